@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// audioArchiveDir holds the recordings kept with history.keep_audio, each
+// named by its recording ID, like its history entry.
+func audioArchiveDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audio"), nil
+}
+
+// archivedAudio returns the kept recording of a history entry, if there is
+// one.
+func archivedAudio(id string) (string, bool) {
+	dir, err := audioArchiveDir()
+	if err != nil || id == "" {
+		return "", false
+	}
+	path := filepath.Join(dir, id+".wav")
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// archiveAudio keeps the recording of a dictation next to the history, so
+// it can be listened to when the transcription looks wrong. With the disk
+// almost full (recording.min_free_mb) archiving pauses rather than taking
+// the last of it. Like the history, it is best effort.
+func archiveAudio(id string, samples []float32) {
+	if !config.History.Enabled || !config.History.KeepAudio || len(samples) == 0 {
+		return
+	}
+	dir, err := audioArchiveDir()
+	if err != nil {
+		fmt.Printf("Warning: failed to keep the recording: %v\n", err)
+		return
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		fmt.Printf("Warning: failed to keep the recording: %v\n", err)
+		return
+	}
+	if free, low := lowDiskSpace(dir); low {
+		fmt.Printf("Warning: only %s of disk space left, not keeping the recording\n", formatBytes(free))
+		return
+	}
+	// Kept as WAV whatever is uploaded, for playback anywhere.
+	archive := wavEncoder{dither: true}
+	if err := checkDiskSpace(dir, archive.Size(len(samples), sampleRate)); err != nil {
+		fmt.Printf("Warning: not keeping the recording: %v\n", err)
+		return
+	}
+
+	path := filepath.Join(dir, id+".wav")
+	// Recordings are as private as the history.
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		fmt.Printf("Warning: failed to keep the recording: %v\n", err)
+		return
+	}
+	err = archive.Encode(file, samples, sampleRate, recordingMetadata(samples))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		fmt.Printf("Warning: failed to keep the recording: %v\n", err)
+		return
+	}
+	pruneAudioArchive(dir)
+}
+
+// pruneAudioArchive removes the oldest recordings until the archive is
+// under history.audio_max_mb.
+func pruneAudioArchive(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type recording struct {
+		path string
+		info os.FileInfo
+	}
+	var recordings []recording
+	var total int64
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".wav") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			recordings = append(recordings, recording{filepath.Join(dir, e.Name()), info})
+			total += info.Size()
+		}
+	}
+	slices.SortFunc(recordings, func(a, b recording) int { return a.info.ModTime().Compare(b.info.ModTime()) })
+	limit := int64(config.History.AudioMaxMB) << 20
+	for _, r := range recordings {
+		if total <= limit {
+			break
+		}
+		if os.Remove(r.path) == nil {
+			total -= r.info.Size()
+		}
+	}
+}
+
+// playRecording starts playing a kept recording and returns a func that
+// stops it and a channel closed once it has stopped, also by itself.
+func playRecording(path string) (func(), <-chan struct{}, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("afplay", path)
+	case "linux":
+		cmd = exec.Command("paplay", path)
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", "(New-Object Media.SoundPlayer $args[0]).PlaySync()", path)
+	default:
+		return nil, nil, fmt.Errorf("playing audio isn't supported on %s", runtime.GOOS)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("playing %s: %w", path, err)
+	}
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	return func() { cmd.Process.Kill() }, done, nil
+}
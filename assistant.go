@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Where the assistant's answers go.
+const (
+	assistantType  = "type"  // typed like a dictation
+	assistantPopup = "popup" // shown in a dialog to insert or copy
+)
+
+// defaultAssistantPrompt is the system prompt of questions when
+// assistant.prompt isn't set.
+const defaultAssistantPrompt = `You answer questions asked by voice. Answer briefly and directly, in plain text without Markdown, in the language of the question. Your answer is typed into the app the user is working in, so reply with the answer only.`
+
+// AssistantConfig turns the hotkey into a small voice assistant: a
+// dictation starting with one of the prefixes is a question for the LLM,
+// and the answer is typed instead of what was said.
+type AssistantConfig struct {
+	Enabled bool `json:"enabled"`
+	// Prefixes are the words that start a question, "ask" by default.
+	Prefixes []string `json:"prefixes"`
+	// Prompt replaces the built-in system prompt.
+	Prompt string `json:"prompt"`
+	// Output is "type" (the default) or "popup".
+	Output string `json:"output"`
+}
+
+// assistantQuestion returns the question of a dictation that starts with
+// an assistant prefix. The prefix has to be followed by punctuation, "Ask,
+// what is..." as Whisper writes it after a short pause, so that dictating
+// "ask Sam to review it" stays a dictation.
+func assistantQuestion(text string) (string, bool) {
+	if !config.Assistant.Enabled {
+		return "", false
+	}
+	for _, prefix := range config.Assistant.Prefixes {
+		re := regexp.MustCompile(`(?i)^\s*` + regexp.QuoteMeta(prefix) + `\s*[,.:;!-]\s*(\S.*)$`)
+		if m := re.FindStringSubmatch(text); m != nil {
+			return capitalizeFirst(m[1]), true
+		}
+	}
+	return "", false
+}
+
+// askAssistant has the profile's LLM answer a question.
+func askAssistant(profile Profile, question string) (string, error) {
+	prompt := config.Assistant.Prompt
+	if prompt == "" {
+		prompt = defaultAssistantPrompt
+	}
+	fmt.Printf("Asking: %s\n", logged(question))
+	started := time.Now()
+	answer, err := chatCompletion(config.llm(profile), prompt, question)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Answered in %dms\n", time.Since(started).Milliseconds())
+	return answer, nil
+}
+
+// showAnswer puts an answer in a dialog to insert or copy it. Without
+// dialogs (other than macOS) it is copied.
+func showAnswer(question, answer string) {
+	switch previewText(tr("Dictation: %s", strings.TrimSpace(question)), answer) {
+	case previewInsert:
+		insertText(answer)
+	case previewCopy, previewUnavailable:
+		if err := injector.WriteClipboard(answer); err != nil {
+			fmt.Printf("Error: copying the answer: %v\n", err)
+			return
+		}
+		notify(tr("Dictation"), tr("The answer is on the clipboard"))
+	}
+}
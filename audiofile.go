@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+)
+
+// uploadFormats are the file extensions the OpenAI transcription endpoint
+// accepts as is.
+var uploadFormats = []string{".flac", ".m4a", ".mp3", ".mp4", ".mpeg", ".mpga", ".oga", ".ogg", ".wav", ".webm"}
+
+// coreAudioFormats are the extensions afconvert handles, so no ffmpeg is
+// needed for them on macOS. It can't read Ogg.
+var coreAudioFormats = []string{".aac", ".aif", ".aiff", ".caf", ".m4a", ".mp3", ".mp4", ".wav"}
+
+// prepareAudioFile returns a file the provider can transcribe: the file
+// itself if its format is accepted, or a 16 kHz mono WAV converted from it.
+// cleanup removes the converted file.
+func prepareAudioFile(path string, provider Provider) (input string, cleanup func(), err error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if _, err := os.Stat(path); err != nil {
+		return "", nil, err
+	}
+
+	// Local workers only read 16 kHz WAV; check the rate of WAV files since
+	// recordings from elsewhere usually have another one.
+	passThrough := slices.Contains(uploadFormats, ext)
+	if provider.local() {
+		passThrough = ext == ".wav" && wavSampleRate(path) == localSampleRate
+	}
+	if passThrough {
+		return path, func() {}, nil
+	}
+
+	out, err := os.CreateTemp("", "dictation-*.wav")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+	out.Close()
+	cleanup = func() { os.Remove(out.Name()) }
+
+	if err := convertToWAV(path, out.Name(), ext); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return out.Name(), cleanup, nil
+}
+
+func wavSampleRate(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	d := wav.NewDecoder(f)
+	d.ReadInfo()
+	if !d.IsValidFile() {
+		return 0
+	}
+	return int(d.SampleRate)
+}
+
+// convertToWAV decodes any audio file to 16 kHz mono 16 bit WAV, with
+// afconvert where it can and ffmpeg otherwise.
+func convertToWAV(in, out, ext string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" && slices.Contains(coreAudioFormats, ext) {
+		cmd = exec.Command("afconvert", "-f", "WAVE", "-d", "LEI16@16000", "-c", "1", in, out)
+	} else if _, err := exec.LookPath("ffmpeg"); err == nil {
+		cmd = exec.Command("ffmpeg", "-nostdin", "-loglevel", "error", "-y", "-i", in, "-ac", "1", "-ar", "16000", "-c:a", "pcm_s16le", out)
+	} else {
+		return fmt.Errorf("can't convert %s files: install ffmpeg (brew install ffmpeg)", ext)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("converting with %s: %w: %s", filepath.Base(cmd.Path), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// probeDuration returns the length of an audio file: WAV files are read
+// directly, other formats are measured with afinfo or ffprobe.
+func probeDuration(path string) (time.Duration, error) {
+	if strings.EqualFold(filepath.Ext(path), ".wav") {
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		return wav.NewDecoder(f).Duration()
+	}
+
+	if runtime.GOOS == "darwin" {
+		out, err := exec.Command("afinfo", path).Output()
+		if err == nil {
+			for _, line := range strings.Split(string(out), "\n") {
+				if v, ok := strings.CutPrefix(strings.TrimSpace(line), "estimated duration:"); ok {
+					return parseSeconds(strings.TrimSuffix(strings.TrimSpace(v), " sec"))
+				}
+			}
+		}
+	}
+
+	if _, err := exec.LookPath("ffprobe"); err == nil {
+		out, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "csv=p=0", path).Output()
+		if err != nil {
+			return 0, fmt.Errorf("running ffprobe: %w", err)
+		}
+		return parseSeconds(strings.TrimSpace(string(out)))
+	}
+	return 0, errors.New("neither afinfo nor ffprobe is available")
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing duration %q: %w", s, err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// audioMagic recognizes audio files by their first bytes, for audio piped
+// in without a file name. The offset is where the magic starts.
+var audioMagic = []struct {
+	offset int
+	magic  string
+	ext    string
+}{
+	{0, "RIFF", ".wav"},
+	{0, "fLaC", ".flac"},
+	{0, "OggS", ".ogg"},
+	{0, "ID3", ".mp3"},
+	{0, "\xff\xfb", ".mp3"},
+	{0, "\xff\xf3", ".mp3"},
+	{0, "\x1a\x45\xdf\xa3", ".webm"},
+	{4, "ftyp", ".m4a"},
+}
+
+// stdinAudio saves audio read from stdin to a temporary file, for
+// `transcribe -` at the end of a pipeline. Files in a known format are kept
+// as they are; anything else is taken to be raw 16 bit little endian mono
+// PCM at rate Hz (sox -t raw, ffmpeg -f s16le) and written as WAV. cleanup
+// removes the file.
+func stdinAudio(rate int) (path string, cleanup func(), err error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading stdin: %w", err)
+	}
+	if len(data) == 0 {
+		return "", nil, errors.New("no audio on stdin")
+	}
+
+	ext := ".wav"
+	raw := true
+	for _, m := range audioMagic {
+		if bytes.HasPrefix(data[min(m.offset, len(data)):], []byte(m.magic)) {
+			ext, raw = m.ext, false
+			break
+		}
+	}
+
+	file, err := os.CreateTemp("", "dictation-stdin-*"+ext)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+	cleanup = func() { os.Remove(file.Name()) }
+	if raw {
+		err = encodePCM(file, data, rate)
+	} else {
+		_, err = file.Write(data)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("saving audio from stdin: %w", err)
+	}
+	return file.Name(), cleanup, nil
+}
+
+// encodePCM writes raw 16 bit little endian mono samples as a WAV file.
+func encodePCM(file io.WriteSeeker, data []byte, rate int) error {
+	samples := make([]int, len(data)/2)
+	for i := range samples {
+		samples[i] = int(int16(binary.LittleEndian.Uint16(data[2*i:])))
+	}
+	encoder := wav.NewEncoder(file, rate, 16, 1, 1)
+	if err := encoder.Write(&audio.IntBuffer{
+		Format:         &audio.Format{NumChannels: 1, SampleRate: rate},
+		Data:           samples,
+		SourceBitDepth: 16,
+	}); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
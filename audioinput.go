@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Opening the audio input is retried after audioRetryDelay, twice as
+	// long after every failure up to maxAudioRetryDelay.
+	audioRetryDelay    = 2 * time.Second
+	maxAudioRetryDelay = time.Minute
+	// audioProblemNotice is how long the input has to stay unavailable
+	// before a notification says so; at login the devices often show up a
+	// few seconds after we start.
+	audioProblemNotice = time.Minute
+)
+
+// audioRetrying is set while watchAudioInput runs, so there is only one.
+var audioRetrying atomic.Bool
+
+// watchAudioInput opens the audio input (without recording) until that
+// works, waiting longer after every failure, so a missing microphone or an
+// audio system that isn't up yet leaves dictation running and waiting for
+// it instead of failing. The problem is in the status meanwhile. It runs at
+// startup and again after a dictation couldn't open the input.
+func watchAudioInput(ctx context.Context) {
+	if !audioRetrying.CompareAndSwap(false, true) {
+		return
+	}
+	defer audioRetrying.Store(false)
+
+	started, delay, notified := time.Now(), audioRetryDelay, false
+	for {
+		recorder, err := newRecorder()
+		if err == nil {
+			device := recorder.Device()
+			recorder.Close()
+			if status.snapshot().AudioProblem != "" {
+				fmt.Printf("Audio input is available again: %s\n", device)
+				status.setAudioProblem("")
+			}
+			return
+		}
+
+		if problem := err.Error(); problem != status.snapshot().AudioProblem {
+			fmt.Printf("Warning: can't open the audio input, retrying: %v\n", err)
+			status.setAudioProblem(problem)
+		}
+		if !notified && time.Since(started) >= audioProblemNotice {
+			notify(tr("Dictation can't record"), tr("No audio input is available: %v", err))
+			notified = true
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxAudioRetryDelay)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	backendAuto  = "auto"
+	backendLocal = "local"
+	backendCloud = "cloud"
+)
+
+// reachabilityTTL is how long a check of the connection to a provider is
+// reused, so dictations in a row don't each wait for one.
+const reachabilityTTL = 30 * time.Second
+
+// AutoBackendConfig tunes how profiles with a local_provider choose between
+// it and their own, cloud, provider.
+type AutoBackendConfig struct {
+	// Default is what is used when none of the conditions below applies,
+	// backendLocal (the default) or backendCloud.
+	Default string `json:"default"`
+	// LongAudio is the recording length from which the cloud is used while
+	// the connection is good, "45s" by default.
+	LongAudio string `json:"long_audio"`
+	// GoodNetwork is the longest connection time to the provider that
+	// counts as a good connection, "300ms" by default.
+	GoodNetwork string `json:"good_network"`
+	// BatteryBelow is the battery level in percent under which the cloud is
+	// used while on battery, so the local model doesn't drain it. 30 by
+	// default, 0 turns it off.
+	BatteryBelow int `json:"battery_below"`
+}
+
+func (a AutoBackendConfig) validate() error {
+	switch a.Default {
+	case backendLocal, backendCloud:
+	default:
+		return fmt.Errorf("auto_backend.default must be \"local\" or \"cloud\", got %q", a.Default)
+	}
+	if err := checkDuration("auto_backend.long_audio", a.LongAudio); err != nil {
+		return err
+	}
+	if err := checkDuration("auto_backend.good_network", a.GoodNetwork); err != nil {
+		return err
+	}
+	if a.BatteryBelow < 0 || a.BatteryBelow > 100 {
+		return fmt.Errorf("auto_backend.battery_below must be 0 to 100, got %d", a.BatteryBelow)
+	}
+	return nil
+}
+
+// onLocal returns the profile with its local_provider instead of its own.
+func (p Profile) onLocal() Profile {
+	p.Provider, p.Model, p.Credential = p.LocalProvider, p.LocalModel, ""
+	return p
+}
+
+// backendOverride is what dictation backend forced, "" for auto. It lasts
+// until dictation restarts.
+var backendOverride struct {
+	sync.Mutex
+	use string
+}
+
+func setBackendOverride(use string) {
+	backendOverride.Lock()
+	defer backendOverride.Unlock()
+	if use == backendAuto {
+		use = ""
+	}
+	backendOverride.use = use
+}
+
+func currentBackendOverride() string {
+	backendOverride.Lock()
+	defer backendOverride.Unlock()
+	return backendOverride.use
+}
+
+// runBackendCommand forces the local or the cloud provider for profiles
+// with a local_provider, or goes back to choosing: dictation backend local.
+// Without an argument it shows what is in effect.
+func runBackendCommand(args []string) error {
+	flags := flag.NewFlagSet("backend", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dictation backend [auto|local|cloud]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	path := "/backend"
+	if flags.NArg() > 0 {
+		switch use := flags.Arg(0); use {
+		case backendAuto, backendLocal, backendCloud:
+			path += "?use=" + use
+		default:
+			return fmt.Errorf("want auto, local or cloud, got %q", use)
+		}
+	}
+	s, err := postControl(path)
+	if err != nil {
+		return err
+	}
+	if s.Backend == "" {
+		fmt.Println("Profiles with a local_provider choose between it and the cloud")
+	} else {
+		fmt.Printf("Profiles with a local_provider always use the %s provider\n", s.Backend)
+	}
+	return nil
+}
+
+type reachability struct {
+	checked time.Time
+	latency time.Duration
+	err     error
+}
+
+var reachabilities = struct {
+	sync.Mutex
+	byHost map[string]reachability
+}{byHost: map[string]reachability{}}
+
+// providerLatency connects to the provider's server and returns how long
+// that took, as a measure of the connection; an error means it can't be
+// reached, we're offline.
+func providerLatency(p Provider) (time.Duration, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil || u.Host == "" {
+		return 0, fmt.Errorf("no server in %q", p.URL)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "443"
+		if u.Scheme == "http" || u.Scheme == "ws" {
+			port = "80"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	reachabilities.Lock()
+	r, ok := reachabilities.byHost[host]
+	reachabilities.Unlock()
+	if ok && time.Since(r.checked) < reachabilityTTL {
+		return r.latency, r.err
+	}
+
+	started := time.Now()
+	conn, err := net.DialTimeout("tcp", host, 2*time.Second)
+	r = reachability{checked: time.Now(), latency: time.Since(started), err: err}
+	if err == nil {
+		conn.Close()
+	}
+	reachabilities.Lock()
+	reachabilities.byHost[host] = r
+	reachabilities.Unlock()
+	return r.latency, r.err
+}
+
+// chooseBackend picks between the cloud provider of a profile and its
+// local_provider for a recording that lasted d, and returns the profile to
+// transcribe it with and why. Profiles without a local_provider are left
+// alone.
+func chooseBackend(profile Profile, d time.Duration) (Profile, string) {
+	if profile.LocalProvider == "" {
+		return profile, ""
+	}
+	use, reason := decideBackend(profile, d)
+	fmt.Printf("Transcribing with the %s provider: %s\n", use, reason)
+	if use == backendLocal {
+		return profile.onLocal(), reason
+	}
+	return profile, reason
+}
+
+func decideBackend(profile Profile, d time.Duration) (use, reason string) {
+	if forced := currentBackendOverride(); forced != "" {
+		return forced, "set with dictation backend"
+	}
+	cfg := config.AutoBackend
+	latency, err := providerLatency(config.provider(profile))
+	if err != nil {
+		return backendLocal, fmt.Sprintf("%s can't be reached (%v)", profile.Provider, err)
+	}
+	if cfg.BatteryBelow > 0 {
+		if percent, battery, ok := onBattery(); ok && battery && percent < cfg.BatteryBelow {
+			return backendCloud, fmt.Sprintf("on battery at %d%%", percent)
+		}
+	}
+	longAudio, _ := time.ParseDuration(cfg.LongAudio)     // checked by validate
+	goodNetwork, _ := time.ParseDuration(cfg.GoodNetwork) // checked by validate
+	if d >= longAudio && latency <= goodNetwork {
+		return backendCloud, fmt.Sprintf("a long recording (%s) and a good connection (%s)", d.Round(time.Second), latency.Round(time.Millisecond))
+	}
+	return cfg.Default, "the default"
+}
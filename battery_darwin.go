@@ -0,0 +1,28 @@
+//go:build darwin
+
+package main
+
+import (
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var batteryPercent = regexp.MustCompile(`(\d+)%`)
+
+// onBattery reports whether the Mac runs on its battery and how full that
+// is, from pmset: "Now drawing from 'Battery Power'" and " 42%; discharging".
+// ok is false on Macs without a battery.
+func onBattery() (percent int, battery, ok bool) {
+	out, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return 0, false, false
+	}
+	m := batteryPercent.FindSubmatch(out)
+	if m == nil {
+		return 0, false, false
+	}
+	percent, _ = strconv.Atoi(string(m[1]))
+	return percent, strings.Contains(string(out), "'Battery Power'"), true
+}
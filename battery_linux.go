@@ -0,0 +1,30 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// onBattery reports whether the machine runs on its battery and how full
+// that is, from the first battery in /sys/class/power_supply. ok is false
+// without one.
+func onBattery() (percent int, battery, ok bool) {
+	dirs, _ := filepath.Glob("/sys/class/power_supply/BAT*")
+	for _, dir := range dirs {
+		capacity, err := os.ReadFile(filepath.Join(dir, "capacity"))
+		if err != nil {
+			continue
+		}
+		percent, err = strconv.Atoi(strings.TrimSpace(string(capacity)))
+		if err != nil {
+			continue
+		}
+		state, _ := os.ReadFile(filepath.Join(dir, "status"))
+		return percent, strings.TrimSpace(string(state)) == "Discharging", true
+	}
+	return 0, false, false
+}
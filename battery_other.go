@@ -0,0 +1,9 @@
+//go:build !darwin && !linux
+
+package main
+
+// Elsewhere the battery isn't looked at.
+
+func onBattery() (percent int, battery, ok bool) {
+	return 0, false, false
+}
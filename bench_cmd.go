@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+)
+
+const benchUsage = "usage: dictation bench providers [-profile name] [-file audio] [-max duration]"
+
+// benchSilence ends the bench recording once the speaker was quiet for it.
+const benchSilence = 2 * time.Second
+
+// benchResult is how one provider did on the bench recording.
+type benchResult struct {
+	provider string
+	model    string
+	latency  time.Duration
+	cost     float64
+	text     string
+	err      error
+}
+
+// runBenchCommand compares the configured providers on the same audio, to
+// help pick the one for the default profile.
+func runBenchCommand(args []string) error {
+	if len(args) == 0 || args[0] != "providers" {
+		return errors.New(benchUsage)
+	}
+	flags := flag.NewFlagSet("bench providers", flag.ExitOnError)
+	profileName := flags.String("profile", defaultProfile, "profile whose transcription parameters to use")
+	file := flags.String("file", "", "transcribe this audio file instead of recording one")
+	maxLength := flags.Duration("max", 15*time.Second, "record at most this long")
+	flags.Parse(args[1:])
+	if flags.NArg() > 0 {
+		return errors.New(benchUsage)
+	}
+
+	base, ok := config.Profiles[*profileName]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", *profileName)
+	}
+	base = applyPreset(base)
+	base.Params.Prompt = vocabularyPrompt(base.Params.Prompt)
+
+	// A recording is kept as samples and saved for every provider at its
+	// upload rate, as for dictations; a file is converted when needed.
+	var samples []float32
+	var audio time.Duration
+	if *file == "" {
+		recorded, err := recordBenchAudio(*maxLength)
+		if err != nil {
+			return err
+		}
+		samples = recorded
+		audio = time.Duration(len(samples)) * time.Second / sampleRate
+	} else {
+		d, err := probeDuration(*file)
+		if err != nil {
+			fmt.Printf("Warning: can't tell the length of %s, costs are left out: %v\n", *file, err)
+		}
+		audio = d
+	}
+
+	var results []benchResult
+	for _, name := range sortedProviders() {
+		provider := config.Providers[name]
+		profile := base
+		profile.Provider = name
+		if provider.Model != "" {
+			profile.Model = provider.Model
+		}
+		if provider.Type == providerFasterWhisperWS {
+			fmt.Printf("Skipping %s, it only streams\n", name)
+			continue
+		}
+		if err := provider.checkAPIKey(); err != nil {
+			fmt.Printf("Skipping %s: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("Transcribing with %s (%s)...\n", name, profile.Model)
+		results = append(results, benchProvider(*file, samples, provider, profile, audio))
+	}
+	if len(results) == 0 {
+		return errors.New("no provider could be benched")
+	}
+	printBench(results, audio)
+	return nil
+}
+
+// recordBenchAudio records one utterance.
+func recordBenchAudio(maxLength time.Duration) ([]float32, error) {
+	fmt.Printf("Say a sentence or two the way you'd dictate them, for example:\n\n    %s\n\n", calibrateSentence)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	return recordOnce(ctx, maxLength, benchSilence)
+}
+
+// sortedProviders returns the names of the configured providers, sorted.
+func sortedProviders() []string {
+	names := make([]string, 0, len(config.Providers))
+	for name := range config.Providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// benchProvider transcribes the recorded samples, or the file at path
+// without them, with one provider. The cache is bypassed, it would make the
+// latency meaningless. Saving the audio at the provider's upload rate or
+// converting the file, and loading a local model, are done before the clock
+// starts, as dictations don't wait for them either.
+func benchProvider(path string, samples []float32, provider Provider, profile Profile, audio time.Duration) benchResult {
+	r := benchResult{provider: profile.Provider, model: profile.Model}
+	input, cleanup, err := benchInput(path, samples, provider)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	defer cleanup()
+	if provider.local() {
+		if r.err = warmUpLocal(provider, profile); r.err != nil {
+			return r
+		}
+	}
+	started := time.Now()
+	r.text, r.err = requestTranscription(input, provider, profile, nil)
+	r.latency = time.Since(started)
+	metrics.record(profile.Provider, r.latency, r.err)
+	if r.err == nil {
+		r.cost = estimateCost(profile, audio)
+		usage.add(profile, audio)
+	}
+	return r
+}
+
+// benchInput returns the file to send to the provider, see benchProvider.
+func benchInput(path string, samples []float32, provider Provider) (string, func(), error) {
+	if samples == nil {
+		return prepareAudioFile(path, provider)
+	}
+	meta := recordingMetadata(samples)
+	rate := provider.uploadRate()
+	if rate != sampleRate {
+		samples = newResampler(sampleRate, rate).process(samples, nil)
+	}
+	saved, err := saveAudioToFile(samples, rate, meta)
+	if err != nil {
+		return "", nil, fmt.Errorf("saving audio file: %w", err)
+	}
+	return saved, func() { os.Remove(saved) }, nil
+}
+
+// printBench shows the latency and cost of every provider, and their
+// transcripts: the first one in full and the others as changes from it.
+func printBench(results []benchResult, audio time.Duration) {
+	width := len("provider")
+	for _, r := range results {
+		width = max(width, len(r.provider))
+	}
+	fmt.Printf("\n%.1fs of audio\n\n", audio.Seconds())
+	fmt.Printf("%-*s  %-24s  %8s  %9s\n", width, "provider", "model", "latency", "cost")
+	var fastest, cheapest *benchResult
+	for i, r := range results {
+		if r.err != nil {
+			fmt.Printf("%-*s  %-24s  failed: %v\n", width, r.provider, r.model, r.err)
+			continue
+		}
+		fmt.Printf("%-*s  %-24s  %7.2fs  $%.5f\n", width, r.provider, r.model, r.latency.Seconds(), r.cost)
+		if fastest == nil || r.latency < fastest.latency {
+			fastest = &results[i]
+		}
+		if cheapest == nil || r.cost < cheapest.cost {
+			cheapest = &results[i]
+		}
+	}
+	if fastest == nil {
+		return
+	}
+
+	fmt.Println()
+	var reference *benchResult
+	for i, r := range results {
+		if r.err != nil {
+			continue
+		}
+		switch {
+		case reference == nil:
+			reference = &results[i]
+			fmt.Printf("%-*s  %s\n", width, r.provider, r.text)
+		case strings.TrimSpace(r.text) == strings.TrimSpace(reference.text):
+			fmt.Printf("%-*s  (same as %s)\n", width, r.provider, reference.provider)
+		default:
+			fmt.Printf("%-*s  %s\n", width, r.provider, wordDiff(reference.text, r.text))
+		}
+	}
+
+	fmt.Printf("\nFastest: %s (%.2fs), cheapest: %s ($%.5f)\n", fastest.provider, fastest.latency.Seconds(), cheapest.provider, cheapest.cost)
+	fmt.Printf("To make one the default, set \"provider\" in profiles.%s of the config.\n", defaultProfile)
+}
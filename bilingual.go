@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Bilingual dictation is for speakers who switch between two (or more)
+// languages mid-sentence, Hinglish or Spanglish. Any single language hint
+// makes Whisper translate or transliterate the other language, so none is
+// sent; instead the prompt says which languages are mixed. In detect mode
+// the language Whisper detected is checked too, and the audio transcribed
+// again with the first language as the hint when it is none of them, which
+// is what happens when it takes Hinglish for Urdu and writes it all in
+// Urdu script.
+
+const (
+	bilingualPrompt = "prompt"
+	bilingualDetect = "detect"
+)
+
+// languageNames are the English names of languages by ISO-639-1 code, as
+// Whisper reports the detected language.
+var languageNames = map[string]string{
+	"ar": "arabic", "bg": "bulgarian", "bn": "bengali", "ca": "catalan", "cs": "czech",
+	"da": "danish", "de": "german", "el": "greek", "en": "english", "es": "spanish",
+	"fa": "persian", "fi": "finnish", "fr": "french", "gu": "gujarati", "he": "hebrew",
+	"hi": "hindi", "hr": "croatian", "hu": "hungarian", "id": "indonesian", "is": "icelandic",
+	"it": "italian", "ja": "japanese", "ko": "korean", "mr": "marathi", "ms": "malay",
+	"nl": "dutch", "no": "norwegian", "pa": "punjabi", "pl": "polish", "pt": "portuguese",
+	"ro": "romanian", "ru": "russian", "sk": "slovak", "sl": "slovenian", "sr": "serbian",
+	"sv": "swedish", "ta": "tamil", "te": "telugu", "th": "thai", "tl": "tagalog",
+	"tr": "turkish", "uk": "ukrainian", "ur": "urdu", "vi": "vietnamese", "zh": "chinese",
+}
+
+// languageCode returns the code of a detected language, which providers
+// report as a name ("english") or a code.
+func languageCode(detected string) string {
+	detected = strings.ToLower(detected)
+	for code, name := range languageNames {
+		if name == detected {
+			return code
+		}
+	}
+	return detected
+}
+
+// mixedLanguagePrompt tells the model which languages are mixed, ahead of
+// the profile's own prompt.
+func mixedLanguagePrompt(languages []string, prompt string) string {
+	names := make([]string, len(languages))
+	for i, code := range languages {
+		names[i] = code
+		if name, ok := languageNames[code]; ok {
+			names[i] = capitalizeFirst(name)
+		}
+	}
+	mixed := fmt.Sprintf("%s mixed, often within one sentence; every word is written in the language it is spoken in.", strings.Join(names, " and "))
+	return strings.TrimSpace(mixed + " " + prompt)
+}
+
+// requestBilingual transcribes with a profile's bilingual setting.
+func requestBilingual(audioFilePath string, provider Provider, profile Profile, trace *span) (string, error) {
+	profile.Params.Language = ""
+	profile.Params.Prompt = mixedLanguagePrompt(profile.Bilingual, profile.Params.Prompt)
+	if profile.BilingualMode != bilingualDetect || provider.local() {
+		text, _, err := requestTranscript(audioFilePath, provider, profile, trace)
+		return text, err
+	}
+
+	profile.Params.ResponseFormat = "verbose_json"
+	text, detected, err := requestTranscript(audioFilePath, provider, profile, trace)
+	if err != nil || detected == "" || slices.Contains(profile.Bilingual, languageCode(detected)) {
+		return text, err
+	}
+	fmt.Printf("Detected %s, which isn't one of %s, transcribing again as %s\n", detected, strings.Join(profile.Bilingual, ", "), profile.Bilingual[0])
+	trace.set("language.detected", detected)
+	profile.Params.Language = profile.Bilingual[0]
+	text, _, err = requestTranscript(audioFilePath, provider, profile, trace)
+	return text, err
+}
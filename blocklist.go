@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// blockedAppPoll is how often the focused app is checked against
+// trigger.blocked_apps, for the status shown by indicators.
+const blockedAppPoll = time.Second
+
+// blockedApp returns the focused app if dictation triggers are blocked in
+// it (trigger.blocked_apps), or "".
+func blockedApp() string {
+	if len(config.Trigger.BlockedApps) == 0 {
+		return ""
+	}
+	app := frontmostApp()
+	if app == "" || !slices.Contains(config.Trigger.BlockedApps, app) {
+		return ""
+	}
+	return app
+}
+
+// watchBlockedApps keeps the blocked state in the status up to date while
+// the focus moves between apps, until ctx is done.
+func watchBlockedApps(ctx context.Context) {
+	if len(config.Trigger.BlockedApps) == 0 {
+		return
+	}
+	ticker := time.NewTicker(blockedAppPoll)
+	defer ticker.Stop()
+	for {
+		if app := blockedApp(); app != status.snapshot().BlockedApp {
+			status.setBlocked(app)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
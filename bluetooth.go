@@ -0,0 +1,55 @@
+package main
+
+import "fmt"
+
+const (
+	// audio.bluetooth settings.
+	bluetoothWarn    = "warn"
+	bluetoothBuiltIn = "builtin"
+	bluetoothAllow   = "allow"
+
+	// handsFreeRate is the highest sample rate a Bluetooth headset's input
+	// runs at in its hands-free (HFP) mode, which is what it switches to as
+	// soon as its mic is used: 8 or 16 kHz, and telephone quality.
+	handsFreeRate = 16000
+)
+
+// audioDevice is an input device of the system.
+type audioDevice struct {
+	id   uint32
+	name string
+}
+
+// bluetoothWarned is the headset last warned about, so the warning comes
+// once per run and not with every recording.
+var bluetoothWarned string
+
+// bluetoothGuard applies audio.bluetooth when the input is a Bluetooth
+// headset in hands-free mode, which Whisper transcribes noticeably worse
+// than a proper microphone: it warns, or returns the built-in microphone to
+// record from instead. ok is false to record from the default input.
+func bluetoothGuard() (mic audioDevice, ok bool) {
+	if config.Audio.Bluetooth == bluetoothAllow {
+		return audioDevice{}, false
+	}
+	name, rate, handsFree := bluetoothHandsFree()
+	if !handsFree {
+		return audioDevice{}, false
+	}
+
+	if config.Audio.Bluetooth == bluetoothBuiltIn {
+		if mic, ok := builtInMicrophone(); ok {
+			fmt.Printf("%s is in hands-free mode (%.0f kHz), recording from %s instead\n", name, rate/1000, mic.name)
+			return mic, true
+		}
+		fmt.Printf("Warning: %s is in hands-free mode (%.0f kHz) and there is no built-in microphone to record from instead\n", name, rate/1000)
+		return audioDevice{}, false
+	}
+
+	if bluetoothWarned != name {
+		bluetoothWarned = name
+		fmt.Printf("Warning: recording from %s in Bluetooth hands-free mode (%.0f kHz), which transcribes noticeably worse; set audio.bluetooth to \"builtin\" to use the built-in microphone instead\n", name, rate/1000)
+		notify(tr("Dictation: low quality microphone"), tr("%s records at %.0f kHz over Bluetooth, transcriptions will be less accurate", name, rate/1000))
+	}
+	return audioDevice{}, false
+}
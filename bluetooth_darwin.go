@@ -0,0 +1,135 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreAudio -framework CoreFoundation
+#include <CoreAudio/CoreAudio.h>
+#include <stdlib.h>
+
+// Element 0 is the main element (kAudioObjectPropertyElementMain, named
+// kAudioObjectPropertyElementMaster before macOS 12).
+
+static AudioDeviceID defaultInputDevice(void) {
+	AudioObjectPropertyAddress address = {kAudioHardwarePropertyDefaultInputDevice, kAudioObjectPropertyScopeGlobal, 0};
+	AudioDeviceID id = kAudioObjectUnknown;
+	UInt32 size = sizeof id;
+	if (AudioObjectGetPropertyData(kAudioObjectSystemObject, &address, 0, NULL, &size, &id) != noErr) {
+		return kAudioObjectUnknown;
+	}
+	return id;
+}
+
+static UInt32 transportType(AudioDeviceID id) {
+	AudioObjectPropertyAddress address = {kAudioDevicePropertyTransportType, kAudioObjectPropertyScopeGlobal, 0};
+	UInt32 transport = 0;
+	UInt32 size = sizeof transport;
+	AudioObjectGetPropertyData(id, &address, 0, NULL, &size, &transport);
+	return transport;
+}
+
+static int isBluetooth(AudioDeviceID id) {
+	UInt32 transport = transportType(id);
+	return transport == kAudioDeviceTransportTypeBluetooth || transport == kAudioDeviceTransportTypeBluetoothLE;
+}
+
+static double nominalRate(AudioDeviceID id) {
+	AudioObjectPropertyAddress address = {kAudioDevicePropertyNominalSampleRate, kAudioObjectPropertyScopeGlobal, 0};
+	Float64 rate = 0;
+	UInt32 size = sizeof rate;
+	AudioObjectGetPropertyData(id, &address, 0, NULL, &size, &rate);
+	return rate;
+}
+
+static int hasInput(AudioDeviceID id) {
+	AudioObjectPropertyAddress address = {kAudioDevicePropertyStreams, kAudioObjectPropertyScopeInput, 0};
+	UInt32 size = 0;
+	if (AudioObjectGetPropertyDataSize(id, &address, 0, NULL, &size) != noErr) {
+		return 0;
+	}
+	return size > 0;
+}
+
+// audioDeviceName returns NULL or a name the caller frees.
+static char *audioDeviceName(AudioDeviceID id) {
+	AudioObjectPropertyAddress address = {kAudioObjectPropertyName, kAudioObjectPropertyScopeGlobal, 0};
+	CFStringRef name = NULL;
+	UInt32 size = sizeof name;
+	if (AudioObjectGetPropertyData(id, &address, 0, NULL, &size, &name) != noErr || name == NULL) {
+		return NULL;
+	}
+	CFIndex max = CFStringGetMaximumSizeForEncoding(CFStringGetLength(name), kCFStringEncodingUTF8) + 1;
+	char *buf = malloc(max);
+	if (buf != NULL && !CFStringGetCString(name, buf, max, kCFStringEncodingUTF8)) {
+		free(buf);
+		buf = NULL;
+	}
+	CFRelease(name);
+	return buf;
+}
+
+static AudioDeviceID builtInInputDevice(void) {
+	AudioObjectPropertyAddress address = {kAudioHardwarePropertyDevices, kAudioObjectPropertyScopeGlobal, 0};
+	UInt32 size = 0;
+	if (AudioObjectGetPropertyDataSize(kAudioObjectSystemObject, &address, 0, NULL, &size) != noErr) {
+		return kAudioObjectUnknown;
+	}
+	AudioDeviceID *ids = malloc(size);
+	if (ids == NULL) {
+		return kAudioObjectUnknown;
+	}
+	AudioDeviceID found = kAudioObjectUnknown;
+	if (AudioObjectGetPropertyData(kAudioObjectSystemObject, &address, 0, NULL, &size, ids) == noErr) {
+		for (UInt32 i = 0; i < size / sizeof *ids; i++) {
+			if (transportType(ids[i]) == kAudioDeviceTransportTypeBuiltIn && hasInput(ids[i])) {
+				found = ids[i];
+				break;
+			}
+		}
+	}
+	free(ids);
+	return found;
+}
+*/
+import "C"
+
+import "unsafe"
+
+// bluetoothHandsFree reports whether the default input is a Bluetooth
+// headset running at a hands-free rate, with its name and rate.
+func bluetoothHandsFree() (name string, rate float64, ok bool) {
+	id := C.defaultInputDevice()
+	if id == C.kAudioObjectUnknown || C.isBluetooth(id) == 0 {
+		return "", 0, false
+	}
+	rate = float64(C.nominalRate(id))
+	if rate == 0 || rate > handsFreeRate {
+		return "", 0, false
+	}
+	name = audioDeviceName(id)
+	if name == "" {
+		name = "the Bluetooth headset"
+	}
+	return name, rate, true
+}
+
+func builtInMicrophone() (audioDevice, bool) {
+	id := C.builtInInputDevice()
+	if id == C.kAudioObjectUnknown {
+		return audioDevice{}, false
+	}
+	mic := audioDevice{id: uint32(id), name: audioDeviceName(id)}
+	if mic.name == "" {
+		mic.name = "the built-in microphone"
+	}
+	return mic, true
+}
+
+func audioDeviceName(id C.AudioDeviceID) string {
+	name := C.audioDeviceName(id)
+	if name == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(name))
+	return C.GoString(name)
+}
@@ -0,0 +1,14 @@
+//go:build !darwin
+
+package main
+
+// Elsewhere the transport of the input device isn't known, so there is no
+// Bluetooth guard.
+
+func bluetoothHandsFree() (name string, rate float64, ok bool) {
+	return "", 0, false
+}
+
+func builtInMicrophone() (audioDevice, bool) {
+	return audioDevice{}, false
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// budgetWarned remembers for which period ("day 2024-10-14", "month
+// 2024-10") a warning was already shown, so it is shown once.
+var (
+	budgetWarnedMu sync.Mutex
+	budgetWarned   = map[string]bool{}
+)
+
+// applyBudget returns the profile to use given the spending caps. Once a cap
+// is reached the user is warned, and if a fallback profile is configured
+// dictation switches to it until the period is over.
+func applyBudget(profile Profile) Profile {
+	cfg := config.Budget
+	if estimateCost(profile, time.Minute) == 0 {
+		return profile // free providers are never capped
+	}
+
+	today, month := usage.spent()
+	var period string
+	switch {
+	case cfg.Daily > 0 && today >= cfg.Daily:
+		period = fmt.Sprintf("daily budget of $%.2f", cfg.Daily)
+	case cfg.Monthly > 0 && month >= cfg.Monthly:
+		period = fmt.Sprintf("monthly budget of $%.2f", cfg.Monthly)
+	default:
+		return profile
+	}
+
+	message := fmt.Sprintf("Spent an estimated $%.2f today and $%.2f this month, over the %s.", today, month, period)
+	if cfg.FallbackProfile != "" {
+		message += fmt.Sprintf(" Using profile %s instead.", cfg.FallbackProfile)
+	}
+
+	budgetWarnedMu.Lock()
+	warned := budgetWarned[period]
+	budgetWarned[period] = true
+	budgetWarnedMu.Unlock()
+
+	// On stderr, so the transcript the CLI commands print stays clean.
+	fmt.Fprintln(os.Stderr, "Warning: "+message)
+	if !warned {
+		message = tr("Spent an estimated $%.2f today and $%.2f this month, over the budget.", today, month)
+		if cfg.FallbackProfile != "" {
+			message += tr(" Using profile %s instead.", cfg.FallbackProfile)
+		}
+		notify(tr("Dictation budget reached"), message)
+	}
+
+	if cfg.FallbackProfile != "" {
+		return config.profile(cfg.FallbackProfile)
+	}
+	return profile
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// The transcription cache keeps results by a hash of the audio and of
+// everything else that goes into the request, so sending the same audio
+// again (a retry after a network error, re-running a batch) doesn't pay
+// for it twice. It lives in the cache directory and is kept under
+// cache.max_size_mb by dropping the least recently used results.
+
+// transcriptCacheKey hashes the audio file together with the provider,
+// model and parameters.
+func transcriptCacheKey(audioFilePath string, provider Provider, profile Profile) (string, error) {
+	file, err := os.Open(audioFilePath)
+	if err != nil {
+		return "", fmt.Errorf("opening audio file: %w", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	params, err := json.Marshal(profile.Params)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00", provider.Type, provider.transcriptionURL(profile), profile.Model, params)
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("reading audio file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func transcriptCacheDir() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "transcripts"), nil
+}
+
+// cachedTranscript returns the cached result for a key, if there is one.
+func cachedTranscript(key string) (string, bool) {
+	dir, err := transcriptCacheDir()
+	if err != nil {
+		return "", false
+	}
+	path := filepath.Join(dir, key+".txt")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	// Touched so eviction goes by last use.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return string(data), true
+}
+
+// storeTranscript caches a result and evicts old ones if the cache grew
+// too big. Failures only cost a cache miss later, so they're only printed.
+func storeTranscript(key, text string) {
+	dir, err := transcriptCacheDir()
+	if err == nil {
+		err = os.MkdirAll(dir, 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, key+".txt"), []byte(text), 0o600)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to cache transcription: %v\n", err)
+		return
+	}
+	evictTranscripts(dir, int64(config.Cache.MaxSizeMB)<<20)
+}
+
+// evictTranscripts removes the least recently used results until the
+// cache is no bigger than limit bytes.
+func evictTranscripts(dir string, limit int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var files []fs.FileInfo
+	var total int64
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	slices.SortFunc(files, func(a, b fs.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+	for _, f := range files {
+		if total <= limit {
+			return
+		}
+		if os.Remove(filepath.Join(dir, f.Name())) == nil {
+			total -= f.Size()
+		}
+	}
+}
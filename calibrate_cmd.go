@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// calibrateNoise and calibrateSpeech are how long the room and the
+	// sample sentence are recorded.
+	calibrateNoise  = 3 * time.Second
+	calibrateSpeech = 6 * time.Second
+	// targetSpeechLevel is the level (RMS) speech is brought to by the
+	// recommended gain, loud enough for Whisper with room to spare before
+	// clipping.
+	targetSpeechLevel = 0.1
+)
+
+// calibrateSentence is what the user reads out during calibration.
+const calibrateSentence = "The quick brown fox jumps over the lazy dog, then takes a short nap in the warm afternoon sun."
+
+// runCalibrateCommand measures the room noise and the user's speech with the
+// current input device, and recommends the gain and silence level to use
+// with it, saving them to the device's audio.devices entry if the user
+// wants.
+func runCalibrateCommand(args []string) error {
+	flags := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	save := flags.Bool("save", false, "save the recommended settings without asking")
+	global := flags.Bool("global", false, "save them as the settings of all devices instead of this one")
+	flags.Parse(args)
+
+	recorder, err := newRecorder()
+	if err != nil {
+		return err
+	}
+	defer recorder.Close()
+	device := recorder.DeviceName()
+	fmt.Printf("Calibrating %s\n", recorder.Device())
+
+	in := bufio.NewReader(os.Stdin)
+	fmt.Printf("\nStay quiet for %.0f seconds to measure the room noise. Press Enter to start.", calibrateNoise.Seconds())
+	in.ReadString('\n')
+	noise, err := measureLevels(recorder, calibrateNoise)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nNow read this out at your normal dictation volume and distance:\n\n    %s\n\nPress Enter to start, you have %.0f seconds.", calibrateSentence, calibrateSpeech.Seconds())
+	in.ReadString('\n')
+	speech, err := measureLevels(recorder, calibrateSpeech)
+	if err != nil {
+		return err
+	}
+
+	c := recommendCalibration(noise, speech)
+	current := config.Audio.device(device)
+	fmt.Printf("\nRoom noise %.4f, speech %.4f (peak %.2f)\n", c.noise, c.speech, c.peak)
+	for _, w := range c.warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+	fmt.Printf("Recommended: \"gain\": %v, \"silence_level\": %v (now %v and %v)\n", c.gain, c.silenceLevel, current.Gain, current.SilenceLevel)
+
+	// Save to the entry the device already uses, or a new one by its name.
+	key, ok := config.Audio.deviceKey(device)
+	if !ok {
+		key = device
+	}
+	where := fmt.Sprintf("the audio.devices entry %q", key)
+	if *global || key == "" {
+		where = "the audio settings"
+	}
+	if !*save {
+		fmt.Printf("Save to %s of the config file? [y/N] ", where)
+		answer, _ := in.ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			return nil
+		}
+	}
+	err = editConfigFile(func(raw map[string]any) {
+		section := configSection(raw, "audio")
+		if !*global && key != "" {
+			section = configSection(configSection(section, "devices"), key)
+		}
+		section["gain"] = c.gain
+		section["silence_level"] = c.silenceLevel
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved to %s. Restart dictation for it to take effect.\n", where)
+	return nil
+}
+
+// levelSample is what a calibration recording measured: the level (RMS) of
+// every buffer and the loudest sample.
+type levelSample struct {
+	levels []float64
+	peak   float64
+}
+
+// measureLevels records for d, through the configured filters but without
+// the gain, since that is what is being calibrated.
+func measureLevels(recorder Recorder, d time.Duration) (levelSample, error) {
+	var m levelSample
+	filter := newAudioFilter()
+	if err := recorder.Start(); err != nil {
+		return m, err
+	}
+	fmt.Println()
+	started := time.Now()
+	for time.Since(started) < d {
+		buffer, err := recorder.Read()
+		if err != nil {
+			recorder.Stop()
+			return m, fmt.Errorf("reading audio: %w", err)
+		}
+		if filter != nil {
+			filter.process(buffer)
+		}
+		level := rmsLevel(buffer)
+		m.levels = append(m.levels, level)
+		for _, s := range buffer {
+			m.peak = max(m.peak, math.Abs(float64(s)))
+		}
+		fmt.Printf("\r%s %s", formatElapsed(time.Since(started)), levelBar(level))
+	}
+	fmt.Println()
+	return m, recorder.Stop()
+}
+
+// levelBar draws a level as a bar, on a log scale from -60 dB to 0.
+func levelBar(level float64) string {
+	const width = 40
+	db := 20 * math.Log10(max(level, 1e-6))
+	n := int((db + 60) / 60 * width)
+	n = max(0, min(width, n))
+	return "[" + strings.Repeat("#", n) + strings.Repeat(" ", width-n) + "]"
+}
+
+// calibration is the outcome of a calibration.
+type calibration struct {
+	noise, speech, peak float64
+	gain, silenceLevel  float64
+	warnings            []string
+}
+
+// recommendCalibration works out the settings from the two recordings. The
+// noise level is the median buffer, so a cough doesn't count, and the speech
+// level the 90th percentile, as much of a sentence is the gaps between words.
+// The gain brings speech to targetSpeechLevel, and the silence level sits
+// halfway between noise and speech on a log scale.
+func recommendCalibration(noise, speech levelSample) calibration {
+	c := calibration{
+		noise:  percentile(noise.levels, 0.5),
+		speech: percentile(speech.levels, 0.9),
+		peak:   speech.peak,
+	}
+
+	c.gain = 1
+	if c.speech > 0 {
+		c.gain = max(0.5, min(8, targetSpeechLevel/c.speech))
+	}
+	if c.peak >= 0.99 {
+		// Already clipping before any gain; more would only make it worse.
+		c.gain = min(c.gain, 1)
+		c.warnings = append(c.warnings, "the input clipped, turn down the input volume in the system sound settings and calibrate again")
+	}
+	if c.speech < 3*c.noise {
+		c.warnings = append(c.warnings, "your speech is barely louder than the room, move closer to the microphone or try a quieter place")
+	}
+	if c.speech < 0.002 {
+		c.warnings = append(c.warnings, "almost nothing was recorded, check that the right input device is selected")
+	}
+
+	silence := math.Sqrt(max(c.noise, 1e-5)*max(c.speech, 1e-5)) * c.gain
+	silence = max(silence, 2*c.noise*c.gain)
+	c.gain = math.Round(c.gain*10) / 10
+	c.silenceLevel = max(0.001, min(0.5, math.Round(silence*1000)/1000))
+	return c
+}
+
+// percentile returns the value below which fraction p of levels lie.
+func percentile(levels []float64, p float64) float64 {
+	if len(levels) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(levels)
+	slices.Sort(sorted)
+	return sorted[int(p*float64(len(sorted)-1))]
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// captureProgress is what the capture loop reports with every buffer.
+type captureProgress struct {
+	// Buffer is the audio just captured, after the gain and filters. It
+	// is only valid during the call.
+	Buffer []float32
+	// Level is the RMS level of the buffer, 0 to 1.
+	Level float64
+	// Elapsed is how much audio was captured so far.
+	Elapsed time.Duration
+}
+
+// captureSummary is how a capture went, once it's over.
+type captureSummary struct {
+	Device string
+	// Reads is how many buffers were read, Overruns how many times audio
+	// was lost in between.
+	Reads, Overruns int
+	// Err is what ended the capture early, nil when it was stopped.
+	Err error
+}
+
+// captureHooks are how the capture loop tells its user what is going on,
+// for the status, the level meter and the cues, and the segmenter or
+// silence detection. They run in the capture goroutine, so they have to
+// be quick; any of them can be nil.
+type captureHooks struct {
+	// Started is called once the input device is recording.
+	Started func(device string)
+	// Progress is called with every buffer; the capture goes on while it
+	// returns true.
+	Progress func(captureProgress) bool
+	// Stopped is called when the capture ended, after the device stopped.
+	Stopped func(captureSummary)
+}
+
+// captureAudio records from the input device until hooks.Progress says to
+// stop, ctx is done, the read fails or the disk is about to fill up, and
+// returns the samples. Failing to open the device is an error; a capture
+// that fails after that returns what it got until then, and only fails
+// when that is nothing.
+func captureAudio(ctx context.Context, hooks captureHooks) ([]float32, error) {
+	recorder, err := newRecorder()
+	if err != nil {
+		return nil, err
+	}
+	defer recorder.Close()
+
+	filter := newAudioFilter()
+	device := recorder.DeviceName()
+	captureSettings = config.Audio.device(device)
+	restoreMute, muted := muteGuard(device)
+	defer restoreMute()
+	if err := recorder.Start(); err != nil {
+		return nil, err
+	}
+	if hooks.Started != nil {
+		hooks.Started(device)
+	}
+
+	var blocks sampleBlocks
+	var reads int
+	var readErr error
+	// heard is set once a buffer was louder than a pause.
+	var heard bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			// A crash while reading ends the recording, what was read
+			// until then is kept.
+			if r := recover(); r != nil {
+				readErr = crashed("recorder", r)
+			}
+		}()
+		started, lastDiskCheck := time.Now(), time.Now()
+		for ctx.Err() == nil {
+			buffer, err := recorder.Read()
+			if err != nil {
+				// Reads fail once the recorder is stopped for ctx.
+				if ctx.Err() == nil {
+					readErr = fmt.Errorf("reading from stream: %w", err)
+				}
+				return
+			}
+			reads++
+			if config.Audio.DuckPlayback && soundsPlaying.Load() > 0 {
+				// Our own sound is playing; keep it out of the recording.
+				clear(buffer)
+			}
+			if gain := captureSettings.Gain; gain != 1 {
+				applyGain(buffer, gain)
+			}
+			if filter != nil {
+				filter.process(buffer)
+			}
+			if blocks.append(buffer) {
+				metrics.sampleMemory(blocks.bytes())
+			}
+			if time.Since(lastDiskCheck) >= diskCheckInterval {
+				lastDiskCheck = time.Now()
+				if !recordingFits(time.Since(started)) {
+					// Stop while what was recorded can still be saved.
+					fmt.Println("Warning: the disk is almost full, stopping the recording")
+					notify(tr("Dictation: disk almost full"), tr("The recording was stopped so it can still be saved"))
+					return
+				}
+			}
+			progress := captureProgress{
+				Buffer:  buffer,
+				Level:   rmsLevel(buffer),
+				Elapsed: time.Duration(blocks.len()) * time.Second / sampleRate,
+			}
+			heard = heard || progress.Level >= captureSettings.SilenceLevel
+			if hooks.Progress != nil && !hooks.Progress(progress) {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+	stopErr := recorder.Stop()
+	// The reader may still be in Read or appending its last buffer; what
+	// it shares is only ours once it returned.
+	<-done
+	samples := blocks.samples()
+
+	summary := captureSummary{Device: device, Reads: reads, Overruns: recorder.Overruns(), Err: readErr}
+	if hooks.Stopped != nil {
+		hooks.Stopped(summary)
+	}
+	if stopErr != nil {
+		return nil, stopErr
+	}
+	if readErr != nil && len(samples) == 0 {
+		return nil, readErr
+	}
+	if muted && !heard {
+		return nil, errInputMuted
+	}
+	return samples, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modifiers is a set of modifier keys. Left and right keys count the same.
+type modifiers uint8
+
+const (
+	modCtrl modifiers = 1 << iota
+	modShift
+	modAlt
+	modCmd
+)
+
+// modifierNames are the names modifiers can have in chords.
+var modifierNames = map[string]modifiers{
+	"ctrl": modCtrl, "control": modCtrl,
+	"shift": modShift,
+	"alt":   modAlt, "option": modAlt, "opt": modAlt,
+	"cmd": modCmd, "command": modCmd, "super": modCmd, "win": modCmd,
+}
+
+// chord is a key pressed with exactly a set of modifiers, written like
+// "ctrl+option+d".
+type chord struct {
+	mods modifiers
+	key  uint16
+}
+
+func parseChord(s string) (chord, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "+")
+	var c chord
+	for _, name := range parts[:len(parts)-1] {
+		m, ok := modifierNames[name]
+		if !ok {
+			return chord{}, fmt.Errorf("unknown modifier %q in %q", name, s)
+		}
+		c.mods |= m
+	}
+	key, ok := keyCodes[parts[len(parts)-1]]
+	if !ok {
+		return chord{}, fmt.Errorf("unknown key %q in %q", parts[len(parts)-1], s)
+	}
+	c.key = key
+	return c, nil
+}
+
+// matches reports whether a key press is the chord. Extra modifiers make it
+// a different chord, so ctrl+c doesn't fire on ctrl+shift+c.
+func (c chord) matches(ev keyEvent) bool {
+	return ev.down && ev.code == c.key && ev.mods == c.mods
+}
+
+// modifierState tracks which modifier keys are held, per key, so releasing
+// right Ctrl while left Ctrl is still down keeps Ctrl held.
+type modifierState map[uint16]bool
+
+// update records a key event and adds the modifiers held to it (platforms
+// that report modifiers with the event already set some). It reports
+// whether the event is a modifier key itself.
+func (s modifierState) update(ev *keyEvent) bool {
+	_, isModifier := modifierKeys[ev.code]
+	if isModifier {
+		s[ev.code] = ev.down
+	}
+	for code, down := range s {
+		if down && code != ev.code {
+			ev.mods |= modifierKeys[code]
+		}
+	}
+	return isModifier
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"maps"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// codeSymbols maps spoken symbol names to what they stand for in code mode.
+var codeSymbols = map[string]string{
+	"open paren":            "(",
+	"close paren":           ")",
+	"open bracket":          "[",
+	"close bracket":         "]",
+	"open brace":            "{",
+	"close brace":           "}",
+	"open angle":            "<",
+	"close angle":           ">",
+	"equals equals":         "==",
+	"triple equals":         "===",
+	"not equals":            "!=",
+	"less than or equal":    "<=",
+	"greater than or equal": ">=",
+	"less than":             "<",
+	"greater than":          ">",
+	"equals":                "=",
+	"colon equals":          ":=",
+	"plus equals":           "+=",
+	"minus equals":          "-=",
+	"plus plus":             "++",
+	"minus minus":           "--",
+	"fat arrow":             "=>",
+	"arrow":                 "->",
+	"double colon":          "::",
+	"and and":               "&&",
+	"or or":                 "||",
+	"ampersand":             "&",
+	"pipe":                  "|",
+	"bang":                  "!",
+	"star":                  "*",
+	"slash":                 "/",
+	"backslash":             `\`,
+	"plus":                  "+",
+	"minus":                 "-",
+	"dash":                  "-",
+	"underscore":            "_",
+	"dot":                   ".",
+	"comma":                 ",",
+	"colon":                 ":",
+	"semicolon":             ";",
+	"hash":                  "#",
+	"dollar":                "$",
+	"at sign":               "@",
+	"percent":               "%",
+	"caret":                 "^",
+	"tilde":                 "~",
+	"backtick":              "`",
+	"quote":                 `"`,
+	"single quote":          "'",
+	"new line":              "\n",
+	"tab key":               "\t",
+}
+
+// codeGrammar is what code mode understands in a locale: the English
+// symbol names with the locale's on top.
+type codeGrammar struct {
+	symbols map[string]string
+	pattern *regexp.Regexp
+}
+
+// codeGrammars caches the codeGrammar of each locale, built when first
+// used.
+var codeGrammars sync.Map
+
+func codeGrammarFor(name string) *codeGrammar {
+	if g, ok := codeGrammars.Load(name); ok {
+		return g.(*codeGrammar)
+	}
+	symbols := maps.Clone(codeSymbols)
+	maps.Copy(symbols, locales[name].Symbols)
+	phrases := make([]string, 0, len(symbols))
+	for p := range symbols {
+		phrases = append(phrases, p)
+	}
+	// Longest first, so "equals equals" wins over "equals".
+	sort.Slice(phrases, func(i, j int) bool { return len(phrases[i]) > len(phrases[j]) })
+	for i, p := range phrases {
+		phrases[i] = strings.ReplaceAll(regexp.QuoteMeta(p), " ", `[\s,]+`)
+	}
+	g := &codeGrammar{
+		symbols: symbols,
+		pattern: regexp.MustCompile(`(?i)\b(` + strings.Join(phrases, "|") + `)\b[.,]?`),
+	}
+	codeGrammars.Store(name, g)
+	return g
+}
+
+// These remove the spaces around symbols that are written without them.
+var (
+	spaceAfterOpening  = regexp.MustCompile(`([(\[{.]) +`)
+	spaceBeforeClosing = regexp.MustCompile(` +([)\]}.,;])`)
+	spaceBeforeColon   = regexp.MustCompile(` +(:)([^=]|$)`)
+	spaceInsideQuotes  = regexp.MustCompile("([\"'`]) *([^\"'`]*?) *([\"'`])")
+	spaceBeforeOpening = regexp.MustCompile(`(\w) +([(\[])`)
+	spaceAroundNewline = regexp.MustCompile(` *([\n\t]) *`)
+)
+
+// applyCodeSymbols turns spoken symbols into characters and removes the
+// prose punctuation Whisper adds, so "if x equals equals y open brace"
+// becomes "if x == y {". The symbols can be said in the locale's language
+// too.
+func applyCodeSymbols(text string) string {
+	text = strings.TrimSuffix(strings.TrimSpace(text), ".")
+
+	grammar := codeGrammarFor(activeLocale())
+	text = grammar.pattern.ReplaceAllStringFunc(text, func(match string) string {
+		phrase := strings.ToLower(strings.TrimRight(match, ".,"))
+		phrase = strings.Join(strings.FieldsFunc(phrase, func(r rune) bool { return r == ' ' || r == ',' }), " ")
+		return " " + grammar.symbols[phrase] + " "
+	})
+
+	text = spaceAfterOpening.ReplaceAllString(text, "$1")
+	text = spaceBeforeClosing.ReplaceAllString(text, "$1")
+	text = spaceBeforeColon.ReplaceAllString(text, "$1$2")
+	text = spaceInsideQuotes.ReplaceAllString(text, "$1$2$3")
+	text = spaceBeforeOpening.ReplaceAllString(text, "$1$2")
+	text = spaceAroundNewline.ReplaceAllString(text, "$1")
+	return strings.TrimSpace(repeatedSpaces.ReplaceAllString(text, " "))
+}
+
+// codeLanguages maps file extensions to language names for the prompt.
+var codeLanguages = map[string]string{
+	".c": "C", ".h": "C", ".cc": "C++", ".cpp": "C++", ".hpp": "C++",
+	".cs": "C#", ".css": "CSS", ".ex": "Elixir", ".exs": "Elixir",
+	".go": "Go", ".html": "HTML", ".java": "Java", ".js": "JavaScript",
+	".jsx": "JavaScript", ".kt": "Kotlin", ".lua": "Lua", ".m": "Objective-C",
+	".php": "PHP", ".py": "Python", ".rb": "Ruby", ".rs": "Rust",
+	".scala": "Scala", ".sh": "shell", ".sql": "SQL", ".swift": "Swift",
+	".ts": "TypeScript", ".tsx": "TypeScript", ".zig": "Zig",
+}
+
+// fileInTitle finds a file name in a window title, as editors show it
+// ("main.go — project", "project – main.go").
+var fileInTitle = regexp.MustCompile(`[\w.-]+\.[A-Za-z]{1,6}\b`)
+
+// detectCodeLanguage guesses the language being edited from the title of
+// the focused window. It returns "" when there is no recognizable file.
+func detectCodeLanguage() string {
+	for _, name := range fileInTitle.FindAllString(windowTitle(), -1) {
+		if lang, ok := codeLanguages[strings.ToLower(filepath.Ext(name))]; ok {
+			return lang
+		}
+	}
+	return ""
+}
+
+// codePrompt biases the transcription toward code in the detected language.
+// Whisper follows the style of the prompt, so it is written the way code is
+// dictated.
+func codePrompt(base string) string {
+	prompt := "Programming. Dictating source code with identifiers like getUserByID, user_id, HTTPClient, JSON, API, stdout, async, await, const, struct, enum."
+	if lang := detectCodeLanguage(); lang != "" {
+		prompt = "Programming in " + lang + ". " + strings.TrimPrefix(prompt, "Programming. ")
+	}
+	if base != "" {
+		prompt = base + " " + prompt
+	}
+	return prompt
+}
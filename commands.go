@@ -0,0 +1,52 @@
+package main
+
+import "fmt"
+
+// runCommand runs a subcommand. Without one the program listens for the
+// dictation triggers.
+func runCommand(name string, args []string) error {
+	switch name {
+	case "transcribe":
+		return runTranscribeCommand(args)
+	case "once":
+		return runOnceCommand(args)
+	case "watch":
+		return runWatchCommand(args)
+	case "history":
+		return runHistoryCommand(args)
+	case "status":
+		return runStatusCommand(args)
+	case "statusline":
+		return runStatuslineCommand(args)
+	case "dashboard":
+		return runDashboardCommand(args)
+	case "doctor":
+		return runDoctorCommand(args)
+	case "pause":
+		return runPauseCommand(args)
+	case "resume":
+		return runResumeCommand(args)
+	case "backend":
+		return runBackendCommand(args)
+	case "preflight":
+		return runPreflightCommand(args)
+	case "calibrate":
+		return runCalibrateCommand(args)
+	case "bench":
+		return runBenchCommand(args)
+	case "relay":
+		return runRelayCommand(args)
+	case "privacy":
+		return runPrivacyCommand(args)
+	case "vocabulary":
+		return runVocabularyCommand(args)
+	case "presets":
+		return runPresetsCommand(args)
+	case "config":
+		return runConfigCommand(args)
+	case "version", "-version", "--version":
+		return runVersionCommand(args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
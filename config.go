@@ -0,0 +1,1583 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Config holds the user settings read from the config file. Every field is
+// optional; a missing file or field keeps the built-in default.
+type Config struct {
+	Trigger   TriggerConfig       `json:"trigger"`
+	Audio     AudioConfig         `json:"audio"`
+	Recording RecordingConfig     `json:"recording"`
+	Providers map[string]Provider `json:"providers"`
+	// Credentials are named API keys and accounts, selected per profile.
+	Credentials map[string]Credential `json:"credentials"`
+	Profiles    map[string]Profile    `json:"profiles"`
+	// Transcription holds request parameters for all profiles; a profile
+	// can override each of them.
+	Transcription TranscriptionParams `json:"transcription"`
+	Limits        LimitsConfig        `json:"limits"`
+	Budget        BudgetConfig        `json:"budget"`
+	// AutoBackend tunes the choice between the local and cloud provider of
+	// profiles with a local_provider.
+	AutoBackend AutoBackendConfig `json:"auto_backend"`
+	Output      OutputConfig      `json:"output"`
+	Profanity   ProfanityConfig   `json:"profanity"`
+	// Hallucinations are phrases never typed as they are.
+	Hallucinations HallucinationConfig `json:"hallucinations"`
+	Vocabulary     VocabularyConfig    `json:"vocabulary"`
+	History        HistoryConfig       `json:"history"`
+	Cache          CacheConfig         `json:"cache"`
+	// InputLanguages maps keyboard layout IDs to languages, for profiles
+	// with LanguageFromInput, on top of the built-in table.
+	InputLanguages map[string]string `json:"input_languages"`
+	// Targets are named places to send dictations to instead of the
+	// focused app.
+	Targets map[string]TargetConfig `json:"targets"`
+	// Apps holds per app settings, keyed by bundle ID.
+	Apps       map[string]AppConfig `json:"apps"`
+	StreamDeck StreamDeckConfig     `json:"stream_deck"`
+	LLM        LLMConfig            `json:"llm"`
+	Assistant  AssistantConfig      `json:"assistant"`
+	Feedback   FeedbackConfig       `json:"feedback"`
+	Privacy    PrivacyConfig        `json:"privacy"`
+	Relay      RelayConfig          `json:"relay"`
+	Tracing    TracingConfig        `json:"tracing"`
+	// UpdateCheck looks for a newer release on GitHub at startup.
+	UpdateCheck bool `json:"update_check"`
+	// EventLog writes an event per transcription to events.jsonl in the log
+	// directory.
+	EventLog bool `json:"event_log"`
+	// Preflight checks at startup, and every few hours, that the providers
+	// in use can be reached and accept their API key.
+	Preflight bool `json:"preflight"`
+	// DataDir replaces the data directory (history, usage...), to keep
+	// the state of separate setups apart. Their logs and caches go below
+	// it too.
+	DataDir string `json:"data_dir"`
+	// Locale is the language of notifications, dialogs and spoken
+	// commands: "en" (the default), one of locales, or "auto" for the
+	// system's.
+	Locale string `json:"locale"`
+
+	// presets are the prompt presets profiles can use, read along with the
+	// config.
+	presets []promptPreset
+}
+
+// AudioConfig controls audio capture.
+type AudioConfig struct {
+	// Backend is "coreaudio", "portaudio" or "auto", which uses CoreAudio
+	// on macOS and portaudio elsewhere or if CoreAudio fails.
+	Backend string `json:"backend"`
+	// FrameSize is the number of frames per captured buffer. Smaller ones
+	// lower the latency of streaming, some USB interfaces need bigger ones
+	// to keep up.
+	FrameSize int `json:"frame_size"`
+	// Latency is the input latency asked of portaudio: "low", "high" (the
+	// default) or a duration such as "20ms".
+	Latency string `json:"latency"`
+	// Channels is how many channels portaudio captures, downmixed to mono;
+	// 0 is mono, or more if the device refuses mono.
+	Channels int `json:"channels"`
+	// Channel (1-based) uses only that input channel instead of a downmix
+	// of all of them, e.g. for an interface with the mic on input 2.
+	Channel int `json:"channel"`
+	// HighPassHz filters out everything below it (rumble, hum, plosives),
+	// 80 to 120 suits speech. 0 is off.
+	HighPassHz int `json:"high_pass_hz"`
+	// DeEss turns down harsh "s" sounds.
+	DeEss bool `json:"de_ess"`
+	// DuckPlayback silences the recording while a sound of ours (like the
+	// reminder) plays, so it isn't transcribed.
+	DuckPlayback bool `json:"duck_playback"`
+	// Gain multiplies the captured audio, for microphones that are too
+	// quiet (or too loud). 1 leaves it as is.
+	Gain float64 `json:"gain"`
+	// SilenceLevel is the level (RMS, 0 to 1) below which audio counts as
+	// a pause, where long recordings are cut into segments.
+	SilenceLevel float64 `json:"silence_level"`
+	// Bluetooth is what to do when the input is a Bluetooth headset in its
+	// low quality hands-free mode: "warn" (the default), "builtin" to
+	// record from the built-in microphone instead, or "allow".
+	Bluetooth string `json:"bluetooth"`
+	// Muted is what to do when the input is muted or its volume is all the
+	// way down as a recording starts: "warn" (the default) right away,
+	// "unmute" it until the recording is over, or "allow".
+	Muted string `json:"muted"`
+	// UploadSampleRate is the rate recordings are uploaded to cloud
+	// providers at, sampleRate when 0. Whisper works at 16000, so that
+	// makes uploads less than half the size without losing anything.
+	UploadSampleRate int `json:"upload_sample_rate"`
+	// Devices holds settings for particular input devices, keyed by device
+	// name or a part of it ("AirPods"), applied whenever that device is the
+	// input.
+	Devices map[string]DeviceSettings `json:"devices"`
+}
+
+// DeviceSettings are the audio settings of one input device. Zero values
+// keep the general audio settings.
+type DeviceSettings struct {
+	Gain         float64 `json:"gain"`
+	SilenceLevel float64 `json:"silence_level"`
+	// SampleRate is the rate portaudio opens the device at, for devices
+	// that don't do 16 kHz well; the audio is resampled. CoreAudio always
+	// captures at the device's own rate.
+	SampleRate int `json:"sample_rate"`
+}
+
+// deviceKey returns the audio.devices key for an input device: its name, or
+// else the longest key that is part of the name, ignoring case.
+func (a AudioConfig) deviceKey(name string) (string, bool) {
+	if _, ok := a.Devices[name]; ok {
+		return name, true
+	}
+	if name == "" {
+		return "", false
+	}
+	found := ""
+	for key := range a.Devices {
+		if len(key) > len(found) && strings.Contains(strings.ToLower(name), strings.ToLower(key)) {
+			found = key
+		}
+	}
+	return found, found != ""
+}
+
+// device returns the settings for an input device, the general ones with
+// those of its audio.devices entry on top.
+func (a AudioConfig) device(name string) DeviceSettings {
+	s := DeviceSettings{Gain: a.Gain, SilenceLevel: a.SilenceLevel}
+	key, ok := a.deviceKey(name)
+	if !ok {
+		return s
+	}
+	d := a.Devices[key]
+	if d.Gain != 0 {
+		s.Gain = d.Gain
+	}
+	if d.SilenceLevel != 0 {
+		s.SilenceLevel = d.SilenceLevel
+	}
+	s.SampleRate = d.SampleRate
+	return s
+}
+
+// RecordingConfig controls what happens while recording.
+type RecordingConfig struct {
+	// Reminder is how long a recording can run before a reminder that the
+	// microphone is still live, e.g. "5m", repeated at that interval.
+	// Empty disables it.
+	Reminder string `json:"reminder"`
+	// ReminderSound is a sound file played as the reminder instead of a
+	// notification, the same as feedback.sounds.reminder.
+	ReminderSound string `json:"reminder_sound"`
+	// SegmentAfter is how long a recording runs before it is cut at the
+	// next pause, so the part so far can be transcribed and typed while
+	// recording goes on, e.g. "60s". Empty disables it.
+	SegmentAfter string `json:"segment_after"`
+	// ParagraphPause is the pause between two segments that starts a new
+	// paragraph, e.g. "2s". Empty never does.
+	ParagraphPause string `json:"paragraph_pause"`
+	// MinFreeMB is the free disk space below which a recording starts with
+	// a warning. 0 disables the warning.
+	MinFreeMB int `json:"min_free_mb"`
+}
+
+// reminder returns the reminder interval, zero when disabled.
+func (r RecordingConfig) reminder() time.Duration {
+	d, _ := time.ParseDuration(r.Reminder) // checked by validate
+	return d
+}
+
+// paragraphPause returns the paragraph pause, zero when disabled.
+func (r RecordingConfig) paragraphPause() time.Duration {
+	d, _ := time.ParseDuration(r.ParagraphPause) // checked by validate
+	return d
+}
+
+// segmentAfter returns the segment length, zero when segmenting is off.
+func (r RecordingConfig) segmentAfter() time.Duration {
+	d, _ := time.ParseDuration(r.SegmentAfter) // checked by validate
+	return d
+}
+
+// OutputConfig controls how the transcription gets into the focused app.
+type OutputConfig struct {
+	// Mode is "type" (synthesized key strokes), "paste" (through the
+	// clipboard) or "auto", which types ASCII text on the TypingLayouts and
+	// pastes otherwise.
+	Mode string `json:"mode"`
+	// TypingLayouts are the keyboard layout IDs typing works with.
+	TypingLayouts []string `json:"typing_layouts"`
+	// Trailing is appended after each insertion: "none", "space" or
+	// "newline".
+	Trailing string `json:"trailing"`
+	// AutoSend presses Return after each insertion, to send dictated chat
+	// messages. Usually enabled per app instead.
+	AutoSend bool `json:"auto_send"`
+	// AutoSendConfirm asks before pressing Return.
+	AutoSendConfirm bool `json:"auto_send_confirm"`
+	// AutoSendExclude lists apps that are never auto-sent to.
+	AutoSendExclude []string `json:"auto_send_exclude"`
+	// KeyCommands are phrases that, said at the end of a dictation, press
+	// a key after inserting it, like "send it" for "enter". Off when
+	// empty.
+	KeyCommands map[string]string `json:"key_commands"`
+	// FocusChange is what happens when another app has focus by the time
+	// the text is ready than when the recording stopped: "insert" it
+	// anyway (the default), "hold" it for the reinsert hotkey, or show a
+	// "preview" to insert or copy it from.
+	FocusChange string `json:"focus_change"`
+	// RemoteSession is what happens when the focused app is a remote
+	// desktop or screen sharing viewer: "insert" anyway (the default),
+	// "hold" for the reinsert hotkey, or "preview" to confirm first.
+	RemoteSession string `json:"remote_session"`
+	// RemoteApps are apps to treat as remote sessions besides the known
+	// ones.
+	RemoteApps []string `json:"remote_apps"`
+	// CopyLast also puts every transcription on the clipboard, so it is one
+	// paste away wherever it ended up: "history" (the default) only long
+	// enough for clipboard managers to record it, "clipboard" leaves it
+	// there, "off" doesn't.
+	CopyLast string `json:"copy_last"`
+	// InsertOrderWait is how long a recording that is still being
+	// transcribed keeps its place in the insertion queue, so the
+	// dictations that stopped after it wait; "15s" by default, "0s" inserts
+	// them in the order they are ready.
+	InsertOrderWait string `json:"insert_order_wait"`
+}
+
+func (o OutputConfig) insertOrderWait() time.Duration {
+	d, _ := time.ParseDuration(o.InsertOrderWait) // checked by validate
+	return d
+}
+
+// HistoryConfig controls the history of dictations.
+type HistoryConfig struct {
+	// Enabled keeps the dictations on disk, off by default.
+	Enabled bool `json:"enabled"`
+	// AppContext also records the app and window title the text went to.
+	AppContext bool `json:"app_context"`
+	// KeepAudio keeps the recording of every dictation, to play back from
+	// the history. AudioMaxMB bounds the space they take; the oldest go.
+	KeepAudio  bool `json:"keep_audio"`
+	AudioMaxMB int  `json:"audio_max_mb"`
+}
+
+// CacheConfig controls the cache of transcriptions by audio hash.
+type CacheConfig struct {
+	Enabled bool `json:"enabled"`
+	// MaxSizeMB bounds the size of the cache on disk.
+	MaxSizeMB int `json:"max_size_mb"`
+}
+
+// ProfanityConfig controls the swear word filter.
+type ProfanityConfig struct {
+	// Mode is "keep" (no filtering), "mask" (f***) or "remove".
+	Mode string `json:"mode"`
+	// Words are added to the built-in list.
+	Words []string `json:"words"`
+}
+
+// VocabularyConfig controls the replacement dictionary and the words added to
+// the prompt, on top of the corrections learned from the user.
+type VocabularyConfig struct {
+	// Replacements map what the transcription says to what it should,
+	// matched as whole words ignoring case.
+	Replacements map[string]string `json:"replacements"`
+	// Words are names and jargon added to the prompt.
+	Words []string `json:"words"`
+	// Prompt adds Words and the learned corrections to the prompt.
+	Prompt bool `json:"prompt"`
+	// WatchEdits learns corrections from edits made to a dictation right
+	// after it was typed (macOS).
+	WatchEdits bool `json:"watch_edits"`
+	// LearnAfter is how many times the same edit has to be seen before it
+	// is applied. Corrections flagged in the history apply right away.
+	LearnAfter int `json:"learn_after"`
+}
+
+// AppConfig holds settings that apply while an app has focus.
+type AppConfig struct {
+	// TerminalSafe strips characters that could run a command when typed
+	// into a shell.
+	TerminalSafe bool `json:"terminal_safe"`
+	// Trailing overrides OutputConfig.Trailing when set.
+	Trailing string `json:"trailing"`
+	// AutoSend overrides OutputConfig.AutoSend when set.
+	AutoSend *bool `json:"auto_send"`
+	// KeyCommands add to and override OutputConfig.KeyCommands in the
+	// app; an empty key turns a phrase off.
+	KeyCommands map[string]string `json:"key_commands"`
+}
+
+// app returns the settings of an app, which are zero for unknown ones.
+func (c Config) app(bundleID string) AppConfig {
+	return c.Apps[bundleID]
+}
+
+// autoSend reports whether Return should be pressed after inserting into an
+// app. Terminals never get it, whatever the config says.
+func (c Config) autoSend(bundleID string) bool {
+	app := c.app(bundleID)
+	if bundleID == "" || app.TerminalSafe || slices.Contains(c.Output.AutoSendExclude, bundleID) {
+		return false
+	}
+	if app.AutoSend != nil {
+		return *app.AutoSend
+	}
+	return c.Output.AutoSend
+}
+
+// BudgetConfig caps the estimated spend in USD. Zero means no cap.
+type BudgetConfig struct {
+	Daily   float64 `json:"daily"`
+	Monthly float64 `json:"monthly"`
+	// FallbackProfile is switched to once a cap is reached, typically one
+	// using a local provider. Without it there is only a warning.
+	FallbackProfile string `json:"fallback_profile"`
+}
+
+// LimitsConfig caps provider API usage. Zero means unlimited.
+type LimitsConfig struct {
+	MaxInFlight       int `json:"max_in_flight"`
+	RequestsPerMinute int `json:"requests_per_minute"`
+}
+
+// Provider is a transcription endpoint. By default it is an OpenAI compatible
+// HTTP endpoint the recording is uploaded to once it stops.
+type Provider struct {
+	// Type selects the protocol: empty for OpenAI compatible uploads,
+	// providerAzure, providerFasterWhisperWS, providerMLXWhisper or
+	// providerSubprocess.
+	Type string `json:"type"`
+	// URL is the endpoint; for Azure, the resource endpoint
+	// ("https://name.openai.azure.com") the deployment URL is built from.
+	URL string `json:"url"`
+	// Deployment is the Azure deployment to use, the profile's model when
+	// empty, and APIVersion the Azure API version.
+	Deployment string `json:"deployment"`
+	APIVersion string `json:"api_version"`
+	// Command runs the worker of subprocess providers. For mlx-whisper it
+	// is the Python interpreter to use, "python3" by default.
+	Command []string `json:"command"`
+	// APIKeyEnv names the environment variable holding the API key, so the
+	// key itself doesn't have to live in the config file.
+	APIKeyEnv string `json:"api_key_env"`
+	// APIKeyCommand prints the API key, e.g. ["op", "read", "op://..."],
+	// so it doesn't have to be in the environment either. It wins over
+	// APIKeyEnv.
+	APIKeyCommand []string `json:"api_key_command"`
+	// APIKeys are several keys to spread requests over, or to fall back
+	// to when one is rate limited, instead of the one above.
+	// APIKeySelection is keyFailover (the default) or keyRoundRobin.
+	APIKeys         []APIKey `json:"api_keys"`
+	APIKeySelection string   `json:"api_key_selection"`
+	// Organization and Project are sent as the OpenAI-Organization and
+	// OpenAI-Project headers, so usage is billed to the right account.
+	Organization string `json:"organization"`
+	Project      string `json:"project"`
+	// Model is used by profiles that don't pick one.
+	Model string `json:"model"`
+	// PricePerMinute overrides the built-in price of the model, in USD per
+	// minute of audio, for cost estimates.
+	PricePerMinute *float64 `json:"price_per_minute"`
+	// MaxUploadMB is the largest file the provider takes, in MB;
+	// defaultMaxUploadMB when 0, no limit when negative. Longer
+	// recordings are split to stay below it.
+	MaxUploadMB int       `json:"max_upload_mb"`
+	TLS         TLSConfig `json:"tls"`
+}
+
+// TLSConfig is for endpoints behind gateways that use a private CA or want
+// a client certificate (mTLS). Files are PEM encoded.
+type TLSConfig struct {
+	// CAFile holds CA certificates trusted on top of the system ones.
+	CAFile   string `json:"ca_file"`
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+// providerAzure is an Azure OpenAI resource: the same API as OpenAI, with
+// deployment URLs and an api-key header.
+const providerAzure = "azure"
+
+// defaultAzureAPIVersion is used when an Azure provider doesn't set one.
+const defaultAzureAPIVersion = "2024-06-01"
+
+// providerFasterWhisperWS streams audio to faster-whisper-server over its
+// websocket endpoint while recording.
+const providerFasterWhisperWS = "faster-whisper-ws"
+
+// providerMLXWhisper runs mlx_whisper in a local Python worker process, and
+// providerSubprocess runs any command speaking the same worker protocol.
+const (
+	providerMLXWhisper = "mlx-whisper"
+	providerSubprocess = "subprocess"
+)
+
+// local reports whether the provider runs in a worker process.
+func (p Provider) local() bool {
+	return p.Type == providerMLXWhisper || p.Type == providerSubprocess
+}
+
+// uploadRate is the sample rate of the audio the provider gets. Local
+// workers get 16 kHz audio, which is what Whisper works with, so they don't
+// need ffmpeg or a resampler of their own.
+func (p Provider) uploadRate() int {
+	switch {
+	case p.local():
+		return localSampleRate
+	case config.Audio.UploadSampleRate != 0:
+		return config.Audio.UploadSampleRate
+	}
+	return sampleRate
+}
+
+// defaultMaxUploadMB is the file size limit of OpenAI's and Groq's
+// transcription APIs.
+const defaultMaxUploadMB = 25
+
+// uploadLimit returns the largest upload the provider takes in bytes, 0 for
+// no limit. Local workers and streaming servers don't get files.
+func (p Provider) uploadLimit() int64 {
+	switch {
+	case p.local() || p.Type == providerFasterWhisperWS || p.MaxUploadMB < 0:
+		return 0
+	case p.MaxUploadMB > 0:
+		return int64(p.MaxUploadMB) * 1000 * 1000
+	}
+	return defaultMaxUploadMB * 1000 * 1000
+}
+
+// apiKey returns the provider's (first) API key, "" if it has none or it
+// can't be read (see checkAPIKey).
+func (p Provider) apiKey() string {
+	key, _ := p.keys()[0].lookup()
+	return key
+}
+
+// checkAPIKey reports why an API key the provider is configured with isn't
+// available.
+func (p Provider) checkAPIKey() error {
+	keys := p.keys()
+	for i, k := range keys {
+		if err := k.check(); err != nil {
+			if len(keys) > 1 {
+				return fmt.Errorf("api_keys[%d]: %w", i, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// transcriptionURL returns the URL transcriptions are posted to.
+func (p Provider) transcriptionURL(profile Profile) string {
+	if p.Type != providerAzure {
+		return p.URL
+	}
+	deployment := p.Deployment
+	if deployment == "" {
+		deployment = profile.Model
+	}
+	return fmt.Sprintf("%s/openai/deployments/%s/audio/transcriptions?api-version=%s",
+		strings.TrimSuffix(p.URL, "/"), url.PathEscape(deployment), url.QueryEscape(p.azureAPIVersion()))
+}
+
+// modelsURL returns the URL listing the available models, if the provider
+// has one.
+func (p Provider) modelsURL() (string, bool) {
+	if p.Type == providerAzure {
+		return fmt.Sprintf("%s/openai/models?api-version=%s", strings.TrimSuffix(p.URL, "/"), url.QueryEscape(p.azureAPIVersion())), true
+	}
+	base, ok := strings.CutSuffix(p.URL, "/audio/transcriptions")
+	return base + "/models", ok
+}
+
+func (p Provider) azureAPIVersion() string {
+	if p.APIVersion == "" {
+		return defaultAzureAPIVersion
+	}
+	return p.APIVersion
+}
+
+// setAuthHeaders adds the (first) API key and the OpenAI account headers
+// that are set to a request.
+func (p Provider) setAuthHeaders(h http.Header) {
+	p.setAuthKey(h, p.apiKey())
+	p.setAccountHeaders(h)
+}
+
+func (p Provider) setAccountHeaders(h http.Header) {
+	if p.Organization != "" {
+		h.Set("OpenAI-Organization", p.Organization)
+	}
+	if p.Project != "" {
+		h.Set("OpenAI-Project", p.Project)
+	}
+}
+
+// Credential is a named set of account settings, e.g. "work" and
+// "personal", that profiles pick from to use a different API key or
+// account with the same provider. Set fields replace the provider's.
+type Credential struct {
+	APIKeyEnv     string   `json:"api_key_env"`
+	APIKeyCommand []string `json:"api_key_command"`
+	Organization  string   `json:"organization"`
+	Project       string   `json:"project"`
+}
+
+// provider returns the provider of a profile, with the profile's
+// credential applied.
+func (c Config) provider(profile Profile) Provider {
+	p := c.Providers[profile.Provider]
+	cred := c.Credentials[profile.Credential]
+	if cred.APIKeyEnv != "" || len(cred.APIKeyCommand) > 0 {
+		p.APIKeyEnv, p.APIKeyCommand, p.APIKeys = cred.APIKeyEnv, cred.APIKeyCommand, nil
+	}
+	if cred.Organization != "" {
+		p.Organization = cred.Organization
+	}
+	if cred.Project != "" {
+		p.Project = cred.Project
+	}
+	return p
+}
+
+// TracingConfig controls the export of OpenTelemetry traces.
+type TracingConfig struct {
+	// Endpoint is the base URL of an OTLP/HTTP collector, e.g.
+	// "http://localhost:4318". Empty disables tracing.
+	Endpoint string `json:"endpoint"`
+	// Headers are sent with every export, for collectors needing a token.
+	Headers map[string]string `json:"headers"`
+}
+
+// LLMConfig is the OpenAI compatible chat completions endpoint, or Ollama
+// server, used by profiles with cleanup on. A profile's llm overrides parts
+// of it.
+type LLMConfig struct {
+	// Type is "openai" (the default) or "ollama". Ollama's URL defaults to
+	// its local server, and it takes no API key.
+	Type          string   `json:"type"`
+	URL           string   `json:"url"`
+	APIKeyEnv     string   `json:"api_key_env"`
+	APIKeyCommand []string `json:"api_key_command"`
+	Model         string   `json:"model"`
+	// Temperature is a pointer because 0 is a meaningful value; unset uses
+	// the endpoint's default.
+	Temperature *float64 `json:"temperature,omitempty"`
+	// CleanupPrompt replaces the built-in system prompt of the cleanup.
+	CleanupPrompt string `json:"cleanup_prompt"`
+	// KeepAlive is how long Ollama keeps the model loaded after a request,
+	// e.g. "30m"; unset is Ollama's default of 5 minutes.
+	KeepAlive string `json:"keep_alive"`
+}
+
+// llm returns the LLM setup of a profile: the llm section with what the
+// profile's own llm sets instead. A profile with another url doesn't get
+// the global key, which belongs to the other endpoint: the type, url and
+// key settings are taken together.
+func (c Config) llm(p Profile) LLMConfig {
+	l := c.LLM
+	o := p.LLM
+	if o == nil {
+		return l
+	}
+	if o.Type != "" || o.URL != "" || o.APIKeyEnv != "" || len(o.APIKeyCommand) > 0 {
+		// The global model is for another kind of endpoint.
+		if llmType(o.Type) != llmType(l.Type) {
+			l.Model = defaultLLMModel(o.Type)
+		}
+		l.Type, l.URL, l.APIKeyEnv, l.APIKeyCommand = o.Type, o.URL, o.APIKeyEnv, o.APIKeyCommand
+	}
+	if o.Model != "" {
+		l.Model = o.Model
+	}
+	if o.Temperature != nil {
+		l.Temperature = o.Temperature
+	}
+	if o.CleanupPrompt != "" {
+		l.CleanupPrompt = o.CleanupPrompt
+	}
+	if o.KeepAlive != "" {
+		l.KeepAlive = o.KeepAlive
+	}
+	return l
+}
+
+// validate checks an LLM setup; used says whether a profile needs it, an
+// unused one only has to be well formed.
+func (l LLMConfig) validate(used bool) error {
+	switch l.Type {
+	case "", llmOpenAI:
+		if used && l.URL == "" {
+			return errors.New("llm has no url")
+		}
+	case llmOllama:
+	default:
+		return fmt.Errorf("llm type must be \"openai\" or \"ollama\", got %q", l.Type)
+	}
+	if used && l.Model == "" {
+		return errors.New("llm has no model")
+	}
+	if t := l.Temperature; t != nil && (*t < 0 || *t > 2) {
+		return fmt.Errorf("llm temperature must be between 0 and 2, got %g", *t)
+	}
+	if l.KeepAlive != "" && l.Type != llmOllama {
+		return errors.New("llm keep_alive is only for the ollama type")
+	}
+	return nil
+}
+
+func (l LLMConfig) apiKey() string {
+	key, _ := lookupSecret(l.APIKeyEnv, l.APIKeyCommand)
+	return key
+}
+
+const (
+	// draftInsert types the whole draft once dictation stops, draftEditor
+	// opens it in a text editor.
+	draftInsert = "insert"
+	draftEditor = "editor"
+)
+
+// Profile is a named transcription setup that triggers can be bound to.
+type Profile struct {
+	// Name is the key of the profile in the config.
+	Name     string `json:"-"`
+	Provider string `json:"provider"`
+	// Credential names the entry of Credentials to use with the provider.
+	Credential string `json:"credential"`
+	Model      string `json:"model"`
+	// RemoveFillers strips "um", "uh", repeated words and false starts.
+	RemoveFillers bool `json:"remove_fillers"`
+	// VoiceCommands runs spoken commands such as "camel case foo bar".
+	VoiceCommands bool `json:"voice_commands"`
+	// CodeMode is for dictating code: spoken symbols, casing commands and a
+	// prompt for the language of the file being edited.
+	CodeMode bool `json:"code_mode"`
+	// Preset is a prompt preset (see dictation presets) for an accent or
+	// domain, like "indian-english" or "medical".
+	Preset string `json:"preset"`
+	// Cleanup has the LLM rewrite the transcription: punctuation,
+	// capitalization, transcription mistakes.
+	Cleanup bool `json:"cleanup"`
+	// CleanupReview shows what the cleanup changed before inserting, to
+	// accept, edit or reject it.
+	CleanupReview bool `json:"cleanup_review"`
+	// LLM overrides the llm section for this profile's cleanup, e.g.
+	// another model or a local one.
+	LLM *LLMConfig `json:"llm"`
+	// Draft is draft mode: nothing is typed while recording, the whole
+	// dictation is cleaned up once at the end and then typed (draftInsert)
+	// or opened in an editor (draftEditor). Empty is off.
+	Draft string `json:"draft"`
+	// LanguageFromInput sets the language from the active keyboard layout
+	// when a recording starts, if it is known.
+	LanguageFromInput bool `json:"language_from_input"`
+	// Bilingual lists the languages (ISO-639-1) a speaker mixes within
+	// sentences; no language hint is sent then. BilingualMode is "prompt"
+	// (the default), or "detect" to transcribe again with the first
+	// language when Whisper detects none of them.
+	Bilingual     []string `json:"bilingual"`
+	BilingualMode string   `json:"bilingual_mode"`
+	// Target is the named target (see Config.Targets) dictations go to,
+	// the focused app when empty.
+	Target string `json:"target"`
+	// Timestamp is put in front of every dictation, with time placeholders
+	// such as {time} filled in, for journals: "[{date} {time}] ".
+	Timestamp string `json:"timestamp"`
+	// Template is a Go template the final text is formatted with before it
+	// is inserted or sent, e.g. "> {{.Text}}"; see templateData.
+	Template string `json:"template"`
+	// AfterInsert are actions done in the app once a dictation was typed:
+	// actionSelect, actionCursor and key presses like "escape".
+	AfterInsert []string `json:"after_insert"`
+	// quick is set for dictations started in quick mode, see QuickConfig.
+	quick bool
+	// LocalProvider is a local provider (LocalModel its model) the profile
+	// switches to when offline, for short recordings and the like; see
+	// AutoBackendConfig. Empty always uses Provider.
+	LocalProvider string `json:"local_provider"`
+	LocalModel    string `json:"local_model"`
+	// Params is flattened into the profile object in the config file, after
+	// loading it also has the global values for everything not overridden.
+	Params TranscriptionParams `json:"-"`
+}
+
+func (p Profile) checkBilingual() error {
+	if len(p.Bilingual) == 1 {
+		return errors.New("bilingual needs at least two languages")
+	}
+	for _, lang := range p.Bilingual {
+		if len(lang) != 2 || strings.ToLower(lang) != lang {
+			return fmt.Errorf("bilingual languages are ISO-639-1 codes like \"en\", got %q", lang)
+		}
+	}
+	switch p.BilingualMode {
+	case "", bilingualPrompt:
+	case bilingualDetect:
+		// Detection needs the verbose_json response, which carries the
+		// detected language.
+		if len(p.Bilingual) == 0 {
+			return errors.New("bilingual_mode needs bilingual")
+		}
+		if !slices.Contains(lookupModel(p.Model).responseFormats, "verbose_json") || p.Params.streams() {
+			return fmt.Errorf("bilingual_mode \"detect\" needs a model with verbose_json responses, like whisper-1, and no stream")
+		}
+		switch p.Params.ResponseFormat {
+		case "", "json", "verbose_json":
+		default:
+			return fmt.Errorf("bilingual_mode \"detect\" doesn't work with response_format %q", p.Params.ResponseFormat)
+		}
+	default:
+		return fmt.Errorf("bilingual_mode must be \"prompt\" or \"detect\", got %q", p.BilingualMode)
+	}
+	return nil
+}
+
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	type plain Profile
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &p.Params)
+}
+
+// TranscriptionParams are the optional parameters of the transcriptions
+// endpoint. Zero values are not sent.
+type TranscriptionParams struct {
+	Language string `json:"language,omitempty"`
+	Prompt   string `json:"prompt,omitempty"`
+	// Temperature is a pointer because 0 is a meaningful value.
+	Temperature    *float64 `json:"temperature,omitempty"`
+	ResponseFormat string   `json:"response_format,omitempty"`
+	// TimestampGranularities ("word", "segment") need the verbose_json
+	// response format.
+	TimestampGranularities []string `json:"timestamp_granularities,omitempty"`
+	// Stream asks for the transcript to be sent as it is produced. Only the
+	// gpt-4o based models support it.
+	Stream *bool `json:"stream,omitempty"`
+}
+
+func (p TranscriptionParams) streams() bool {
+	return p.Stream != nil && *p.Stream
+}
+
+// over returns p with every unset field taken from base.
+func (p TranscriptionParams) over(base TranscriptionParams) TranscriptionParams {
+	if p.Language == "" {
+		p.Language = base.Language
+	}
+	if p.Prompt == "" {
+		p.Prompt = base.Prompt
+	}
+	if p.Temperature == nil {
+		p.Temperature = base.Temperature
+	}
+	if p.ResponseFormat == "" {
+		p.ResponseFormat = base.ResponseFormat
+	}
+	if p.TimestampGranularities == nil {
+		p.TimestampGranularities = base.TimestampGranularities
+	}
+	if p.Stream == nil {
+		p.Stream = base.Stream
+	}
+	return p
+}
+
+func (p TranscriptionParams) validate() error {
+	switch p.ResponseFormat {
+	case "", "json", "text", "srt", "verbose_json", "vtt":
+	default:
+		return fmt.Errorf("unknown response_format %q", p.ResponseFormat)
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 1) {
+		return fmt.Errorf("temperature must be between 0 and 1, got %v", *p.Temperature)
+	}
+	for _, g := range p.TimestampGranularities {
+		if g != "word" && g != "segment" {
+			return fmt.Errorf("unknown timestamp granularity %q", g)
+		}
+	}
+	if len(p.TimestampGranularities) > 0 && p.ResponseFormat != "verbose_json" {
+		return fmt.Errorf("timestamp_granularities needs response_format verbose_json")
+	}
+	return nil
+}
+
+// defaultProfile is used by triggers that aren't bound to a profile.
+const defaultProfile = "default"
+
+// HotkeyConfig binds a key, chord ("ctrl+option+d") or sequence of chords
+// ("ctrl+k d") to an action. Globe alone keeps its double press to start,
+// single press to stop gesture; anything else toggles on each press.
+type HotkeyConfig struct {
+	Key     string `json:"key"`
+	Profile string `json:"profile"`
+	// Action is "toggle" (the default), "abort", which stops and discards
+	// the recording, or "reinsert", which types the last dictation again.
+	Action string `json:"action"`
+	// Hold makes the hotkey fire only once held this long, e.g. "500ms".
+	Hold string `json:"hold"`
+	// Target sends the dictations started with the hotkey to a named
+	// target instead of the profile's.
+	Target string `json:"target"`
+}
+
+type TriggerConfig struct {
+	// MediaKey lets the play/pause media key (including the one sent by
+	// Bluetooth headsets and AirPods) start and stop dictation.
+	MediaKey bool `json:"media_key"`
+
+	MIDI MIDIConfig `json:"midi"`
+
+	// Hotkeys replaces the default Globe binding when set.
+	Hotkeys []HotkeyConfig `json:"hotkeys"`
+
+	// Quick is quick mode, started by holding the Globe key.
+	Quick QuickConfig `json:"quick"`
+
+	// Quit is the chord that quits when running in a terminal, "ctrl+c"
+	// by default. Empty disables it.
+	Quit string `json:"quit"`
+
+	// BlockedApps lists the apps (bundle IDs) in which the triggers don't
+	// start dictation, such as games that use the Globe key.
+	BlockedApps []string `json:"blocked_apps"`
+
+	// QuietHours are the times of day in which the triggers are ignored,
+	// such as meetings.
+	QuietHours []QuietHours `json:"quiet_hours"`
+}
+
+// MIDIConfig describes a MIDI foot switch. Pedals usually send a control
+// change (64 is the sustain pedal); some send notes instead, which is what
+// Note is for. A negative value disables that kind of message.
+type MIDIConfig struct {
+	Enabled bool `json:"enabled"`
+	// Mode is either "toggle" (press to start, press again to stop) or
+	// "hold" (record while the pedal is held down).
+	Mode    string `json:"mode"`
+	Control int    `json:"control"`
+	Note    int    `json:"note"`
+}
+
+type StreamDeckConfig struct {
+	// Listen is the local address of the Stream Deck API, e.g.
+	// "127.0.0.1:7799". Empty disables it.
+	Listen string `json:"listen"`
+}
+
+var config Config
+
+func defaultConfig() Config {
+	return Config{
+		Trigger: TriggerConfig{
+			MIDI: MIDIConfig{
+				Mode:    "toggle",
+				Control: 64,
+				Note:    -1,
+			},
+			Hotkeys: []HotkeyConfig{{Key: defaultHotkey, Profile: defaultProfile}},
+			Quick:   QuickConfig{Hold: "1s", Silence: "1s", Max: "30s", Submit: true},
+			Quit:    "ctrl+c",
+		},
+		Audio:     AudioConfig{Backend: audioBackendAuto, FrameSize: defaultFrameSize, DuckPlayback: true, Gain: 1, SilenceLevel: defaultSilenceLevel, Bluetooth: bluetoothWarn, Muted: mutedWarn},
+		Recording: RecordingConfig{MinFreeMB: 500},
+		AutoBackend: AutoBackendConfig{
+			Default:      backendLocal,
+			LongAudio:    "45s",
+			GoodNetwork:  "300ms",
+			BatteryBelow: 30,
+		},
+		Profanity: ProfanityConfig{Mode: profanityKeep},
+		Hallucinations: HallucinationConfig{
+			Mode:    hallucinationsDrop,
+			BuiltIn: true,
+		},
+		History:    HistoryConfig{AudioMaxMB: 1000},
+		Vocabulary: VocabularyConfig{Prompt: true, LearnAfter: 2},
+		Preflight:  true,
+		Locale:     localeEnglish,
+		Targets: map[string]TargetConfig{
+			"app":       {Type: targetApp},
+			"clipboard": {Type: targetClipboard},
+		},
+		Cache: CacheConfig{MaxSizeMB: 50},
+		Output: OutputConfig{
+			Mode:            insertAuto,
+			Trailing:        trailingNone,
+			FocusChange:     confirmInsert,
+			RemoteSession:   confirmInsert,
+			CopyLast:        copyLastHistory,
+			InsertOrderWait: "15s",
+			TypingLayouts: []string{
+				"com.apple.keylayout.US",
+				"com.apple.keylayout.ABC",
+				"com.apple.keylayout.USExtended",
+				"com.apple.keylayout.USInternational-PC",
+			},
+		},
+		Apps: map[string]AppConfig{
+			"com.apple.Terminal":     {TerminalSafe: true},
+			"com.googlecode.iterm2":  {TerminalSafe: true},
+			"io.alacritty":           {TerminalSafe: true},
+			"net.kovidgoyal.kitty":   {TerminalSafe: true},
+			"com.github.wez.wezterm": {TerminalSafe: true},
+			"dev.warp.Warp-Stable":   {TerminalSafe: true},
+			"com.mitchellh.ghostty":  {TerminalSafe: true},
+			// Linux window classes.
+			"Alacritty":                   {TerminalSafe: true},
+			"kitty":                       {TerminalSafe: true},
+			"org.wezfurlong.wezterm":      {TerminalSafe: true},
+			"Gnome-terminal":              {TerminalSafe: true},
+			"konsole":                     {TerminalSafe: true},
+			"XTerm":                       {TerminalSafe: true},
+			"com.mitchellh.ghostty.linux": {TerminalSafe: true},
+			// Windows executables.
+			"WindowsTerminal.exe": {TerminalSafe: true},
+			"conhost.exe":         {TerminalSafe: true},
+			"wezterm-gui.exe":     {TerminalSafe: true},
+			"alacritty.exe":       {TerminalSafe: true},
+		},
+		Providers: map[string]Provider{
+			"openai": {
+				URL:       "https://api.openai.com/v1/audio/transcriptions",
+				APIKeyEnv: "OPENAI_API_KEY",
+				Model:     "whisper-1",
+			},
+			"groq": {
+				URL:       "https://api.groq.com/openai/v1/audio/transcriptions",
+				APIKeyEnv: "GROQ_API_KEY",
+				Model:     "whisper-large-v3",
+			},
+		},
+		Profiles: map[string]Profile{
+			defaultProfile: {Provider: "openai"},
+		},
+		// The url, key and model of the openai type are filled in by
+		// applyDefaults; Ollama has its own url and no default model.
+		Relay: RelayConfig{
+			Listen: "127.0.0.1:8790",
+			MaxMB:  25,
+		},
+		Privacy: PrivacyConfig{
+			History:    true,
+			Audio:      true,
+			AppContext: true,
+			Logs:       true,
+			Analytics:  true,
+		},
+		Feedback: FeedbackConfig{
+			Visual: visualOff,
+		},
+		Assistant: AssistantConfig{
+			Prefixes: []string{"ask"},
+			Output:   assistantType,
+		},
+	}
+}
+
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+
+	// Without a config file the defaults are used, but a missing file it
+	// includes is a mistake.
+	raw := map[string]any{}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if _, explicit := explicitConfigPath(); explicit {
+			return cfg, fmt.Errorf("reading config: %w", err)
+		}
+	} else if raw, err = readConfigFile(path, nil); err != nil {
+		return cfg, err
+	}
+	if err := applyEnvOverrides(raw); err != nil {
+		return cfg, fmt.Errorf("invalid environment: %w", err)
+	}
+	// Decoding into cfg keeps the defaults of settings the file leaves out,
+	// but not within map entries, which are decoded from scratch: an
+	// override of only the key of the built-in openai provider would lose
+	// its URL. So the file is merged onto the defaults first.
+	defaults, err := json.Marshal(cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("merging config %s: %w", path, err)
+	}
+	merged := map[string]any{}
+	if err := json.Unmarshal(defaults, &merged); err != nil {
+		return cfg, fmt.Errorf("merging config %s: %w", path, err)
+	}
+	mergeConfig(merged, raw)
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return cfg, fmt.Errorf("merging config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+
+	cfg.applyDefaults()
+	cfg.applyPrivacy()
+	if cfg.presets, err = loadPresets(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// readConfigFile reads a config file as generic JSON, with the files it
+// lists in "include" merged in below it. including holds the files being
+// read, to catch include loops.
+func readConfigFile(path string, including []string) (map[string]any, error) {
+	if slices.Contains(including, path) {
+		return nil, fmt.Errorf("config %s includes itself", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if len(including) > 0 {
+			return nil, fmt.Errorf("reading config %s included by %s: %w", path, including[len(including)-1], err)
+		}
+		return nil, err
+	}
+	raw := map[string]any{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	return resolveIncludes(path, raw, including)
+}
+
+// resolveIncludes merges the files raw includes below it. Included paths
+// are relative to the directory of the including file. raw isn't changed.
+func resolveIncludes(path string, raw map[string]any, including []string) (map[string]any, error) {
+	includes, _ := raw["include"].([]any)
+	merged := map[string]any{}
+	for _, include := range includes {
+		name, ok := include.(string)
+		if !ok {
+			return nil, fmt.Errorf("config %s: include must be a list of file names", path)
+		}
+		name, err := expandHome(name)
+		if err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(path), name)
+		}
+		included, err := readConfigFile(name, append(including, path))
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(merged, included)
+	}
+	mergeConfig(merged, raw)
+	delete(merged, "include")
+	return merged, nil
+}
+
+// mergeConfig merges src into dst: objects are merged key by key, anything
+// else in src replaces what dst has.
+func mergeConfig(dst, src map[string]any) {
+	for key, value := range src {
+		if obj, ok := value.(map[string]any); ok {
+			if existing, ok := dst[key].(map[string]any); ok {
+				mergeConfig(existing, obj)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// editConfigFile changes settings in the config file, for commands that save
+// them. edit gets the file as generic JSON, so settings this version doesn't
+// know about survive; the file is written back with its keys sorted. The
+// result must still be a valid config.
+func editConfigFile(edit func(raw map[string]any)) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	raw := map[string]any{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("parsing config %s: %w", path, err)
+		}
+	}
+
+	edit(raw)
+	data, err = json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	// Checked with the included files, but only this one is written.
+	merged, err := resolveIncludes(path, raw, nil)
+	if err != nil {
+		return err
+	}
+	check, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	cfg := defaultConfig()
+	if err := json.Unmarshal(check, &cfg); err != nil {
+		return fmt.Errorf("checking config: %w", err)
+	}
+	cfg.applyDefaults()
+	cfg.presets = config.presets
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("the changed config would be invalid: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+	return nil
+}
+
+// configSection returns the object under key in raw config JSON, adding an
+// empty one if there is none.
+func configSection(raw map[string]any, key string) map[string]any {
+	section, ok := raw[key].(map[string]any)
+	if !ok {
+		section = map[string]any{}
+		raw[key] = section
+	}
+	return section
+}
+
+// applyDefaults fills in what a profile leaves out: the OpenAI provider, the
+// provider's model and the global transcription parameters.
+func (c *Config) applyDefaults() {
+	if _, ok := c.Profiles[defaultProfile]; !ok {
+		c.Profiles[defaultProfile] = Profile{}
+	}
+	for name, p := range c.Profiles {
+		if p.Provider == "" {
+			p.Provider = "openai"
+		}
+		if p.Model == "" {
+			p.Model = c.Providers[p.Provider].Model
+		}
+		if p.LocalModel == "" {
+			p.LocalModel = c.Providers[p.LocalProvider].Model
+		}
+		p.Params = p.Params.over(c.Transcription)
+		p.Name = name
+		c.Profiles[name] = p
+	}
+	for i, h := range c.Trigger.Hotkeys {
+		if h.Profile == "" {
+			c.Trigger.Hotkeys[i].Profile = defaultProfile
+		}
+	}
+	if c.Trigger.Quick.Profile == "" {
+		c.Trigger.Quick.Profile = defaultProfile
+	}
+	if c.LLM.Type == "" || c.LLM.Type == llmOpenAI {
+		if c.LLM.URL == "" {
+			c.LLM.URL = "https://api.openai.com/v1/chat/completions"
+		}
+		if c.LLM.APIKeyEnv == "" && len(c.LLM.APIKeyCommand) == 0 {
+			c.LLM.APIKeyEnv = "OPENAI_API_KEY"
+		}
+	}
+	if c.LLM.Model == "" {
+		c.LLM.Model = defaultLLMModel(c.LLM.Type)
+	}
+}
+
+// defaultLLMModel is the model of an LLM type that doesn't set one. Ollama
+// has none, it runs whatever was pulled.
+func defaultLLMModel(t string) string {
+	if llmType(t) == llmOpenAI {
+		return "gpt-4o-mini"
+	}
+	return ""
+}
+
+// llmType returns the type of an LLM setup, which is openai when unset.
+func llmType(t string) string {
+	if t == "" {
+		return llmOpenAI
+	}
+	return t
+}
+
+func (c Config) validate() error {
+	if c.Limits.MaxInFlight < 0 || c.Limits.RequestsPerMinute < 0 {
+		return fmt.Errorf("limits can't be negative")
+	}
+	if c.Cache.MaxSizeMB < 0 {
+		return fmt.Errorf("cache.max_size_mb can't be negative")
+	}
+	if c.History.AudioMaxMB < 1 {
+		return fmt.Errorf("history.audio_max_mb must be at least 1, got %d", c.History.AudioMaxMB)
+	}
+	if c.Recording.MinFreeMB < 0 {
+		return fmt.Errorf("recording.min_free_mb can't be negative")
+	}
+	switch c.Audio.Backend {
+	case audioBackendAuto, audioBackendCoreAudio, audioBackendPortAudio:
+	default:
+		return fmt.Errorf("audio.backend must be \"auto\", \"coreaudio\" or \"portaudio\", got %q", c.Audio.Backend)
+	}
+	if c.Audio.FrameSize < 64 || c.Audio.FrameSize > 16384 {
+		return fmt.Errorf("audio.frame_size must be between 64 and 16384, got %d", c.Audio.FrameSize)
+	}
+	if hz := c.Audio.HighPassHz; hz != 0 && (hz < 20 || hz > 500) {
+		return fmt.Errorf("audio.high_pass_hz must be between 20 and 500, got %d", hz)
+	}
+	if r := c.Audio.UploadSampleRate; r != 0 && (r < 8000 || r > sampleRate) {
+		return fmt.Errorf("audio.upload_sample_rate must be between 8000 and %d, got %d", sampleRate, r)
+	}
+	if g := c.Audio.Gain; g <= 0 || g > maxGain {
+		return fmt.Errorf("audio.gain must be above 0 and at most %v, got %v", maxGain, g)
+	}
+	if l := c.Audio.SilenceLevel; l <= 0 || l >= 1 {
+		return fmt.Errorf("audio.silence_level must be between 0 and 1, got %v", l)
+	}
+	switch c.Audio.Bluetooth {
+	case bluetoothWarn, bluetoothBuiltIn, bluetoothAllow:
+	default:
+		return fmt.Errorf("audio.bluetooth must be \"warn\", \"builtin\" or \"allow\", got %q", c.Audio.Bluetooth)
+	}
+	switch c.Audio.Muted {
+	case mutedWarn, mutedUnmute, mutedAllow:
+	default:
+		return fmt.Errorf("audio.muted must be \"warn\", \"unmute\" or \"allow\", got %q", c.Audio.Muted)
+	}
+	for name, d := range c.Audio.Devices {
+		if d.Gain < 0 || d.Gain > maxGain {
+			return fmt.Errorf("audio.devices %q: gain must be above 0 and at most %v, got %v", name, maxGain, d.Gain)
+		}
+		if d.SilenceLevel < 0 || d.SilenceLevel >= 1 {
+			return fmt.Errorf("audio.devices %q: silence_level must be between 0 and 1, got %v", name, d.SilenceLevel)
+		}
+		if r := d.SampleRate; r != 0 && (r < 8000 || r > 192000) {
+			return fmt.Errorf("audio.devices %q: sample_rate must be between 8000 and 192000, got %d", name, r)
+		}
+	}
+	if c.Audio.Channels < 0 || c.Audio.Channel < 0 {
+		return fmt.Errorf("audio.channels and audio.channel can't be negative")
+	}
+	if c.Audio.Channels > 0 && c.Audio.Channel > c.Audio.Channels {
+		return fmt.Errorf("audio.channel %d is beyond audio.channels %d", c.Audio.Channel, c.Audio.Channels)
+	}
+	switch c.Audio.Latency {
+	case "", audioLatencyLow, audioLatencyHigh:
+	default:
+		if err := checkDuration("audio.latency", c.Audio.Latency); err != nil {
+			return fmt.Errorf("audio.latency must be \"low\", \"high\" or a duration such as \"20ms\", got %q", c.Audio.Latency)
+		}
+	}
+	if err := checkDuration("recording.reminder", c.Recording.Reminder); err != nil {
+		return err
+	}
+	if err := checkDuration("recording.segment_after", c.Recording.SegmentAfter); err != nil {
+		return err
+	}
+	if err := checkDuration("recording.paragraph_pause", c.Recording.ParagraphPause); err != nil {
+		return err
+	}
+	switch c.Output.Mode {
+	case insertAuto, insertType, insertPaste:
+	default:
+		return fmt.Errorf("output.mode must be \"auto\", \"type\" or \"paste\", got %q", c.Output.Mode)
+	}
+	switch c.Output.FocusChange {
+	case confirmInsert, confirmHold, confirmPreview:
+	default:
+		return fmt.Errorf("output.focus_change must be \"insert\", \"hold\" or \"preview\", got %q", c.Output.FocusChange)
+	}
+	switch c.Output.RemoteSession {
+	case confirmInsert, confirmHold, confirmPreview:
+	default:
+		return fmt.Errorf("output.remote_session must be \"insert\", \"hold\" or \"preview\", got %q", c.Output.RemoteSession)
+	}
+	if err := checkDuration("output.insert_order_wait", c.Output.InsertOrderWait); err != nil {
+		return err
+	}
+	switch c.Output.CopyLast {
+	case copyLastHistory, copyLastClipboard, copyLastOff:
+	default:
+		return fmt.Errorf("output.copy_last must be \"history\", \"clipboard\" or \"off\", got %q", c.Output.CopyLast)
+	}
+	if _, ok := trailingText[c.Output.Trailing]; !ok {
+		return fmt.Errorf("output.trailing must be \"none\", \"space\" or \"newline\", got %q", c.Output.Trailing)
+	}
+	for phrase, key := range c.Output.KeyCommands {
+		if _, err := parseKeyPress(key); err != nil {
+			return fmt.Errorf("output.key_commands.%s: %w", phrase, err)
+		}
+	}
+	for id, app := range c.Apps {
+		if _, ok := trailingText[app.Trailing]; app.Trailing != "" && !ok {
+			return fmt.Errorf("apps.%s.trailing must be \"none\", \"space\" or \"newline\", got %q", id, app.Trailing)
+		}
+		for phrase, key := range app.KeyCommands {
+			if _, err := parseKeyPress(key); key != "" && err != nil {
+				return fmt.Errorf("apps.%s.key_commands.%s: %w", id, phrase, err)
+			}
+		}
+	}
+	switch c.Profanity.Mode {
+	case profanityKeep, profanityMask, profanityRemove:
+	default:
+		return fmt.Errorf("profanity.mode must be \"keep\", \"mask\" or \"remove\", got %q", c.Profanity.Mode)
+	}
+	switch c.Hallucinations.Mode {
+	case hallucinationsOff, hallucinationsDrop, hallucinationsFlag:
+	default:
+		return fmt.Errorf("hallucinations.mode must be \"drop\", \"flag\" or \"off\", got %q", c.Hallucinations.Mode)
+	}
+	for name, t := range c.Targets {
+		switch t.Type {
+		case targetApp, targetClipboard:
+		case targetFile:
+			if t.Path == "" {
+				return fmt.Errorf("targets.%s has no path", name)
+			}
+			if err := checkTimeFields("targets."+name+".path", t.Path); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("targets.%s.type must be \"app\", \"clipboard\" or \"file\", got %q", name, t.Type)
+		}
+		if err := checkTemplate("targets."+name+".template", t.Template); err != nil {
+			return err
+		}
+	}
+	if c.Relay.MaxMB < 1 {
+		return fmt.Errorf("relay.max_mb must be at least 1, got %d", c.Relay.MaxMB)
+	}
+	for _, name := range c.Relay.Profiles {
+		if _, ok := c.Profiles[name]; !ok {
+			return fmt.Errorf("relay.profiles has unknown profile %q", name)
+		}
+	}
+	if err := c.Feedback.validate(); err != nil {
+		return err
+	}
+	switch c.Assistant.Output {
+	case assistantType, assistantPopup:
+	default:
+		return fmt.Errorf("assistant.output must be \"type\" or \"popup\", got %q", c.Assistant.Output)
+	}
+	for _, prefix := range c.Assistant.Prefixes {
+		if strings.TrimSpace(prefix) == "" {
+			return errors.New("assistant.prefixes has an empty prefix")
+		}
+	}
+	if c.Vocabulary.LearnAfter < 1 {
+		return fmt.Errorf("vocabulary.learn_after must be at least 1, got %d", c.Vocabulary.LearnAfter)
+	}
+	for heard := range c.Vocabulary.Replacements {
+		if strings.TrimSpace(heard) == "" {
+			return errors.New("vocabulary.replacements has an empty key")
+		}
+	}
+	if p := c.Budget.FallbackProfile; p != "" {
+		if _, ok := c.Profiles[p]; !ok {
+			return fmt.Errorf("budget.fallback_profile uses unknown profile %q", p)
+		}
+	}
+
+	switch c.Trigger.MIDI.Mode {
+	case "toggle", "hold":
+	default:
+		return fmt.Errorf("trigger.midi.mode must be \"toggle\" or \"hold\", got %q", c.Trigger.MIDI.Mode)
+	}
+
+	if err := c.LLM.validate(false); err != nil {
+		return err
+	}
+	if err := c.AutoBackend.validate(); err != nil {
+		return err
+	}
+	if err := checkLocale(c.Locale); err != nil {
+		return err
+	}
+	for name, p := range c.Profiles {
+		provider, ok := c.Providers[p.Provider]
+		if !ok {
+			return fmt.Errorf("profile %q uses unknown provider %q", name, p.Provider)
+		}
+		if p.LocalProvider != "" {
+			local, ok := c.Providers[p.LocalProvider]
+			switch {
+			case !ok:
+				return fmt.Errorf("profile %q uses unknown local_provider %q", name, p.LocalProvider)
+			case !local.local():
+				return fmt.Errorf("profile %q: local_provider %q isn't a local provider", name, p.LocalProvider)
+			case provider.local() || provider.Type == providerFasterWhisperWS:
+				return fmt.Errorf("profile %q: local_provider needs a cloud provider to choose from, %q isn't one", name, p.Provider)
+			case p.LocalModel == "":
+				return fmt.Errorf("profile %q has no local_model and provider %q has no default", name, p.LocalProvider)
+			}
+		}
+		switch provider.Type {
+		case "", providerAzure, providerFasterWhisperWS:
+			if provider.URL == "" {
+				return fmt.Errorf("provider %q has no url", p.Provider)
+			}
+		case providerMLXWhisper:
+		case providerSubprocess:
+			if len(provider.Command) == 0 {
+				return fmt.Errorf("provider %q has no command", p.Provider)
+			}
+		default:
+			return fmt.Errorf("provider %q has unknown type %q", p.Provider, provider.Type)
+		}
+		if _, err := provider.TLS.load(); err != nil {
+			return fmt.Errorf("provider %q: %w", p.Provider, err)
+		}
+		switch provider.APIKeySelection {
+		case "", keyFailover, keyRoundRobin:
+		default:
+			return fmt.Errorf("provider %q: api_key_selection must be \"failover\" or \"round_robin\", got %q", p.Provider, provider.APIKeySelection)
+		}
+		for i, k := range provider.APIKeys {
+			if k.APIKeyEnv == "" && len(k.APIKeyCommand) == 0 && k.APIKeyFile == "" {
+				return fmt.Errorf("provider %q: api_keys[%d] has no api_key_env, api_key_command or api_key_file", p.Provider, i)
+			}
+		}
+		if _, ok := c.Credentials[p.Credential]; p.Credential != "" && !ok {
+			return fmt.Errorf("profile %q uses unknown credential %q", name, p.Credential)
+		}
+		if p.Model == "" {
+			return fmt.Errorf("profile %q has no model and provider %q has no default", name, p.Provider)
+		}
+		switch p.Draft {
+		case "", draftInsert, draftEditor:
+		default:
+			return fmt.Errorf("profile %q: draft must be \"insert\" or \"editor\", got %q", name, p.Draft)
+		}
+		if err := c.llm(p).validate(p.Cleanup || c.Assistant.Enabled); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		if err := p.Params.validate(); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		if err := lookupModel(p.Model).check(p.Model, p.Params); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		if err := p.checkBilingual(); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		if _, ok := c.Targets[p.Target]; p.Target != "" && !ok {
+			return fmt.Errorf("profile %q uses unknown target %q", name, p.Target)
+		}
+		if err := checkTimeFields(fmt.Sprintf("profile %q: timestamp", name), p.Timestamp); err != nil {
+			return err
+		}
+		if err := checkTemplate(fmt.Sprintf("profile %q: template", name), p.Template); err != nil {
+			return err
+		}
+		if err := checkAfterInsert(p.AfterInsert); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		if p.Preset != "" {
+			if _, err := findPreset(c.presets, p.Preset); err != nil {
+				return fmt.Errorf("profile %q: %w", name, err)
+			}
+		}
+	}
+
+	for _, h := range c.Trigger.Hotkeys {
+		if _, err := parseHotkey(h); err != nil {
+			return fmt.Errorf("trigger.hotkeys: %w", err)
+		}
+		if _, ok := c.Profiles[h.Profile]; !ok {
+			return fmt.Errorf("hotkey %q uses unknown profile %q", h.Key, h.Profile)
+		}
+		if _, ok := c.Targets[h.Target]; h.Target != "" && !ok {
+			return fmt.Errorf("hotkey %q uses unknown target %q", h.Key, h.Target)
+		}
+	}
+	if q := c.Trigger.Quick; q.Enabled {
+		for _, d := range []struct{ name, value string }{{"hold", q.Hold}, {"silence", q.Silence}, {"max", q.Max}} {
+			if d.value == "" {
+				return fmt.Errorf("trigger.quick.%s must be set", d.name)
+			}
+			if err := checkDuration("trigger.quick."+d.name, d.value); err != nil {
+				return err
+			}
+		}
+		if _, ok := c.Profiles[q.Profile]; !ok {
+			return fmt.Errorf("trigger.quick uses unknown profile %q", q.Profile)
+		}
+	}
+	if c.Trigger.Quit != "" {
+		if _, err := parseChord(c.Trigger.Quit); err != nil {
+			return fmt.Errorf("trigger.quit: %w", err)
+		}
+	}
+	for i, q := range c.Trigger.QuietHours {
+		if err := q.validate(); err != nil {
+			return fmt.Errorf("trigger.quiet_hours[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkDuration checks an optional duration setting.
+func checkDuration(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		return fmt.Errorf("%s must be a duration such as \"5m\", got %q", name, value)
+	}
+	return nil
+}
+
+// checkAPIKeys makes sure the key of every provider in use is available.
+func (c Config) checkAPIKeys() error {
+	for _, p := range c.Profiles {
+		if err := c.provider(p).checkAPIKey(); err != nil {
+			return fmt.Errorf("API key of provider %q: %w", p.Provider, err)
+		}
+		if p.Cleanup || c.Assistant.Enabled {
+			llm := c.llm(p)
+			if llm.Type == llmOllama {
+				continue
+			}
+			if err := checkSecret(llm.APIKeyEnv, llm.APIKeyCommand); err != nil {
+				return fmt.Errorf("API key of the llm of profile %q: %w", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c Config) profile(name string) Profile {
+	if p, ok := c.Profiles[name]; ok {
+		return p
+	}
+	return c.Profiles[defaultProfile]
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The control socket is a Unix socket in the data directory that the
+// subcommands talking to the running instance (dashboard) and the Raycast
+// extension use. It speaks HTTP, like the Stream Deck API, but is only
+// reachable by the user.
+
+// controlStatus is the payload of the control API.
+type controlStatus struct {
+	statusSnapshot
+	// Level is the RMS input level of the current recording, 0 to 1.
+	Level     float64                   `json:"level"`
+	Providers map[string]providerHealth `json:"providers"`
+	// Queue is how many provider requests are waiting or in flight.
+	Queue int `json:"queue"`
+	// UpdateAvailable is the newer release found by the update check.
+	UpdateAvailable string `json:"update_available,omitempty"`
+	// SelectedProfile is the profile toggles start dictation with.
+	SelectedProfile string `json:"selected_profile"`
+	// Backend is the backend forced with dictation backend, "local" or
+	// "cloud", empty while profiles choose.
+	Backend string `json:"backend,omitempty"`
+	// Memory is for debugging, see dictation status -debug. Only GET
+	// /status has it; in the events it would change every time.
+	Memory *memoryStats `json:"memory,omitempty"`
+}
+
+func currentControlStatus() controlStatus {
+	level, providers := metrics.snapshot()
+	return controlStatus{
+		statusSnapshot:  status.snapshot(),
+		Level:           level,
+		Providers:       providers,
+		Queue:           int(limiter.queued.Load()),
+		UpdateAvailable: availableUpdate(),
+		SelectedProfile: triggerProfile(),
+		Backend:         currentBackendOverride(),
+	}
+}
+
+func controlSocketPath() (string, error) {
+	dir, err := runtimeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dictation.sock"), nil
+}
+
+// startControlServer serves the control API on the control socket until ctx
+// is done.
+func startControlServer(ctx context.Context) error {
+	path, err := controlSocketPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating runtime directory: %w", err)
+	}
+
+	// A socket file left behind by an instance that didn't shut down
+	// cleanly is removed, one that answers means we are running twice.
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return errors.New("another instance is already running")
+	}
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return fmt.Errorf("restricting control socket: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		s := currentControlStatus()
+		memory := metrics.sampleMemory(0)
+		s.Memory = &memory
+		writeJSON(w, http.StatusOK, s)
+	})
+	mux.HandleFunc("POST /toggle", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case toggleRequests <- "Dashboard toggle":
+		default:
+		}
+		writeJSON(w, http.StatusAccepted, currentControlStatus())
+	})
+	mux.HandleFunc("POST /preflight", func(w http.ResponseWriter, r *http.Request) {
+		preflight()
+		writeJSON(w, http.StatusOK, currentControlStatus())
+	})
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		d, err := time.ParseDuration(r.URL.Query().Get("for"))
+		if err != nil || d <= 0 {
+			http.Error(w, "for must be a duration such as 30m", http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("Dictation paused for %s\n", d)
+		pauseTriggers(d)
+		writeJSON(w, http.StatusOK, currentControlStatus())
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Println("Dictation resumed")
+		pauseTriggers(0)
+		writeJSON(w, http.StatusOK, currentControlStatus())
+	})
+	mux.HandleFunc("POST /backend", func(w http.ResponseWriter, r *http.Request) {
+		switch use := r.URL.Query().Get("use"); use {
+		case "":
+		case backendAuto, backendLocal, backendCloud:
+			fmt.Printf("Backend set to %s\n", use)
+			setBackendOverride(use)
+		default:
+			http.Error(w, "use must be auto, local or cloud", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, currentControlStatus())
+	})
+	mux.HandleFunc("GET /events", handleControlEvents)
+	registerRaycastAPI(mux)
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		os.Remove(path)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Control server stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// handleControlEvents streams the control status as server-sent events. It
+// is sampled rather than pushed, as the input level changes with every
+// buffer; unchanged statuses aren't sent again.
+func handleControlEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var last []byte
+	lastSent := time.Time{}
+	for {
+		data, err := json.Marshal(currentControlStatus())
+		if err != nil {
+			return
+		}
+		switch {
+		case string(data) != string(last):
+			fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+			last, lastSent = data, time.Now()
+			flusher.Flush()
+		case time.Since(lastSent) > 30*time.Second:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			lastSent = time.Now()
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchControlStatus asks the running instance for its status.
+func fetchControlStatus(client *http.Client) (controlStatus, error) {
+	var s controlStatus
+	resp, err := client.Get("http://dictation/status")
+	if err != nil {
+		return s, fmt.Errorf("connecting to dictation: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return s, fmt.Errorf("decoding status: %w", err)
+	}
+	return s, nil
+}
+
+// watchControlStatus follows the status of the running instance through
+// its event stream. The channel is closed when the stream ends; stop ends
+// it.
+func watchControlStatus(client *http.Client) (updates <-chan controlStatus, stop func(), err error) {
+	resp, err := client.Get("http://dictation/events")
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to dictation: %w", err)
+	}
+
+	ch := make(chan controlStatus)
+	go func() {
+		defer close(ch)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var s controlStatus
+			if json.Unmarshal([]byte(data), &s) == nil {
+				ch <- s
+			}
+		}
+	}()
+	return ch, func() { resp.Body.Close() }, nil
+}
+
+// controlClient returns an HTTP client that talks to the running instance
+// over the control socket. URLs use the host "dictation".
+func controlClient() (*http.Client, error) {
+	path, err := controlSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.New("dictation is not running (no control socket)")
+	}
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}, nil
+}
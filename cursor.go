@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// actionSelect selects the dictation that was just inserted.
+	actionSelect = "select"
+	// actionCursor moves the cursor to where {{cursor}} was in the
+	// profile's template.
+	actionCursor = "cursor"
+)
+
+// cursorMarker is what {{cursor}} puts into a template's output, a
+// character of the private use area no dictation has. cutCursor takes it
+// out again before the text goes anywhere.
+const cursorMarker = "\ue000"
+
+// maxCursorMoves is the most arrow presses after_insert makes, as moving
+// over a long dictation one character at a time takes a while.
+const maxCursorMoves = 2000
+
+// cursorMoveInterval is the pause between the key presses of after_insert,
+// as apps drop arrow presses that come too fast.
+const cursorMoveInterval = 2 * time.Millisecond
+
+// followUp is what is done in the app after a dictation was inserted.
+type followUp struct {
+	// Actions are the profile's after_insert actions.
+	Actions []string
+	// Cursor is where {{cursor}} was, in characters from the end of the
+	// text; -1 if it had none.
+	Cursor int
+	// Key is the key command said at the end of the dictation, nil if
+	// there was none.
+	Key *keyPress
+	// Submit sends the text like auto-send does, for quick mode. Terminal
+	// safe apps never get it.
+	Submit bool
+	// Recording is the recording the text is from, "" if none; aborting
+	// it cancels the insertion while it waits in the queue.
+	Recording string
+}
+
+// cutCursor takes the {{cursor}} marker out of a dictation. It returns the
+// text and where the marker was, in characters from its end, or -1.
+func cutCursor(text string) (string, int) {
+	before, after, found := strings.Cut(text, cursorMarker)
+	if !found {
+		return text, -1
+	}
+	// Only the first one counts, there is only one cursor.
+	after = strings.ReplaceAll(after, cursorMarker, "")
+	return before + after, utf8.RuneCountInString(after)
+}
+
+// checkAfterInsert reports an after_insert action that doesn't exist.
+func checkAfterInsert(actions []string) error {
+	for _, a := range actions {
+		if a == actionSelect || a == actionCursor {
+			continue
+		}
+		if _, err := parseKeyPress(a); err != nil {
+			return fmt.Errorf("after_insert: %q is neither select, cursor nor a key: %w", a, err)
+		}
+	}
+	return nil
+}
+
+// runAfterInsert runs the after_insert actions once text (the dictation)
+// and trailing (output.trailing) were inserted, with the cursor at the end
+// of them. Moves are counted in characters, which is what the arrow keys
+// move by in text fields, a line break included.
+func runAfterInsert(f followUp, text, trailing string) {
+	length, trail := utf8.RuneCountInString(text), utf8.RuneCountInString(trailing)
+	// pos is where the cursor is, in characters from the end of what was
+	// inserted.
+	pos := 0
+	tap := func(key string, modifiers ...string) {
+		injector.TapKey(key, modifiers...)
+		time.Sleep(cursorMoveInterval)
+	}
+	moveTo := func(p int) bool {
+		if n := max(p-pos, pos-p); n > maxCursorMoves {
+			fmt.Printf("Warning: not moving the cursor over %d characters\n", n)
+			return false
+		}
+		for ; pos < p; pos++ {
+			tap("left")
+		}
+		for ; pos > p; pos-- {
+			tap("right")
+		}
+		return true
+	}
+
+	for _, a := range f.Actions {
+		switch a {
+		case actionCursor:
+			if f.Cursor >= 0 {
+				moveTo(trail + f.Cursor)
+			}
+		case actionSelect:
+			if length > maxCursorMoves || !moveTo(trail) {
+				fmt.Println("Warning: the dictation is too long to select")
+				continue
+			}
+			for range length {
+				tap("left", "shift")
+			}
+			// Where the cursor ends up in a selection depends on the
+			// app; what comes after a select is best keys only.
+			pos += length
+		default:
+			// Checked with the config.
+			key, _ := parseKeyPress(a)
+			pressKeyCommand(&key)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runDashboardCommand shows the live state of the running instance, read
+// from the control socket.
+func runDashboardCommand(args []string) error {
+	client, err := controlClient()
+	if err != nil {
+		return err
+	}
+	updates, stop, err := watchControlStatus(client)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	keys := readKeys()
+	var current controlStatus
+	message := ""
+	for {
+		drawDashboard(t, current, message)
+		select {
+		case s, ok := <-updates:
+			if !ok {
+				return fmt.Errorf("dictation stopped")
+			}
+			current = s
+		case key := <-keys:
+			switch key {
+			case "q", keyEsc, keyCtrlC:
+				return nil
+			case " ", "t":
+				resp, err := client.Post("http://dictation/toggle", "", nil)
+				if err != nil {
+					message = "Toggle failed: " + err.Error()
+				} else {
+					resp.Body.Close()
+					message = ""
+				}
+			}
+		}
+	}
+}
+
+func drawDashboard(t *terminal, s controlStatus, message string) {
+	_, cols := t.size()
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	line := func(format string, args ...any) {
+		b.WriteString(truncate(fmt.Sprintf(format, args...), cols) + "\x1b[K\r\n")
+	}
+
+	state := string(s.State)
+	if s.State == stateRecording && s.RecordingSince != nil {
+		state += " " + s.elapsed()
+	}
+	if s.State == stateRateLimited && s.RetryAt != nil {
+		state += fmt.Sprintf(" (retry in %ds)", int(time.Until(*s.RetryAt).Round(time.Second).Seconds()))
+	}
+	if s.BlockedApp != "" && s.State == stateIdle {
+		state += " (blocked in " + s.BlockedApp + ")"
+	}
+	if s.DisabledUntil != nil && s.State == stateIdle {
+		state += " (" + disabledMessage(s.Disabled, *s.DisabledUntil) + ")"
+	}
+	line("\x1b[1mDictation\x1b[0m  %s", state)
+	if s.UpdateAvailable != "" {
+		line("\x1b[33mUpdate available: %s\x1b[0m", s.UpdateAvailable)
+	}
+	line("")
+
+	// The meter is logarithmic, -60 dBFS to 0 dBFS, like the ones in
+	// audio apps; speech sits in the upper half.
+	const meterWidth = 40
+	filled := 0
+	if s.Level > 0 {
+		db := 20 * math.Log10(s.Level)
+		filled = int(math.Round(meterWidth * (db + 60) / 60))
+		filled = max(0, min(meterWidth, filled))
+	}
+	line("Input  [%s%s]", strings.Repeat("█", filled), strings.Repeat(" ", meterWidth-filled))
+	line("")
+
+	line("Last transcription:")
+	for _, l := range wrap(s.LastText, cols-2) {
+		line("  %s", l)
+	}
+	if s.LastError != "" {
+		line("\x1b[31mLast error: %s\x1b[0m", s.LastError)
+	}
+	if s.AudioProblem != "" {
+		line("\x1b[31mNo audio input: %s\x1b[0m", s.AudioProblem)
+	}
+	line("")
+
+	line("\x1b[1m%-16s %8s %8s %10s %10s  %s\x1b[0m", "Provider", "Requests", "Failed", "Last", "Median", "Health")
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		h := s.Providers[name]
+		health := "\x1b[32mok\x1b[0m"
+		if h.LastError != "" {
+			health = "\x1b[31m" + h.LastError + "\x1b[0m"
+		}
+		if problem, ok := s.ProviderProblems[name]; ok {
+			health = "\x1b[31m" + problem + "\x1b[0m"
+		}
+		line("%-16s %8d %8d %8dms %8dms  %s", name, h.Requests, h.Failures, h.LastLatencyMs, h.MedianLatencyMs, health)
+	}
+	if len(names) == 0 {
+		line("\x1b[2mNo transcriptions yet.\x1b[0m")
+	}
+	line("")
+
+	if message == "" {
+		message = "space toggle dictation  q quit"
+	}
+	b.WriteString("\x1b[2m" + truncate(message, cols) + "\x1b[0m")
+	fmt.Print(b.String())
+}
@@ -0,0 +1,68 @@
+package main
+
+import "strings"
+
+// wordDiff shows the changes from a to b word by word, in the style of
+// wdiff: removed words as [-old-], added ones as {+new+}.
+func wordDiff(a, b string) string {
+	var out []string
+	wordChanges(strings.Fields(a), strings.Fields(b), func(word string) {
+		out = append(out, word)
+	}, func(removed, added []string) {
+		if len(removed) > 0 {
+			out = append(out, "[-"+strings.Join(removed, " ")+"-]")
+		}
+		if len(added) > 0 {
+			out = append(out, "{+"+strings.Join(added, " ")+"+}")
+		}
+	})
+	return strings.Join(out, " ")
+}
+
+// wordChanges walks the longest common subsequence of x and y, calling same
+// with every word they share (if set) and changed with every run of words
+// removed from x and added in y in between.
+func wordChanges(x, y []string, same func(word string), changed func(removed, added []string)) {
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var removed, added []string
+	flush := func() {
+		if len(removed) > 0 || len(added) > 0 {
+			changed(removed, added)
+		}
+		removed, added = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			flush()
+			if same != nil {
+				same(x[i])
+			}
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, x[i])
+			i++
+		default:
+			added = append(added, y[j])
+			j++
+		}
+	}
+	flush()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// wavBytesPerSecond is how much disk a second of recording takes once
+	// saved for the upload: 16 bit samples.
+	wavBytesPerSecond = sampleRate * channels * 2
+	// diskCheckInterval is how often the free space is checked while
+	// recording.
+	diskCheckInterval = 30 * time.Second
+	// diskMargin is kept free on top of what a file needs, so the rest of
+	// the system doesn't run out because of us.
+	diskMargin = 20 << 20
+)
+
+// lowDiskSpace reports whether the volume of dir has less free space than
+// recording.min_free_mb, and how much it has. When the free space can't be
+// found out it is taken to be enough.
+func lowDiskSpace(dir string) (free int64, low bool) {
+	if config.Recording.MinFreeMB <= 0 {
+		return 0, false
+	}
+	os.MkdirAll(dir, 0o700)
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		return 0, false
+	}
+	return free, free < int64(config.Recording.MinFreeMB)<<20
+}
+
+// checkDiskSpace returns an error if a file of size bytes doesn't fit on the
+// volume of dir, before anything is written, rather than failing halfway
+// through.
+func checkDiskSpace(dir string, size int64) error {
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		return nil
+	}
+	if free < size+diskMargin {
+		return fmt.Errorf("not enough disk space for %s: %s free in %s", formatBytes(size), formatBytes(free), dir)
+	}
+	return nil
+}
+
+// warnLowDiskSpace tells the user when a recording starts with the disk
+// almost full, since a long one may then not fit.
+func warnLowDiskSpace() {
+	dir, err := cacheDir()
+	if err != nil {
+		return
+	}
+	if free, low := lowDiskSpace(dir); low {
+		fmt.Printf("Warning: only %s of disk space left in %s\n", formatBytes(free), dir)
+		notify(tr("Dictation: disk almost full"), tr("Only %s left, long recordings may not fit", formatBytes(free)))
+	}
+}
+
+// recordingFits reports whether a recording of the given length still fits
+// on the disk when saved, with room to grow until the next check.
+func recordingFits(recorded time.Duration) bool {
+	dir, err := cacheDir()
+	if err != nil {
+		return true
+	}
+	need := int64((recorded + 2*diskCheckInterval).Seconds() * wavBytesPerSecond)
+	return checkDiskSpace(dir, need) == nil
+}
+
+// formatBytes formats a size in MB, or GB from 10 GB on.
+func formatBytes(n int64) string {
+	if n >= 10<<30 {
+		return fmt.Sprintf("%.0f GB", float64(n)/(1<<30))
+	}
+	return fmt.Sprintf("%.0f MB", float64(n)/(1<<20))
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// check is the outcome of one doctor check. Fix says what to do about a
+// failure.
+type check struct {
+	name string
+	ok   bool
+	info string
+	fix  string
+}
+
+// runDoctorCommand checks the things dictation needs and prints what to do
+// about the ones that are missing.
+func runDoctorCommand(args []string) error {
+	var checks []check
+	checks = append(checks, checkPermissions()...)
+	checks = append(checks, checkAudioDevice())
+	checks = append(checks, checkInjection()...)
+	checks = append(checks, checkProviders()...)
+	checks = append(checks, checkLocalModels()...)
+	checks = append(checks, checkLLMs()...)
+
+	failed := 0
+	for _, c := range checks {
+		if c.ok {
+			fmt.Printf("✓ %s: %s\n", c.name, c.info)
+			continue
+		}
+		failed++
+		fmt.Printf("✗ %s: %s\n", c.name, c.info)
+		if c.fix != "" {
+			fmt.Printf("    fix: %s\n", c.fix)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	fmt.Println("Everything looks good.")
+	return nil
+}
+
+func checkPermissions() []check {
+	mic := check{name: "microphone permission", info: microphonePermission()}
+	switch mic.info {
+	case "granted":
+		mic.ok = true
+	case "not determined":
+		mic.ok = true
+		mic.info += ", macOS will ask on the first recording"
+	default:
+		mic.fix = "allow your terminal app in System Settings > Privacy & Security > Microphone, then restart it"
+	}
+
+	access := check{name: "accessibility permission", ok: accessibilityTrusted(), info: "granted"}
+	if !access.ok {
+		access.info = "not granted, hotkeys and typing won't work"
+		access.fix = "allow your terminal app in System Settings > Privacy & Security > Accessibility (and Input Monitoring), then restart it"
+	}
+	return []check{mic, access}
+}
+
+func checkInjection() []check {
+	var checks []check
+	for _, name := range injectionCommands() {
+		c := check{name: name}
+		if path, err := exec.LookPath(name); err != nil {
+			c.info = "not installed, dictations can't be typed"
+			c.fix = "install it with your package manager"
+		} else {
+			c.ok, c.info = true, path
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+func checkAudioDevice() check {
+	c := check{name: "audio input"}
+	recorder, err := newRecorder()
+	if err != nil {
+		c.info = err.Error()
+		c.fix = "connect a microphone or pick one in System Settings > Sound > Input; with the portaudio backend, reinstall it (brew reinstall portaudio)"
+		return c
+	}
+	defer recorder.Close()
+	c.ok = true
+	c.info = recorder.Device()
+	return c
+}
+
+// checkProviders checks every provider a profile uses: cloud ones by
+// listing models with the API key of each credential they're used with, local ones by finding their worker or
+// server.
+func checkProviders() []check {
+	var checks []check
+	for _, setup := range usedProviderSetups() {
+		provider := setup.provider
+		c := check{name: "provider " + setup.name}
+		switch provider.Type {
+		case providerMLXWhisper:
+			checkMLXWhisper(&c, provider)
+		case providerSubprocess:
+			checkSubprocess(&c, provider)
+		case providerFasterWhisperWS:
+			checkServer(&c, provider)
+		default:
+			checkAPI(&c, provider)
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// checkLLMs checks the Ollama servers and models cleanup uses. OpenAI
+// compatible endpoints aren't checked, asking them costs money.
+func checkLLMs() []check {
+	var checks []check
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	seen := map[string]bool{}
+	for _, name := range names {
+		p := config.Profiles[name]
+		llm := config.llm(p)
+		key := ollamaURL(llm) + " " + llm.Model
+		if !p.Cleanup || llm.Type != llmOllama || seen[key] {
+			continue
+		}
+		seen[key] = true
+		c := check{name: "llm of profile " + name}
+		checkOllama(&c, llm)
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// localModelTimeout is how long a local worker gets to load its model and
+// answer, which may take a while the first time.
+const localModelTimeout = 2 * time.Minute
+
+// checkLocalModels has the worker of every local provider and model a
+// profile uses, also as its local_provider, transcribe a moment of silence,
+// so a missing model or a broken worker shows up here and not at the first
+// dictation.
+func checkLocalModels() []check {
+	var checks []check
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	seen := map[string]bool{}
+	for _, name := range names {
+		profiles := []Profile{config.Profiles[name]}
+		if p := profiles[0]; p.LocalProvider != "" {
+			profiles = append(profiles, p.onLocal())
+		}
+		for _, p := range profiles {
+			provider := config.Providers[p.Provider]
+			key := p.Provider + "\x00" + p.Model
+			if !provider.local() || seen[key] {
+				continue
+			}
+			seen[key] = true
+			c := check{name: fmt.Sprintf("model %s of provider %s", p.Model, p.Provider)}
+			if p.Model == "" {
+				c.name = "worker of provider " + p.Provider
+			}
+			checkLocalModel(&c, provider, p)
+			checks = append(checks, c)
+		}
+	}
+	return checks
+}
+
+func checkLocalModel(c *check, provider Provider, profile Profile) {
+	if provider.Type == providerMLXWhisper && !mlxModelPresent(profile.Model) {
+		// Loading it would download it, which can take long.
+		c.info = "not downloaded, the first dictation would download it"
+		c.fix = fmt.Sprintf("huggingface-cli download %s, or set the model to a local folder", profile.Model)
+		return
+	}
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- warmUpLocal(provider, profile) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			c.info = fmt.Sprintf("the worker failed: %v", err)
+			c.fix = "run the provider's command in a terminal to see why, and check the model name"
+			return
+		}
+	case <-time.After(localModelTimeout):
+		c.info = fmt.Sprintf("the worker didn't answer within %s", localModelTimeout)
+		c.fix = "run the provider's command in a terminal to see whether it hangs"
+		return
+	}
+	c.ok = true
+	c.info = fmt.Sprintf("loaded, answered in %s", time.Since(start).Round(time.Millisecond))
+}
+
+// mlxModelPresent reports whether an mlx_whisper model is a local folder or
+// in the Hugging Face cache, where mlx_whisper downloads models to.
+func mlxModelPresent(model string) bool {
+	if _, err := os.Stat(model); err == nil {
+		return true
+	}
+	hub := os.Getenv("HF_HUB_CACHE")
+	if hub == "" {
+		home := os.Getenv("HF_HOME")
+		if home == "" {
+			dir, err := os.UserHomeDir()
+			if err != nil {
+				return false
+			}
+			home = filepath.Join(dir, ".cache", "huggingface")
+		}
+		hub = filepath.Join(home, "hub")
+	}
+	_, err := os.Stat(filepath.Join(hub, "models--"+strings.ReplaceAll(model, "/", "--")))
+	return err == nil
+}
+
+func checkMLXWhisper(c *check, provider Provider) {
+	python := provider.workerCommand("")[0]
+	out, err := exec.Command(python, "-c", "import mlx_whisper, numpy").CombinedOutput()
+	if err != nil {
+		c.info = fmt.Sprintf("mlx_whisper can't be imported with %s: %s", python, lastLine(out, err))
+		c.fix = fmt.Sprintf("%s -m pip install mlx-whisper, or set the provider's command to the Python that has it", python)
+		return
+	}
+	c.ok = true
+	c.info = "mlx_whisper installed"
+}
+
+func checkSubprocess(c *check, provider Provider) {
+	if len(provider.Command) == 0 {
+		c.info = "no command configured"
+		c.fix = "set the provider's command"
+		return
+	}
+	path, err := exec.LookPath(provider.Command[0])
+	if err != nil {
+		c.info = fmt.Sprintf("%s not found", provider.Command[0])
+		c.fix = "install it or use the full path in the provider's command"
+		return
+	}
+	c.ok = true
+	c.info = path
+}
+
+func checkServer(c *check, provider Provider) {
+	u, err := url.Parse(provider.URL)
+	if err != nil {
+		c.info = fmt.Sprintf("invalid url: %v", err)
+		return
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "wss" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, 3*time.Second)
+	if err != nil {
+		c.info = fmt.Sprintf("can't connect to %s: %v", host, err)
+		c.fix = "start faster-whisper-server, or fix the provider url"
+		return
+	}
+	conn.Close()
+	c.ok = true
+	c.info = "server reachable at " + host
+}
+
+// checkAPI lists the provider's models, which is free, to see that the
+// network, the endpoint and the API key all work.
+func checkAPI(c *check, provider Provider) {
+	if err := provider.checkAPIKey(); err != nil {
+		c.info = err.Error()
+		c.fix = fmt.Sprintf("export %s=... in your shell profile", provider.APIKeyEnv)
+		if len(provider.APIKeyCommand) > 0 {
+			c.fix = "check that the api_key_command works in a terminal"
+		}
+		if len(provider.APIKeys) > 0 {
+			c.fix = "check the provider's api_keys: variables set, commands working, files readable"
+		}
+		return
+	}
+	modelsURL, ok := provider.modelsURL()
+	if !ok {
+		c.ok = true
+		c.info = "can't be checked (not an OpenAI style url), skipped"
+		return
+	}
+
+	req, err := http.NewRequest("GET", modelsURL, nil)
+	if err != nil {
+		c.info = err.Error()
+		return
+	}
+	provider.setAuthHeaders(req.Header)
+
+	client, err := provider.httpClient()
+	if err != nil {
+		c.info = err.Error()
+		c.fix = "check the provider's tls files"
+		return
+	}
+	client.Timeout = 10 * time.Second
+	resp, err := client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		c.info = fmt.Sprintf("can't reach %s: %v", req.URL.Host, err)
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			c.info = fmt.Sprintf("%s timed out", req.URL.Host)
+		}
+		c.fix = "check your internet connection, VPN or proxy"
+		return
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		c.ok = true
+		c.info = "API key works"
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		c.info = "API key rejected (" + resp.Status + ")"
+		c.fix = fmt.Sprintf("create a new key and put it in %s", provider.APIKeyEnv)
+		if len(provider.APIKeys) > 0 {
+			c.fix = "replace the first of the provider's api_keys"
+		}
+	default:
+		c.info = fmt.Sprintf("%s returned %s", req.URL.Host, resp.Status)
+		c.fix = "the provider may be having problems, check its status page"
+	}
+}
+
+// lastLine returns the last line of a command's output, which is where
+// Python puts the actual error, or the error itself if there is no output.
+func lastLine(out []byte, err error) string {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if last := lines[len(lines)-1]; last != "" {
+		return last
+	}
+	return err.Error()
+}
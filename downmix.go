@@ -0,0 +1,23 @@
+package main
+
+// downmix turns interleaved multi-channel audio into the mono samples the
+// rest of the program works with, writing them to out (one per frame).
+// channel picks a single channel, 1-based; 0 averages all of them.
+func downmix(out, interleaved []float32, channelCount, channel int) []float32 {
+	frames := len(interleaved) / channelCount
+	out = out[:frames]
+	if channel > 0 && channel <= channelCount {
+		for i := range frames {
+			out[i] = interleaved[i*channelCount+channel-1]
+		}
+		return out
+	}
+	for i := range frames {
+		var sum float32
+		for _, s := range interleaved[i*channelCount : (i+1)*channelCount] {
+			sum += s
+		}
+		out[i] = sum / float32(channelCount)
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// draftsDir is where openDraft saves drafts, in the data directory.
+func draftsDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "drafts"), nil
+}
+
+// openDraft saves a draft dictation to a file in the drafts folder of the
+// data directory and opens it in the system's text editor, where it can be
+// reworked before being used. The file is kept, unless privacy.history is
+// off: then it is removed once the editor is closed, or at the next start
+// where we can't tell when that is.
+func openDraft(text string) error {
+	dir, err := draftsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("creating drafts directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("draft_%s.txt", time.Now().Format("20060102_150405")))
+	if err := os.WriteFile(path, []byte(text+"\n"), 0o600); err != nil {
+		return fmt.Errorf("saving draft: %w", err)
+	}
+
+	keep := config.Privacy.History
+	var cmd *exec.Cmd
+	waits := true
+	switch runtime.GOOS {
+	case "darwin":
+		// -W waits until TextEdit quits, to know when the draft can go.
+		cmd = exec.Command("open", "-W", "-e", path)
+	case "windows":
+		cmd = exec.Command("notepad", path)
+	default:
+		// xdg-open returns as soon as it has started the editor.
+		cmd = exec.Command("xdg-open", path)
+		waits = false
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("opening editor (draft saved to %s): %w", path, err)
+	}
+	go func() {
+		cmd.Wait()
+		if !keep && waits {
+			os.Remove(path)
+		}
+	}()
+	fmt.Printf("Draft opened in the editor: %s\n", path)
+	return nil
+}
+
+// removeDrafts removes the saved drafts, for privacy.history off, which
+// keeps no dictated text on disk.
+func removeDrafts() {
+	dir, err := draftsDir()
+	if err != nil {
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Printf("Warning: removing drafts: %v\n", err)
+	}
+}
+
+// countDrafts returns the number of drafts saved, for the privacy report.
+func countDrafts() int {
+	dir, err := draftsDir()
+	if err != nil {
+		return 0
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "draft_*.txt"))
+	return len(files)
+}
@@ -0,0 +1,129 @@
+package main
+
+import "math"
+
+// audioFilter is the optional clean-up of captured audio before it is
+// transcribed: a high-pass filter against rumble, hum and the low thump of
+// plosives from desk mics, and a de-esser that tames harsh "s" sounds.
+// Both help Whisper with quiet speech, which rumble otherwise drowns.
+type audioFilter struct {
+	highPass *biquad
+	deEsser  *deEsser
+}
+
+// newAudioFilter returns the filter for the audio settings, or nil when
+// none is enabled.
+func newAudioFilter() *audioFilter {
+	f := &audioFilter{}
+	if hz := config.Audio.HighPassHz; hz > 0 {
+		f.highPass = newHighPass(sampleRate, float64(hz))
+	}
+	if config.Audio.DeEss {
+		f.deEsser = newDeEsser(sampleRate)
+	}
+	if f.highPass == nil && f.deEsser == nil {
+		return nil
+	}
+	return f
+}
+
+// process filters a buffer in place. The filter keeps its state between
+// buffers, so a recording is filtered as one continuous signal.
+func (f *audioFilter) process(buffer []float32) {
+	for i, s := range buffer {
+		x := float64(s)
+		if f.highPass != nil {
+			x = f.highPass.process(x)
+		}
+		if f.deEsser != nil {
+			x = f.deEsser.process(x)
+		}
+		buffer[i] = float32(x)
+	}
+}
+
+// applyGain multiplies a buffer by gain in place, clipping at full scale.
+func applyGain(buffer []float32, gain float64) {
+	for i, s := range buffer {
+		buffer[i] = float32(max(-1, min(1, float64(s)*gain)))
+	}
+}
+
+// biquad is a second order IIR filter (direct form I), with coefficients
+// from the RBJ audio EQ cookbook.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// newHighPass returns a Butterworth (Q 0.707) high-pass filter.
+func newHighPass(rate, cutoff float64) *biquad {
+	const q = math.Sqrt2 / 2
+	w := 2 * math.Pi * cutoff / rate
+	alpha := math.Sin(w) / (2 * q)
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	return &biquad{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+const (
+	// deEssCutoff is where the sibilance band starts.
+	deEssCutoff = 5000
+	// deEssRatio is how much of the signal's level the sibilance band may
+	// reach before it is turned down.
+	deEssRatio = 0.5
+)
+
+// deEsser is a split band de-esser: the band above deEssCutoff is turned
+// down whenever its level gets too high compared to the whole signal, which
+// is what an "s" looks like, and left alone otherwise.
+type deEsser struct {
+	band             *biquad
+	bandLevel, level float64
+	attack, release  float64
+}
+
+func newDeEsser(rate float64) *deEsser {
+	return &deEsser{
+		band: newHighPass(rate, deEssCutoff),
+		// Envelope followers: fast attack (1ms) to catch the start of an
+		// "s", slower release (50ms) so it doesn't flutter.
+		attack:  1 - math.Exp(-1/(0.001*rate)),
+		release: 1 - math.Exp(-1/(0.050*rate)),
+	}
+}
+
+func (d *deEsser) process(x float64) float64 {
+	sibilance := d.band.process(x)
+	d.bandLevel = follow(d.bandLevel, math.Abs(sibilance), d.attack, d.release)
+	d.level = follow(d.level, math.Abs(x), d.attack, d.release)
+
+	limit := deEssRatio * d.level
+	if d.bandLevel <= limit || d.bandLevel == 0 {
+		return x
+	}
+	gain := limit / d.bandLevel
+	return x - sibilance + sibilance*gain
+}
+
+// follow moves an envelope toward the input level, quickly when it rises
+// and slowly when it falls.
+func follow(env, in, attack, release float64) float64 {
+	if in > env {
+		return env + attack*(in-env)
+	}
+	return env + release*(in-env)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+)
+
+// Encoder writes recorded audio, mono float32 samples from -1 to 1, as a
+// file a provider accepts. WAV is the only format so far; a FLAC or Opus
+// encoder plugs in as uploadEncoder.
+type Encoder interface {
+	// Ext is the extension of the files it writes, with the dot.
+	Ext() string
+	// Encode writes samples recorded at rate to w.
+	Encode(w io.WriteSeeker, samples []float32, rate int, meta audioMetadata) error
+	// Size is how big n samples at rate get at most, without metadata,
+	// for the disk space check and the provider's upload limit.
+	Size(n, rate int) int64
+}
+
+// audioMetadata is what encoders embed in the files they write, where the
+// format has a place for it.
+type audioMetadata struct {
+	Created time.Time
+	// Device is the input device it was recorded with, "" if unknown.
+	Device string
+}
+
+// recordingMetadata is the metadata of a recording that just ended: it
+// started as long ago as it lasts, on the input device of the status.
+func recordingMetadata(samples []float32) audioMetadata {
+	return audioMetadata{
+		Created: time.Now().Add(-time.Duration(len(samples)) * time.Second / sampleRate),
+		Device:  status.snapshot().Device,
+	}
+}
+
+// uploadEncoder encodes the recordings uploaded to providers and handed to
+// local workers.
+var uploadEncoder Encoder = wavEncoder{dither: true}
+
+// wavEncoder writes 16 bit mono WAV files, with the metadata in a LIST INFO
+// chunk after the audio.
+type wavEncoder struct {
+	// dither adds triangular noise of one step before rounding, so quiet
+	// audio doesn't turn into distortion in 16 bits.
+	dither bool
+}
+
+func (wavEncoder) Ext() string { return ".wav" }
+
+func (wavEncoder) Size(n, rate int) int64 {
+	return 44 + 2*int64(n)
+}
+
+func (e wavEncoder) Encode(w io.WriteSeeker, samples []float32, rate int, meta audioMetadata) error {
+	ints := make([]int, len(samples))
+	for i, sample := range samples {
+		v := float64(sample) * math.MaxInt16
+		if e.dither {
+			v += rand.Float64() - rand.Float64()
+		}
+		// Clipped samples (gain, a hot input) are kept at full scale
+		// instead of wrapping around.
+		ints[i] = int(max(math.MinInt16, min(math.MaxInt16, math.Round(v))))
+	}
+
+	encoder := wav.NewEncoder(w, rate, 16, channels, 1)
+	encoder.Metadata = &wav.Metadata{
+		Software:     riffText("dictation " + version),
+		CreationDate: riffText(meta.Created.Format("2006-01-02")),
+		Comments:     riffText(wavComment(meta)),
+	}
+	buffer := &audio.IntBuffer{
+		Format:         &audio.Format{NumChannels: channels, SampleRate: rate},
+		Data:           ints,
+		SourceBitDepth: 16,
+	}
+	if err := encoder.Write(buffer); err != nil {
+		return fmt.Errorf("encoding WAV: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("encoding WAV: %w", err)
+	}
+	return nil
+}
+
+// wavComment describes the recording in the comment field, which is where
+// players show it: the time (INFO has no field for it) and the device.
+func wavComment(meta audioMetadata) string {
+	comment := "Recorded " + meta.Created.Format(time.RFC3339)
+	if meta.Device != "" {
+		comment += " with " + meta.Device
+	}
+	return comment
+}
+
+// riffText pads an INFO value to an even length with its terminating zero,
+// which RIFF chunks need and the wav package doesn't do itself.
+func riffText(s string) string {
+	if len(s)%2 == 0 {
+		s += " "
+	}
+	return s
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// envPrefix starts the environment variables that override settings, e.g.
+// DICTATION_AUDIO_GAIN for audio.gain.
+const envPrefix = "DICTATION_"
+
+// envReserved are DICTATION_ variables that aren't settings.
+var envReserved = map[string]bool{"DICTATION_CONFIG": true}
+
+// applyEnvOverrides sets the settings given in DICTATION_* environment
+// variables in raw config JSON, so a service or container can be configured
+// without a file. The name is the path of the setting in upper case with _
+// between the keys: DICTATION_OUTPUT_MODE, DICTATION_PROFILES_WORK_MODEL.
+// Entries of maps such as profiles are found by their name in the config,
+// or else taken to be the next word. Lists are comma separated or JSON,
+// objects JSON.
+func applyEnvOverrides(raw map[string]any) error {
+	var defaults map[string]any
+	if data, err := json.Marshal(defaultConfig()); err == nil {
+		json.Unmarshal(data, &defaults)
+	}
+	referenced := map[string]bool{}
+	envReferences(raw, referenced)
+
+	env := os.Environ()
+	slices.Sort(env) // the same order every time, for settings set twice
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, envPrefix) || envReserved[name] || referenced[name] {
+			continue
+		}
+		path, t, ok := envPath(strings.TrimPrefix(name, envPrefix), reflect.TypeOf(Config{}), []map[string]any{raw, defaults})
+		if !ok {
+			fmt.Printf("Warning: %s isn't a setting, ignoring it\n", name)
+			continue
+		}
+		v, err := envValue(value, t)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		section := raw
+		for _, key := range path[:len(path)-1] {
+			section = configSection(section, key)
+		}
+		section[path[len(path)-1]] = v
+	}
+	return nil
+}
+
+// envReferences collects the variables named by the *_env settings (API
+// keys), which are for the setting, not settings themselves.
+func envReferences(raw map[string]any, names map[string]bool) {
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			if strings.HasSuffix(key, "_env") {
+				names[v] = true
+			}
+		case map[string]any:
+			envReferences(v, names)
+		case []any:
+			for _, item := range v {
+				if m, ok := item.(map[string]any); ok {
+					envReferences(m, names)
+				}
+			}
+		}
+	}
+}
+
+// envField is a key that can come next in a setting's path.
+type envField struct {
+	key string
+	t   reflect.Type
+}
+
+// envPath finds the setting an environment variable name (without the
+// prefix) stands for in type t, trying longer keys first so
+// DICTATION_AUDIO_FRAME_SIZE is audio.frame_size and not audio.frame.size.
+// known holds the raw config below t, for the names of map entries. It
+// returns the JSON path and the type of the setting.
+func envPath(rest string, t reflect.Type, known []map[string]any) ([]string, reflect.Type, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if rest == "" {
+		return nil, t, true
+	}
+
+	var candidates []envField
+	switch {
+	case t.Kind() == reflect.Struct:
+		candidates = jsonFields(t)
+	case t.Kind() == reflect.Map && isStruct(t.Elem()):
+		for _, m := range known {
+			for key := range m {
+				candidates = append(candidates, envField{key, t.Elem()})
+			}
+		}
+		word, _, _ := strings.Cut(rest, "_")
+		candidates = append(candidates, envField{strings.ToLower(word), t.Elem()})
+	default:
+		return nil, nil, false
+	}
+	slices.SortStableFunc(candidates, func(a, b envField) int { return len(b.key) - len(a.key) })
+
+	for _, c := range candidates {
+		upper := envName(c.key)
+		after, ok := strings.CutPrefix(rest, upper)
+		if !ok || (after != "" && after[0] != '_') {
+			continue
+		}
+		var below []map[string]any
+		for _, m := range known {
+			if sub, ok := m[c.key].(map[string]any); ok {
+				below = append(below, sub)
+			}
+		}
+		if path, leaf, ok := envPath(strings.TrimPrefix(after, "_"), c.t, below); ok {
+			return append([]string{c.key}, path...), leaf, true
+		}
+	}
+	return nil, nil, false
+}
+
+// jsonFields returns the settings of a config struct. Fields flattened into
+// it with a custom decoder (json:"-", like Profile.Params) are included.
+func jsonFields(t reflect.Type) []envField {
+	var fields []envField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-" && f.Type.Kind() == reflect.Struct:
+			fields = append(fields, jsonFields(f.Type)...)
+		case name != "" && name != "-":
+			fields = append(fields, envField{name, f.Type})
+		}
+	}
+	return fields
+}
+
+func isStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// envName turns a key into its part of a variable name: upper case, with
+// anything but letters and digits as _, so the app com.apple.Terminal is
+// COM_APPLE_TERMINAL.
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, key)
+}
+
+// envValue parses the value of a variable as JSON of the setting's type.
+func envValue(value string, t reflect.Type) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("want true or false, got %q", value)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("want a whole number, got %q", value)
+		}
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("want a number, got %q", value)
+		}
+		return f, nil
+	case reflect.Slice:
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			break
+		}
+		list := []any{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			v, err := envValue(item, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return nil, fmt.Errorf("want JSON: %w", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// transcriptionEvent is one line of the event log, written for every
+// transcription when event_log is on. Unlike the history it has no text,
+// only numbers, for analysis and personal dashboards.
+type transcriptionEvent struct {
+	Time        time.Time `json:"time"`
+	RecordingID string    `json:"recording_id,omitempty"`
+	// File is set instead of RecordingID for transcribed files.
+	File     string `json:"file,omitempty"`
+	Profile  string `json:"profile"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// AudioSeconds is the length of the audio, LatencyMs the time from the
+	// end of the recording to the text.
+	AudioSeconds float64 `json:"audio_seconds"`
+	LatencyMs    int64   `json:"latency_ms"`
+	Chars        int     `json:"chars"`
+	// App is the app that had focus when the text was ready.
+	App   string `json:"app,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+var eventLogMu sync.Mutex
+
+// logTranscription appends an event for a transcription that produced text
+// or failed with err.
+func logTranscription(e transcriptionEvent, profile Profile, audio, latency time.Duration, text string, err error) {
+	if !config.EventLog {
+		return
+	}
+	e.Time = time.Now()
+	e.Profile, e.Provider, e.Model = profile.Name, profile.Provider, profile.Model
+	e.AudioSeconds = audio.Seconds()
+	e.LatencyMs = latency.Milliseconds()
+	e.Chars = len([]rune(text))
+	if e.File == "" && config.Privacy.AppContext {
+		e.App = frontmostApp()
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	if err := appendEvent(e); err != nil {
+		fmt.Printf("Warning: failed to write event log: %v\n", err)
+	}
+}
+
+func eventLogPath() (string, error) {
+	dir, err := logDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "events.jsonl"), nil
+}
+
+func appendEvent(e transcriptionEvent) error {
+	eventLogMu.Lock()
+	defer eventLogMu.Unlock()
+
+	path, err := eventLogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening event log: %w", err)
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing event log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Dictation events that get feedback cues.
+const (
+	cueStart    = "start"    // recording started
+	cueStop     = "stop"     // recording stopped
+	cueDone     = "done"     // text delivered
+	cueError    = "error"    // the dictation failed
+	cueReminder = "reminder" // still recording, see recording.reminder
+)
+
+var cueEvents = []string{cueStart, cueStop, cueDone, cueError, cueReminder}
+
+// feedback.visual settings.
+const (
+	visualOff          = "off"
+	visualFlash        = "flash"
+	visualNotification = "notification"
+)
+
+// FeedbackConfig controls the cues dictation gives besides its output, so
+// every sound has a visual or haptic counterpart for those who can't hear
+// it.
+type FeedbackConfig struct {
+	// Sounds are sound files played on events, by event name.
+	Sounds map[string]string `json:"sounds"`
+	// Visual is "off" (the default), "flash" to tint the screen briefly
+	// (macOS, a notification elsewhere) or "notification".
+	Visual string `json:"visual"`
+	// Haptic taps the Force Touch trackpad on events, macOS only.
+	Haptic bool `json:"haptic"`
+}
+
+// sound returns the sound file of an event. The reminder falls back to
+// recording.reminder_sound.
+func (f FeedbackConfig) sound(event string) string {
+	if s := f.Sounds[event]; s != "" {
+		return s
+	}
+	if event == cueReminder {
+		return config.Recording.ReminderSound
+	}
+	return ""
+}
+
+// cuePattern is how an event looks and feels: the screen tint, as the
+// brightest each channel gets, and how many flashes and taps.
+type cuePattern struct {
+	red, green, blue float32
+	count            int
+	message          string
+}
+
+var cuePatterns = map[string]cuePattern{
+	cueStart:    {1, 0.45, 0.45, 1, "Recording"},
+	cueStop:     {1, 0.8, 0.4, 1, "Transcribing"},
+	cueDone:     {0.5, 1, 0.5, 1, "Inserted"},
+	cueError:    {1, 0.3, 0.3, 3, "Dictation failed"},
+	cueReminder: {1, 0.45, 0.45, 2, "Still recording"},
+}
+
+// flashTime is how long one flash tints the screen.
+const flashTime = 120 * time.Millisecond
+
+// playingCue keeps cues from overlapping, a flash restores the screen
+// another one just tinted.
+var playingCue sync.Mutex
+
+// cue gives the feedback of an event: its sound, and the visual and haptic
+// cue configured for all events. It doesn't wait for them.
+func cue(event string) {
+	f := config.Feedback
+	if sound := f.sound(event); sound != "" {
+		playSound(sound)
+	}
+	if f.Visual == visualOff && !f.Haptic {
+		return
+	}
+	p := cuePatterns[event]
+	go func() {
+		playingCue.Lock()
+		defer playingCue.Unlock()
+		for i := range p.count {
+			if i > 0 {
+				time.Sleep(flashTime)
+			}
+			if f.Haptic {
+				hapticTap()
+			}
+			if f.Visual == visualFlash {
+				if !flashScreen(p.red, p.green, p.blue, flashTime) {
+					notify(tr("Dictation"), tr(p.message))
+					return
+				}
+			}
+		}
+		if f.Visual == visualNotification {
+			notify(tr("Dictation"), tr(p.message))
+		}
+	}()
+}
+
+func (f FeedbackConfig) validate() error {
+	for event := range f.Sounds {
+		if _, ok := cuePatterns[event]; !ok {
+			return fmt.Errorf("feedback.sounds has unknown event %q, use start, stop, done, error or reminder", event)
+		}
+	}
+	switch f.Visual {
+	case visualOff, visualFlash, visualNotification:
+	default:
+		return fmt.Errorf("feedback.visual must be \"off\", \"flash\" or \"notification\", got %q", f.Visual)
+	}
+	return nil
+}
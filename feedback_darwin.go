@@ -0,0 +1,51 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework ApplicationServices -framework AppKit
+#import <AppKit/AppKit.h>
+#import <ApplicationServices/ApplicationServices.h>
+
+// tintDisplays limits the brightest value of each color channel on all
+// displays, which tints the whole screen without a window.
+static int tintDisplays(float red, float green, float blue) {
+	CGDirectDisplayID displays[16];
+	uint32_t n = 0;
+	if (CGGetActiveDisplayList(16, displays, &n) != kCGErrorSuccess || n == 0) {
+		return 0;
+	}
+	for (uint32_t i = 0; i < n; i++) {
+		CGSetDisplayTransferByFormula(displays[i], 0, red, 1, 0, green, 1, 0, blue, 1);
+	}
+	return 1;
+}
+
+static void hapticGeneric(void) {
+	@autoreleasepool {
+		[[NSHapticFeedbackManager defaultPerformer] performFeedbackPattern:NSHapticFeedbackPatternGeneric
+			performanceTime:NSHapticFeedbackPerformanceTimeNow];
+	}
+}
+*/
+import "C"
+
+import "time"
+
+// flashScreen tints the screen for d. The displays' own color settings come
+// back afterwards, and when the process exits in between.
+func flashScreen(red, green, blue float32, d time.Duration) bool {
+	if C.tintDisplays(C.float(red), C.float(green), C.float(blue)) == 0 {
+		return false
+	}
+	time.Sleep(d)
+	C.CGDisplayRestoreColorSyncSettings()
+	return true
+}
+
+// hapticTap taps the Force Touch trackpad, which is only felt while a
+// finger rests on it.
+func hapticTap() {
+	C.hapticGeneric()
+}
@@ -0,0 +1,14 @@
+//go:build !darwin
+
+package main
+
+import "time"
+
+// Screen tints and trackpad taps need macOS; flashes fall back to
+// notifications elsewhere.
+
+func flashScreen(red, green, blue float32, d time.Duration) bool {
+	return false
+}
+
+func hapticTap() {}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// fillerWords are hesitation sounds, removed wherever they appear along
+	// with the comma Whisper usually puts after them. The one before them
+	// belongs to what precedes: "well, um, I think" is "well, I think".
+	fillerWords = regexp.MustCompile(`(?i)\b(u+m+|u+h+|erm|er|a+h+|hmm+|mm+)\b,?`)
+	// strandedComma is a comma left before the end of a sentence by a
+	// removed filler, "I think, um." or "I think, um".
+	strandedComma = regexp.MustCompile(`,(\s*(?:[.!?]|$))`)
+	// fillerPhrases are only fillers when set off by commas; "I like it"
+	// keeps its "like".
+	fillerPhrases = regexp.MustCompile(`(?i),\s*(like|you know|I mean|sort of|kind of),`)
+	// leadingFillers are fillers opening a sentence, "Like, ..." or "So, like, ...".
+	leadingFillers = regexp.MustCompile(`(?i)(^|[.!?]\s+)(so,\s*)?(like|you know|I mean),\s*`)
+)
+
+// removeDisfluencies strips filler words, repeated words and phrases ("the
+// the", "I think I think") and false starts ("I wa- I want"), with local
+// heuristics only.
+func removeDisfluencies(text string) string {
+	capitalized := !startsLower(text)
+
+	if fillerWords.MatchString(text) {
+		text = fillerWords.ReplaceAllString(text, " ")
+		text = strandedComma.ReplaceAllString(tidySpaces(text), "$1")
+	}
+	text = tidySpaces(text)
+	text = leadingFillers.ReplaceAllString(text, "$1")
+	text = fillerPhrases.ReplaceAllString(text, ",")
+	text = falseStart.ReplaceAllString(text, "")
+	text = removeRepetitions(text)
+	text = tidySpaces(text)
+	return capitalizeSentences(text, capitalized)
+}
+
+// falseStart is a word cut off with a hyphen, which Whisper writes for
+// "I wa- I want". Hyphens in compound words aren't followed by a space.
+var falseStart = regexp.MustCompile(`\b[\p{L}']+-\s+`)
+
+// maxRepeatedPhrase is the longest phrase, in words, whose immediate repeat
+// is removed.
+const maxRepeatedPhrase = 3
+
+func removeRepetitions(text string) string {
+	words := strings.Fields(text)
+	var out []string
+
+	for i := 0; i < len(words); {
+		skipped := false
+		for n := maxRepeatedPhrase; n >= 1; n-- {
+			if i+2*n > len(words) {
+				continue
+			}
+			if samePhrase(words[i:i+n], words[i+n:i+2*n]) {
+				i += n // drop the first occurrence, keep the one with its punctuation
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			out = append(out, words[i])
+			i++
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// samePhrase compares words ignoring case and trailing commas, but not
+// other punctuation: "no. No" ends a sentence and is kept.
+func samePhrase(a, b []string) bool {
+	for i := range a {
+		x := strings.TrimRight(a[i], ",")
+		y := strings.TrimRight(b[i], ",")
+		if !strings.EqualFold(x, y) || strings.ContainsAny(x, ".!?") {
+			return false
+		}
+	}
+	return true
+}
+
+// capitalizeSentences upper cases the first letter of each sentence, which
+// may have become a lower case word after removing what preceded it. The
+// very first letter is only changed if first is set, the text could be the
+// continuation of an earlier dictation.
+func capitalizeSentences(text string, first bool) string {
+	var b strings.Builder
+	upper := first
+	for _, r := range text {
+		if upper && unicode.IsLetter(r) {
+			r = unicode.ToUpper(r)
+			upper = false
+		} else if !unicode.IsSpace(r) {
+			upper = false
+		}
+		if r == '.' || r == '!' || r == '?' {
+			upper = true
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// startsLower reports whether text starts with a lower case letter.
+func startsLower(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsLower(r)
+}
@@ -0,0 +1,69 @@
+package main
+
+import "fmt"
+
+// output.focus_change and output.remote_session settings: what happens to
+// a dictation that shouldn't just be typed where the focus is.
+const (
+	confirmInsert  = "insert"
+	confirmHold    = "hold"
+	confirmPreview = "preview"
+)
+
+// stopTarget returns the app the text of a recording that just stopped is
+// meant for, the focused one, or "" when focus changes are let through.
+func stopTarget() string {
+	if config.Output.FocusChange == confirmInsert {
+		return ""
+	}
+	return frontmostApp()
+}
+
+// focusMoved reports whether another app than target has focus now. Without
+// a target, or when the focused app can't be told, it hasn't.
+func focusMoved(target string) bool {
+	if target == "" {
+		return false
+	}
+	now := frontmostApp()
+	return now != "" && now != target
+}
+
+// handleFocusChange deals with a transcription that is ready after focus
+// moved away from the app it was dictated for, rather than typing it into
+// whatever app has focus now.
+func handleFocusChange(text, target string) {
+	now := frontmostApp()
+	fmt.Printf("Focus moved from %s to %s since the recording stopped\n", target, now)
+
+	confirmText(config.Output.FocusChange, text, tr("Dictation: focus changed"),
+		tr("Focus moved to another app; the text is kept for the reinsert hotkey: %s", truncate(logged(text), 80)))
+}
+
+// confirmText handles a dictation that needs confirming per mode: with
+// confirmPreview it is shown in a preview titled title to insert or copy,
+// otherwise, or where there are no previews, it is held for the reinsert
+// hotkey and held is the notification saying so.
+func confirmText(mode, text, title, held string) {
+	if mode == confirmPreview {
+		switch previewText(title, text) {
+		case previewInsert:
+			insertText(text)
+			return
+		case previewCopy:
+			if err := injector.WriteClipboard(text); err != nil {
+				fmt.Printf("Error: copying failed: %v\n", err)
+			}
+			return
+		case previewUnavailable:
+			// Fall back to holding the text.
+		default:
+			fmt.Println("Not inserting, the text is kept for the reinsert hotkey")
+			return
+		}
+	}
+
+	// The text is the last transcription now, which is what reinsert types.
+	fmt.Println("Not inserting, press the reinsert hotkey to type it")
+	notify(tr("Dictation not inserted"), held)
+}
@@ -0,0 +1,49 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework ApplicationServices -framework Foundation
+#import <Foundation/Foundation.h>
+#import <ApplicationServices/ApplicationServices.h>
+#include <stdlib.h>
+#include <string.h>
+
+// focusedElementValue asks the accessibility API for the text of the focused
+// text field or area. It returns NULL or a string the caller frees.
+static char *focusedElementValue(void) {
+	@autoreleasepool {
+		AXUIElementRef system = AXUIElementCreateSystemWide();
+		CFTypeRef element = NULL, value = NULL;
+		char *result = NULL;
+		if (AXUIElementCopyAttributeValue(system, kAXFocusedUIElementAttribute, &element) == kAXErrorSuccess &&
+			AXUIElementCopyAttributeValue((AXUIElementRef)element, kAXValueAttribute, &value) == kAXErrorSuccess &&
+			CFGetTypeID(value) == CFStringGetTypeID()) {
+			const char *utf8 = [(NSString *)value UTF8String];
+			if (utf8 != NULL) {
+				result = strdup(utf8);
+			}
+		}
+		if (value != NULL) CFRelease(value);
+		if (element != NULL) CFRelease(element);
+		CFRelease(system);
+		return result;
+	}
+}
+*/
+import "C"
+
+import "unsafe"
+
+// focusedText returns the text of the focused text field, if the app exposes
+// it to the accessibility API (most native ones do, some Electron apps and
+// terminals don't).
+func focusedText() (string, bool) {
+	value := C.focusedElementValue()
+	if value == nil {
+		return "", false
+	}
+	defer C.free(unsafe.Pointer(value))
+	return C.GoString(value), true
+}
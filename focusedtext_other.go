@@ -0,0 +1,10 @@
+//go:build !darwin
+
+package main
+
+// Reading the focused text field needs the macOS accessibility API, so edits
+// aren't watched elsewhere.
+
+func focusedText() (string, bool) {
+	return "", false
+}
@@ -0,0 +1,71 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework ApplicationServices -framework Foundation
+#import <Foundation/Foundation.h>
+#import <ApplicationServices/ApplicationServices.h>
+#include <stdlib.h>
+#include <string.h>
+
+// focusedWindowTitle asks the accessibility API for the title of the
+// focused window. It returns NULL or a title the caller frees.
+static char *focusedWindowTitle(void) {
+	@autoreleasepool {
+		AXUIElementRef system = AXUIElementCreateSystemWide();
+		CFTypeRef app = NULL, window = NULL, title = NULL;
+		char *result = NULL;
+		if (AXUIElementCopyAttributeValue(system, kAXFocusedApplicationAttribute, &app) == kAXErrorSuccess &&
+			AXUIElementCopyAttributeValue((AXUIElementRef)app, kAXFocusedWindowAttribute, &window) == kAXErrorSuccess &&
+			AXUIElementCopyAttributeValue((AXUIElementRef)window, kAXTitleAttribute, &title) == kAXErrorSuccess &&
+			CFGetTypeID(title) == CFStringGetTypeID()) {
+			result = strdup([(NSString *)title UTF8String]);
+		}
+		if (title != NULL) CFRelease(title);
+		if (window != NULL) CFRelease(window);
+		if (app != NULL) CFRelease(app);
+		CFRelease(system);
+		return result;
+	}
+}
+*/
+import "C"
+
+import (
+	"os/exec"
+	"strings"
+	"unsafe"
+)
+
+// frontmostApp returns the bundle ID of the app that has keyboard focus, or
+// "" if it can't be determined. lsappinfo ships with macOS and, unlike
+// AppleScript, needs no automation permission.
+func frontmostApp() string {
+	asn, err := exec.Command("lsappinfo", "front").Output()
+	if err != nil {
+		return ""
+	}
+	out, err := exec.Command("lsappinfo", "info", "-only", "bundleid", strings.TrimSpace(string(asn))).Output()
+	if err != nil {
+		return ""
+	}
+
+	// The output looks like "CFBundleIdentifier"="com.apple.Terminal".
+	_, value, ok := strings.Cut(strings.TrimSpace(string(out)), "=")
+	if !ok {
+		return ""
+	}
+	return strings.Trim(value, `"`)
+}
+
+// windowTitle returns the title of the focused window, or "".
+func windowTitle() string {
+	title := C.focusedWindowTitle()
+	if title == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(title))
+	return C.GoString(title)
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// frontmostApp returns the window class of the focused window on X11, or ""
+// on Wayland, which doesn't let clients find out.
+func frontmostApp() string {
+	return xdotoolActiveWindow("getwindowclassname")
+}
+
+func windowTitle() string {
+	return xdotoolActiveWindow("getwindowname")
+}
+
+func xdotoolActiveWindow(command string) string {
+	if wayland() {
+		return ""
+	}
+	out, err := exec.Command("xdotool", "getactivewindow", command).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
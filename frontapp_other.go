@@ -0,0 +1,11 @@
+//go:build !darwin && !linux && !windows
+
+package main
+
+func frontmostApp() string {
+	return ""
+}
+
+func windowTitle() string {
+	return ""
+}
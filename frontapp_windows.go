@@ -0,0 +1,43 @@
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// frontmostApp returns the executable name of the foreground window's
+// process, e.g. "WindowsTerminal.exe", or "".
+func frontmostApp() string {
+	hwnd, _, _ := procGetForegroundWindow.Call()
+	if hwnd == 0 {
+		return ""
+	}
+	var pid uint32
+	procGetWindowThreadPID.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+
+	process, _, _ := procOpenProcess.Call(processQueryLimitedInformation, 0, uintptr(pid))
+	if process == 0 {
+		return ""
+	}
+	defer procCloseHandle.Call(process)
+
+	buf := make([]uint16, syscall.MAX_PATH)
+	size := uint32(len(buf))
+	if ok, _, _ := procQueryFullProcessImageNameW.Call(process, 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); ok == 0 {
+		return ""
+	}
+	return filepath.Base(syscall.UTF16ToString(buf[:size]))
+}
+
+func windowTitle() string {
+	hwnd, _, _ := procGetForegroundWindow.Call()
+	if hwnd == 0 {
+		return ""
+	}
+	buf := make([]uint16, 512)
+	n, _, _ := procGetWindowTextW.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	return syscall.UTF16ToString(buf[:n])
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	hallucinationsOff  = "off"
+	hallucinationsDrop = "drop"
+	hallucinationsFlag = "flag"
+)
+
+// builtinHallucinations are what Whisper is known to make up out of
+// silence and noise, learned from the subtitles of videos it was trained
+// on. Only phrases nobody dictates are listed; "Thank you." alone is a
+// common one too, but also something people say.
+var builtinHallucinations = []string{
+	"thank you for watching",
+	"thanks for watching",
+	"thank you so much for watching",
+	"please subscribe",
+	"please like and subscribe",
+	"don't forget to like and subscribe",
+	"subtitles by the amara.org community",
+	"transcription by castingwords",
+	"sous-titres réalisés par la communauté d'amara.org",
+	"untertitel der amara.org-community",
+	"ご視聴ありがとうございました",
+}
+
+// HallucinationConfig is the list of phrases that are never typed: Whisper
+// hallucinations and the user's own.
+type HallucinationConfig struct {
+	// Mode is "drop" (the default) to take the phrases out of dictations,
+	// "flag" to ask before typing a dictation with one or "off".
+	Mode string `json:"mode"`
+	// Phrases are matched on top of the built-in ones, as whole words
+	// anywhere in a dictation, ignoring case and the punctuation around
+	// them. Built-in ones only count as the whole dictation or its last
+	// sentence.
+	Phrases []string `json:"phrases"`
+	// BuiltIn uses builtinHallucinations, on by default.
+	BuiltIn bool `json:"builtin"`
+}
+
+// hallucinationPatterns are the compiled phrases: the user's, found
+// anywhere, and the built-in ones, which Whisper makes up as the whole
+// output or its last sentence and are only found there, so "thanks for
+// watching the kids" stays.
+type hallucinationPatterns struct {
+	phrases *regexp.Regexp
+	builtin *regexp.Regexp
+}
+
+var (
+	hallucinationOnce    sync.Once
+	hallucinationPattern hallucinationPatterns
+)
+
+// compileHallucinations builds the patterns of phrases. The punctuation
+// after a phrase goes with it. The built-in pattern's second group is the
+// phrases, the first what came before them.
+func compileHallucinations(cfg HallucinationConfig) hallucinationPatterns {
+	var h hallucinationPatterns
+	if alternatives := phraseAlternatives(cfg.Phrases); alternatives != "" {
+		h.phrases = regexp.MustCompile(`(?i)(?:` + alternatives + `)[.!?,。！]*`)
+	}
+	if !cfg.BuiltIn {
+		return h
+	}
+	if alternatives := phraseAlternatives(builtinHallucinations); alternatives != "" {
+		h.builtin = regexp.MustCompile(`(?i)(^\s*|[.!?。！]\s*)((?:(?:` + alternatives + `)[.!?,。！]*\s*)+)$`)
+	}
+	return h
+}
+
+func phraseAlternatives(phrases []string) string {
+	var alternatives []string
+	for _, p := range phrases {
+		p = strings.TrimFunc(p, func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSpace(r) })
+		if p == "" {
+			continue
+		}
+		alternatives = append(alternatives, phrasePattern(p))
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// phrasePattern matches a phrase as whole words. Word boundaries only go
+// where the phrase starts or ends with an ASCII letter or digit, as \b
+// doesn't work with phrases in Japanese and the like, or that start or
+// end with punctuation.
+func phrasePattern(p string) string {
+	re := regexp.QuoteMeta(p)
+	if p == "" {
+		return re
+	}
+	if first := p[0]; first < 0x80 && isWordByte(first) {
+		re = `\b` + re
+	}
+	if last := p[len(p)-1]; last < 0x80 && isWordByte(last) {
+		re += `\b`
+	}
+	return re
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func hallucinations() hallucinationPatterns {
+	hallucinationOnce.Do(func() { hallucinationPattern = compileHallucinations(config.Hallucinations) })
+	return hallucinationPattern
+}
+
+// findHallucinations returns the listed phrases in a dictation.
+func findHallucinations(text string) []string {
+	if config.Hallucinations.Mode == hallucinationsOff {
+		return nil
+	}
+	h := hallucinations()
+	var found []string
+	if h.phrases != nil {
+		found = h.phrases.FindAllString(text, -1)
+	}
+	if h.builtin != nil {
+		if m := h.builtin.FindStringSubmatch(text); m != nil {
+			found = append(found, strings.TrimSpace(m[2]))
+		}
+	}
+	return found
+}
+
+// dropHallucinations takes the listed phrases out of a dictation, in drop
+// mode. What is left is "" when it was nothing but them.
+func dropHallucinations(text string) string {
+	if config.Hallucinations.Mode != hallucinationsDrop {
+		return text
+	}
+	found := findHallucinations(text)
+	if len(found) == 0 {
+		return text
+	}
+	for _, f := range found {
+		fmt.Printf("Dropped %q, it's on the hallucination list\n", logged(f))
+	}
+	h := hallucinations()
+	if h.builtin != nil {
+		text = h.builtin.ReplaceAllString(text, "${1}")
+	}
+	if h.phrases != nil {
+		text = h.phrases.ReplaceAllString(text, "")
+	}
+	text = tidySpaces(text)
+	if !strings.ContainsFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+		return ""
+	}
+	return text
+}
+
+// flaggedHallucinations reports whether a dictation has to be confirmed
+// before it is typed, in flag mode.
+func flaggedHallucinations(text string) bool {
+	return config.Hallucinations.Mode == hallucinationsFlag && len(findHallucinations(text)) > 0
+}
+
+// handleHallucination asks what to do with a dictation that has a listed
+// phrase in it, in the preview window, or holds it for the reinsert hotkey
+// where there is none.
+func handleHallucination(text string) {
+	fmt.Printf("Possible hallucination: %s\n", logged(strings.Join(findHallucinations(text), ", ")))
+	switch previewText(tr("Dictation: possible hallucination"), text) {
+	case previewInsert:
+		insertText(text)
+		return
+	case previewCopy:
+		if err := injector.WriteClipboard(text); err != nil {
+			fmt.Printf("Error: copying failed: %v\n", err)
+		}
+		return
+	case previewUnavailable:
+		// Fall back to holding the text.
+	default:
+		fmt.Println("Not inserting, the text is kept for the reinsert hotkey")
+		return
+	}
+	fmt.Println("Not inserting, press the reinsert hotkey to type it anyway")
+	notify(tr("Dictation not inserted"), tr("It looks like a Whisper hallucination, press the reinsert hotkey to type it anyway: %s", truncate(logged(text), 80)))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// historyEntry is one dictation in the history file.
+type historyEntry struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Profile string    `json:"profile"`
+	Text    string    `json:"text"`
+	// App and WindowTitle are where the text was inserted. Only recorded
+	// with history.app_context on.
+	App         string `json:"app,omitempty"`
+	WindowTitle string `json:"window_title,omitempty"`
+}
+
+// The history is an append-only JSON lines file, one entry per dictation.
+var historyMu sync.Mutex
+
+func historyPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+func appendHistory(entry historyEntry) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// The history can hold sensitive text, keep it private.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening history: %w", err)
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	return nil
+}
+
+// readHistory returns all entries, oldest first. Lines that can't be parsed
+// are skipped.
+func readHistory() ([]historyEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var e historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// recordHistory saves a dictation if history is enabled. app is the bundle
+// ID of the app it went to.
+func recordHistory(id string, profile Profile, text, app, windowTitle string) {
+	if !config.History.Enabled {
+		return
+	}
+	entry := historyEntry{ID: id, Time: time.Now(), Profile: profile.Name, Text: text}
+	if config.History.AppContext {
+		entry.App, entry.WindowTitle = app, windowTitle
+	}
+	if err := appendHistory(entry); err != nil {
+		fmt.Printf("Warning: failed to save history: %v\n", err)
+	}
+}
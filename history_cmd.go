@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func runHistoryCommand(args []string) error {
+	if len(args) == 0 || args[0] != "browse" {
+		return errors.New("usage: dictation history browse")
+	}
+
+	entries, err := readHistory()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		if !config.History.Enabled {
+			fmt.Println("No history, dictations are only kept with history.enabled in the config.")
+			return nil
+		}
+		fmt.Println("No history yet.")
+		return nil
+	}
+	slices.Reverse(entries) // newest first
+
+	b := &historyBrowser{all: entries}
+	b.filter()
+	m, err := tea.NewProgram(b, tea.WithAltScreen()).Run()
+	b.stop()
+	if err != nil {
+		return fmt.Errorf("history browser: %w", err)
+	}
+
+	if insert := m.(*historyBrowser).insert; insert != "" {
+		// Give the user a moment to focus the app the text should go to.
+		for i := 3; i > 0; i-- {
+			fmt.Printf("\rSwitch to the target app, typing in %d...", i)
+			time.Sleep(time.Second)
+		}
+		fmt.Println()
+		insertText(insert)
+	}
+	return nil
+}
+
+// historyBrowser is a full screen list of past dictations with search and
+// a preview of the selected one, as a bubbletea model.
+type historyBrowser struct {
+	all       []historyEntry
+	shown     []historyEntry
+	selected  int
+	offset    int
+	rows      int
+	cols      int
+	query     string
+	searching bool
+	// correcting is on while a correction is typed, as "heard -> meant".
+	correcting bool
+	correction string
+	message    string
+	// stopPlayback stops the recording being played, if any. playing
+	// counts the recordings played, to tell which one a playbackEnded is
+	// about.
+	stopPlayback func()
+	playing      int
+	// insert is the text to re-insert once the browser is closed, if that
+	// is what the user chose.
+	insert string
+}
+
+func (b *historyBrowser) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keys until the user quits.
+func (b *historyBrowser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.rows, b.cols = msg.Height, msg.Width
+	case playbackEnded:
+		if int(msg) == b.playing && b.stopPlayback != nil {
+			b.stopPlayback = nil
+			b.message = "Finished playing"
+		}
+	case tea.KeyMsg:
+		b.message = ""
+		switch {
+		case b.correcting:
+			switch msg.Type {
+			case tea.KeyEnter:
+				b.correcting = false
+				b.learnCorrection()
+			case tea.KeyEsc, tea.KeyCtrlC:
+				b.correcting = false
+			case tea.KeyBackspace:
+				b.correction = dropLastRune(b.correction)
+			case tea.KeyRunes, tea.KeySpace:
+				b.correction += string(msg.Runes)
+			}
+		case b.searching:
+			switch msg.Type {
+			case tea.KeyEnter, tea.KeyEsc:
+				b.searching = false
+			case tea.KeyBackspace:
+				b.query = dropLastRune(b.query)
+				b.filter()
+			case tea.KeyRunes, tea.KeySpace:
+				b.query += string(msg.Runes)
+				b.filter()
+			}
+		default:
+			return b, b.key(msg.String())
+		}
+	}
+	return b, nil
+}
+
+// key handles a key outside of search and corrections.
+func (b *historyBrowser) key(key string) tea.Cmd {
+	switch key {
+	case "q", "esc", "ctrl+c":
+		return tea.Quit
+	case "up", "k":
+		b.selected = max(0, b.selected-1)
+	case "down", "j":
+		b.selected = min(len(b.shown)-1, b.selected+1)
+	case "/":
+		b.searching = true
+	case "c":
+		if e, ok := b.current(); ok {
+			if err := injector.WriteClipboard(e.Text); err != nil {
+				b.message = "Copy failed: " + err.Error()
+			} else {
+				b.message = "Copied to clipboard"
+			}
+		}
+	case "i":
+		if e, ok := b.current(); ok {
+			b.insert = e.Text
+			return tea.Quit
+		}
+	case "e":
+		if _, ok := b.current(); ok {
+			b.correcting, b.correction = true, ""
+		}
+	case "p":
+		return b.play()
+	}
+	return nil
+}
+
+func dropLastRune(s string) string {
+	if r := []rune(s); len(r) > 0 {
+		return string(r[:len(r)-1])
+	}
+	return s
+}
+
+// play plays the kept recording of the selected dictation, or stops the
+// one playing. The command it returns reports when playing ends.
+func (b *historyBrowser) play() tea.Cmd {
+	if b.stopPlayback != nil {
+		b.stop()
+		b.message = "Stopped"
+		return nil
+	}
+	e, ok := b.current()
+	if !ok {
+		return nil
+	}
+	path, ok := archivedAudio(e.ID)
+	if !ok {
+		b.message = "No recording kept for this dictation (see history.keep_audio)"
+		return nil
+	}
+	stop, done, err := playRecording(path)
+	if err != nil {
+		b.message = "Playing failed: " + err.Error()
+		return nil
+	}
+	b.playing++
+	b.stopPlayback = stop
+	b.message = "Playing the recording, p to stop"
+	playing := b.playing
+	return func() tea.Msg {
+		<-done
+		return playbackEnded(playing)
+	}
+}
+
+// playbackEnded is sent when the playing of a recording ended, with the
+// number of that playing.
+type playbackEnded int
+
+func (b *historyBrowser) stop() {
+	if b.stopPlayback != nil {
+		b.stopPlayback()
+		b.stopPlayback = nil
+	}
+}
+
+// learnCorrection adds the typed correction of the selected dictation to the
+// vocabulary, so later dictations get it right.
+func (b *historyBrowser) learnCorrection() {
+	e, ok := b.current()
+	if !ok {
+		return
+	}
+	heard, meant, ok := strings.Cut(strings.ReplaceAll(b.correction, "→", "->"), "->")
+	heard, meant = strings.TrimSpace(heard), strings.TrimSpace(meant)
+	if !ok || heard == "" || meant == "" {
+		b.message = "Type a correction as: heard -> meant"
+		return
+	}
+	if !strings.Contains(strings.ToLower(e.Text), strings.ToLower(heard)) {
+		b.message = fmt.Sprintf("%q isn't in this dictation", heard)
+		return
+	}
+	if err := vocabulary.learn(heard, meant, true); err != nil {
+		b.message = "Saving the correction failed: " + err.Error()
+		return
+	}
+	b.message = fmt.Sprintf("Learned: %q → %q", heard, meant)
+}
+
+func (b *historyBrowser) current() (historyEntry, bool) {
+	if b.selected < 0 || b.selected >= len(b.shown) {
+		return historyEntry{}, false
+	}
+	return b.shown[b.selected], true
+}
+
+// filter applies the search query: every word has to appear in the text,
+// the app or the window title.
+func (b *historyBrowser) filter() {
+	words := strings.Fields(strings.ToLower(b.query))
+	b.shown = b.shown[:0]
+	for _, e := range b.all {
+		haystack := strings.ToLower(e.Text + " " + e.App + " " + e.WindowTitle)
+		match := true
+		for _, w := range words {
+			if !strings.Contains(haystack, w) {
+				match = false
+				break
+			}
+		}
+		if match {
+			b.shown = append(b.shown, e)
+		}
+	}
+	b.selected, b.offset = 0, 0
+}
+
+// View draws the list, the preview of the selected dictation and a footer
+// with the keys or the last message.
+func (b *historyBrowser) View() string {
+	rows, cols := b.rows, b.cols
+	if rows <= 0 || cols <= 0 {
+		rows, cols = 24, 80
+	}
+	previewRows := max(3, rows/3)
+	listRows := max(1, rows-previewRows-3)
+
+	if b.selected < b.offset {
+		b.offset = b.selected
+	}
+	if b.selected >= b.offset+listRows {
+		b.offset = b.selected - listRows + 1
+	}
+
+	var lines []string
+	header := fmt.Sprintf("History: %d of %d", len(b.shown), len(b.all))
+	if b.query != "" || b.searching {
+		header += "  search: " + b.query
+		if b.searching {
+			header += "▏"
+		}
+	}
+	if b.correcting {
+		header = "Correction (heard -> meant): " + b.correction + "▏"
+	}
+	lines = append(lines, "\x1b[1m"+truncate(header, cols)+"\x1b[0m")
+
+	for i := b.offset; i < b.offset+listRows; i++ {
+		if i >= len(b.shown) {
+			lines = append(lines, "")
+			continue
+		}
+		e := b.shown[i]
+		row := e.Time.Local().Format("2006-01-02 15:04") + "  "
+		if e.App != "" {
+			row += e.App + "  "
+		}
+		row = truncate(row+strings.ReplaceAll(e.Text, "\n", " "), cols)
+		if i == b.selected {
+			row = "\x1b[7m" + row + "\x1b[0m"
+		}
+		lines = append(lines, row)
+	}
+
+	lines = append(lines, strings.Repeat("─", cols))
+	preview := []string{}
+	if e, ok := b.current(); ok {
+		if e.WindowTitle != "" {
+			preview = append(preview, "\x1b[2m"+truncate(e.WindowTitle, cols)+"\x1b[0m")
+		}
+		preview = append(preview, wrap(e.Text, cols)...)
+	}
+	for i := 0; i < previewRows; i++ {
+		if i < len(preview) {
+			lines = append(lines, preview[i])
+		} else {
+			lines = append(lines, "")
+		}
+	}
+
+	footer := "↑/↓ select  / search  c copy  i re-insert  e correct  p play  q quit"
+	if b.message != "" {
+		footer = b.message
+	}
+	lines = append(lines, "\x1b[2m"+truncate(footer, cols)+"\x1b[0m")
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Hotkey actions.
+const (
+	actionToggle   = "toggle"
+	actionAbort    = "abort"
+	actionReinsert = "reinsert"
+)
+
+// sequenceTimeout is how long the next step of a sequence may take.
+const sequenceTimeout = time.Second
+
+// hotkey is a parsed trigger.hotkeys entry: one chord, or a sequence of
+// them separated by spaces ("ctrl+k d"), optionally to be held.
+type hotkey struct {
+	HotkeyConfig
+	steps []chord
+	hold  time.Duration
+}
+
+func parseHotkey(h HotkeyConfig) (hotkey, error) {
+	hk := hotkey{HotkeyConfig: h}
+	for _, step := range strings.Fields(h.Key) {
+		c, err := parseChord(step)
+		if err != nil {
+			return hotkey{}, err
+		}
+		hk.steps = append(hk.steps, c)
+	}
+	if len(hk.steps) == 0 {
+		return hotkey{}, fmt.Errorf("empty hotkey")
+	}
+	if h.Hold != "" {
+		d, err := time.ParseDuration(h.Hold)
+		if err != nil || d <= 0 {
+			return hotkey{}, fmt.Errorf("hotkey %q: invalid hold %q", h.Key, h.Hold)
+		}
+		hk.hold = d
+	}
+	switch h.Action {
+	case "", actionToggle, actionAbort, actionReinsert:
+	default:
+		return hotkey{}, fmt.Errorf("hotkey %q: unknown action %q", h.Key, h.Action)
+	}
+	return hk, nil
+}
+
+// globeGesture reports whether the hotkey is the plain Globe key, which
+// keeps its double press to start, single press to stop gesture.
+func (h *hotkey) globeGesture() bool {
+	return len(h.steps) == 1 && h.steps[0] == chord{key: globeKeyCode} && h.hold == 0 &&
+		(h.Action == "" || h.Action == actionToggle)
+}
+
+// chordEngine matches key events against the hotkeys. Keys can't be
+// swallowed, the focused app sees them too, so hotkeys should be
+// combinations apps don't use.
+type chordEngine struct {
+	hotkeys  []hotkey
+	progress []int // steps of each hotkey matched so far
+	lastStep time.Time
+
+	// A hotkey whose last step is being held, waiting for its timer.
+	holding   int
+	holdKey   uint16
+	holdTimer *time.Timer
+	// holds receives the index of a hotkey once it was held long enough.
+	holds chan int
+}
+
+func newChordEngine(configs []HotkeyConfig) *chordEngine {
+	e := &chordEngine{holding: -1, holds: make(chan int, 1)}
+	for _, h := range configs {
+		hk, err := parseHotkey(h) // checked by validate
+		if err != nil {
+			continue
+		}
+		e.hotkeys = append(e.hotkeys, hk)
+	}
+	e.progress = make([]int, len(e.hotkeys))
+	return e
+}
+
+// handle takes a key event (not of a modifier key) and returns the hotkeys
+// that fire now. Held hotkeys fire later, through holds.
+func (e *chordEngine) handle(ev keyEvent) []*hotkey {
+	if !ev.down {
+		if e.holding >= 0 && ev.code == e.holdKey {
+			e.cancelHold()
+		}
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(e.lastStep) > sequenceTimeout {
+		clear(e.progress)
+	}
+
+	var fired []*hotkey
+	for i := range e.hotkeys {
+		h := &e.hotkeys[i]
+		p := e.progress[i]
+		switch {
+		case h.steps[p].matches(ev):
+			p++
+		case h.steps[0].matches(ev):
+			p = 1
+		default:
+			p = 0
+		}
+		if p == len(h.steps) {
+			p = 0
+			if h.hold > 0 {
+				e.startHold(i, ev.code)
+			} else {
+				fired = append(fired, h)
+			}
+		}
+		e.progress[i] = p
+	}
+	e.lastStep = now
+	return fired
+}
+
+func (e *chordEngine) startHold(i int, key uint16) {
+	e.cancelHold()
+	e.holding, e.holdKey = i, key
+	e.holdTimer = time.AfterFunc(e.hotkeys[i].hold, func() {
+		select {
+		case e.holds <- i:
+		default:
+		}
+	})
+}
+
+func (e *chordEngine) cancelHold() {
+	if e.holdTimer != nil {
+		e.holdTimer.Stop()
+	}
+	e.holding = -1
+}
+
+// held returns the hotkey a hold notification from holds is for, if the
+// key is still down.
+func (e *chordEngine) held(i int) (*hotkey, bool) {
+	if i != e.holding {
+		return nil, false
+	}
+	e.holding = -1
+	return &e.hotkeys[i], true
+}
@@ -0,0 +1,15 @@
+package main
+
+// Injector puts text and key presses into the focused app and gives access
+// to the clipboard. Every platform has its own, see inject_*.go.
+type Injector interface {
+	TypeString(text string) error
+	// TapKey presses a key with modifiers held. Keys are "enter", "tab",
+	// "escape", the arrows "left", "right", "up" and "down" or a letter,
+	// modifiers "cmd", "ctrl", "shift" or "alt".
+	TapKey(key string, modifiers ...string) error
+	ReadClipboard() (string, error)
+	WriteClipboard(text string) error
+}
+
+var injector = newInjector()
@@ -0,0 +1,149 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework ApplicationServices -framework AppKit
+#import <AppKit/AppKit.h>
+#import <ApplicationServices/ApplicationServices.h>
+#include <stdlib.h>
+#include <string.h>
+
+// postUnicode types the characters as one key press. Flags are cleared so a
+// modifier the user still holds doesn't turn the text into shortcuts.
+static void postUnicode(UniChar *chars, int n) {
+	CGEventRef down = CGEventCreateKeyboardEvent(NULL, 0, true);
+	CGEventRef up = CGEventCreateKeyboardEvent(NULL, 0, false);
+	CGEventSetFlags(down, 0);
+	CGEventSetFlags(up, 0);
+	CGEventKeyboardSetUnicodeString(down, n, chars);
+	CGEventKeyboardSetUnicodeString(up, n, chars);
+	CGEventPost(kCGHIDEventTap, down);
+	CGEventPost(kCGHIDEventTap, up);
+	CFRelease(down);
+	CFRelease(up);
+}
+
+static void postKey(CGKeyCode code, CGEventFlags flags) {
+	CGEventRef down = CGEventCreateKeyboardEvent(NULL, code, true);
+	CGEventRef up = CGEventCreateKeyboardEvent(NULL, code, false);
+	CGEventSetFlags(down, flags);
+	CGEventSetFlags(up, flags);
+	CGEventPost(kCGHIDEventTap, down);
+	CGEventPost(kCGHIDEventTap, up);
+	CFRelease(down);
+	CFRelease(up);
+}
+
+// readPasteboard returns NULL if there is no text, or text the caller frees.
+static char *readPasteboard(void) {
+	@autoreleasepool {
+		NSString *text = [[NSPasteboard generalPasteboard] stringForType:NSPasteboardTypeString];
+		if (text == nil) {
+			return NULL;
+		}
+		return strdup(text.UTF8String);
+	}
+}
+
+static int writePasteboard(const char *text) {
+	@autoreleasepool {
+		NSPasteboard *pasteboard = [NSPasteboard generalPasteboard];
+		[pasteboard clearContents];
+		return [pasteboard setString:[NSString stringWithUTF8String:text] forType:NSPasteboardTypeString];
+	}
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+const pasteModifier = "cmd"
+
+// typingInterval is the pause between typed characters. Without it some
+// apps (Electron ones especially) drop or reorder characters.
+const typingInterval = 2 * time.Millisecond
+
+// cgEventInjector posts keyboard events with CGEventPost, typing the text
+// as unicode payloads so it doesn't depend on the keyboard layout.
+type cgEventInjector struct{}
+
+func newInjector() Injector {
+	return cgEventInjector{}
+}
+
+func injectionCommands() []string {
+	return nil
+}
+
+// macKeyCodes are the virtual key codes of the keys TapKey knows.
+var macKeyCodes = map[string]C.CGKeyCode{
+	"enter": 36, "tab": 48, "space": 49, "escape": 53,
+	"left": 123, "right": 124, "down": 125, "up": 126,
+	"a": 0, "b": 11, "c": 8, "d": 2, "e": 14, "f": 3, "g": 5, "h": 4, "i": 34,
+	"j": 38, "k": 40, "l": 37, "m": 46, "n": 45, "o": 31, "p": 35, "q": 12,
+	"r": 15, "s": 1, "t": 17, "u": 32, "v": 9, "w": 13, "x": 7, "y": 16, "z": 6,
+}
+
+var macModifierFlags = map[string]C.CGEventFlags{
+	"cmd":   C.kCGEventFlagMaskCommand,
+	"ctrl":  C.kCGEventFlagMaskControl,
+	"shift": C.kCGEventFlagMaskShift,
+	"alt":   C.kCGEventFlagMaskAlternate,
+}
+
+func (cgEventInjector) TypeString(text string) error {
+	for _, r := range text {
+		if r == '\n' {
+			// A newline character isn't a line break to most apps.
+			C.postKey(macKeyCodes["enter"], 0)
+		} else {
+			units := utf16.Encode([]rune{r})
+			C.postUnicode((*C.UniChar)(unsafe.Pointer(&units[0])), C.int(len(units)))
+		}
+		time.Sleep(typingInterval)
+	}
+	return nil
+}
+
+func (cgEventInjector) TapKey(key string, modifiers ...string) error {
+	code, ok := macKeyCodes[key]
+	if !ok {
+		return fmt.Errorf("unknown key %q", key)
+	}
+	var flags C.CGEventFlags
+	for _, m := range modifiers {
+		flag, ok := macModifierFlags[m]
+		if !ok {
+			return fmt.Errorf("unknown modifier %q", m)
+		}
+		flags |= flag
+	}
+	C.postKey(code, flags)
+	return nil
+}
+
+func (cgEventInjector) ReadClipboard() (string, error) {
+	text := C.readPasteboard()
+	if text == nil {
+		return "", errors.New("no text on the clipboard")
+	}
+	defer C.free(unsafe.Pointer(text))
+	return C.GoString(text), nil
+}
+
+func (cgEventInjector) WriteClipboard(text string) error {
+	cText := C.CString(text)
+	defer C.free(unsafe.Pointer(cText))
+	if C.writePasteboard(cText) == 0 {
+		return errors.New("the pasteboard refused the text")
+	}
+	return nil
+}
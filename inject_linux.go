@@ -0,0 +1,101 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// On Linux text is injected with xdotool on X11 and wtype on Wayland, and
+// the clipboard goes through xclip or wl-clipboard. All of them have to be
+// installed.
+
+const pasteModifier = "ctrl"
+
+// commandInjector runs the X11 or Wayland tool for each action.
+type commandInjector struct{}
+
+func newInjector() Injector {
+	return commandInjector{}
+}
+
+func wayland() bool {
+	return os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// injectionCommands are the programs text injection needs in this session.
+func injectionCommands() []string {
+	if wayland() {
+		return []string{"wtype", "wl-copy", "wl-paste"}
+	}
+	return []string{"xdotool", "xclip"}
+}
+
+func (commandInjector) TypeString(text string) error {
+	if wayland() {
+		return runWithInput(text, "wtype", "-")
+	}
+	return runWithInput(text, "xdotool", "type", "--clearmodifiers", "--file", "-")
+}
+
+// linuxKeyNames maps Injector key names to X keysyms, which both tools use.
+var linuxKeyNames = map[string]string{
+	"enter":  "Return",
+	"tab":    "Tab",
+	"escape": "Escape",
+	"left":   "Left",
+	"right":  "Right",
+	"up":     "Up",
+	"down":   "Down",
+}
+
+func (commandInjector) TapKey(key string, modifiers ...string) error {
+	if name, ok := linuxKeyNames[key]; ok {
+		key = name
+	}
+	if wayland() {
+		var args []string
+		for _, m := range modifiers {
+			args = append(args, "-M", m)
+		}
+		args = append(args, "-k", key)
+		for _, m := range modifiers {
+			args = append(args, "-m", m)
+		}
+		return runWithInput("", "wtype", args...)
+	}
+	return runWithInput("", "xdotool", "key", "--clearmodifiers", strings.Join(append(modifiers, key), "+"))
+}
+
+func (commandInjector) ReadClipboard() (string, error) {
+	var cmd *exec.Cmd
+	if wayland() {
+		cmd = exec.Command("wl-paste", "--no-newline")
+	} else {
+		cmd = exec.Command("xclip", "-selection", "clipboard", "-o")
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("running %s: %w", cmd.Args[0], err)
+	}
+	return string(out), nil
+}
+
+func (commandInjector) WriteClipboard(text string) error {
+	if wayland() {
+		return runWithInput(text, "wl-copy")
+	}
+	return runWithInput(text, "xclip", "-selection", "clipboard")
+}
+
+func runWithInput(input, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(input)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("running %s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !darwin && !linux && !windows
+
+package main
+
+import "errors"
+
+const pasteModifier = "ctrl"
+
+var errNoInjection = errors.New("typing text isn't supported on this system")
+
+// unsupportedInjector is used where there is no way to inject text yet.
+type unsupportedInjector struct{}
+
+func newInjector() Injector {
+	return unsupportedInjector{}
+}
+
+func injectionCommands() []string {
+	return nil
+}
+
+func (unsupportedInjector) TypeString(string) error        { return errNoInjection }
+func (unsupportedInjector) TapKey(string, ...string) error { return errNoInjection }
+func (unsupportedInjector) ReadClipboard() (string, error) { return "", errNoInjection }
+func (unsupportedInjector) WriteClipboard(string) error    { return errNoInjection }
@@ -0,0 +1,163 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// Text is typed with SendInput and unicode key events, which produce any
+// character regardless of the keyboard layout.
+
+const pasteModifier = "ctrl"
+
+type sendInputInjector struct{}
+
+func newInjector() Injector {
+	return sendInputInjector{}
+}
+
+func injectionCommands() []string {
+	return nil
+}
+
+func (sendInputInjector) TypeString(text string) error {
+	var inputs []keyboardInput
+	for _, unit := range utf16.Encode([]rune(text)) {
+		if unit == '\n' {
+			// Apps expect Return for a line break, not the character.
+			inputs = append(inputs, virtualKey(0x0d, false), virtualKey(0x0d, true))
+			continue
+		}
+		inputs = append(inputs,
+			keyboardInput{typ: inputKeyboard, ki: keybdInput{scan: unit, flags: keyeventfUnicode}},
+			keyboardInput{typ: inputKeyboard, ki: keybdInput{scan: unit, flags: keyeventfUnicode | keyeventfKeyUp}},
+		)
+	}
+	return sendInput(inputs)
+}
+
+// windowsKeys maps Injector key names to virtual key codes.
+var windowsKeys = map[string]uint16{
+	"enter":  0x0d,
+	"tab":    0x09,
+	"escape": 0x1b,
+	"left":   0x25,
+	"up":     0x26,
+	"right":  0x27,
+	"down":   0x28,
+	"ctrl":   0x11,
+	"shift":  0x10,
+	"alt":    0x12,
+}
+
+func (sendInputInjector) TapKey(key string, modifiers ...string) error {
+	code, ok := windowsKeys[key]
+	if !ok && len(key) == 1 && key[0] >= 'a' && key[0] <= 'z' {
+		code, ok = uint16(key[0]-'a'+'A'), true
+	}
+	if !ok {
+		return fmt.Errorf("unknown key %q", key)
+	}
+
+	var inputs []keyboardInput
+	for _, m := range modifiers {
+		inputs = append(inputs, virtualKey(windowsKeys[m], false))
+	}
+	inputs = append(inputs, virtualKey(code, false), virtualKey(code, true))
+	for i := len(modifiers) - 1; i >= 0; i-- {
+		inputs = append(inputs, virtualKey(windowsKeys[modifiers[i]], true))
+	}
+	return sendInput(inputs)
+}
+
+func virtualKey(code uint16, up bool) keyboardInput {
+	in := keyboardInput{typ: inputKeyboard, ki: keybdInput{vk: code}}
+	if up {
+		in.ki.flags = keyeventfKeyUp
+	}
+	return in
+}
+
+func sendInput(inputs []keyboardInput) error {
+	if len(inputs) == 0 {
+		return nil
+	}
+	n, _, err := procSendInput.Call(uintptr(len(inputs)), uintptr(unsafe.Pointer(&inputs[0])), unsafe.Sizeof(inputs[0]))
+	if int(n) != len(inputs) {
+		return fmt.Errorf("SendInput: %w", err)
+	}
+	return nil
+}
+
+// openClipboard retries for a moment, as another app may briefly hold the
+// clipboard open.
+func openClipboard() error {
+	var err error
+	for range 10 {
+		var ok uintptr
+		if ok, _, err = procOpenClipboard.Call(0); ok != 0 {
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("opening clipboard: %w", err)
+}
+
+func (sendInputInjector) ReadClipboard() (string, error) {
+	if err := openClipboard(); err != nil {
+		return "", err
+	}
+	defer procCloseClipboard.Call()
+
+	h, _, _ := procGetClipboardData.Call(cfUnicodeText)
+	if h == 0 {
+		return "", errors.New("no text on the clipboard")
+	}
+	p, _, err := procGlobalLock.Call(h)
+	if p == 0 {
+		return "", fmt.Errorf("locking clipboard data: %w", err)
+	}
+	defer procGlobalUnlock.Call(h)
+
+	n, _, _ := procLstrlenW.Call(p)
+	buf := make([]uint16, n+1)
+	procRtlMoveMemory.Call(uintptr(unsafe.Pointer(&buf[0])), p, (n+1)*2)
+	return syscall.UTF16ToString(buf), nil
+}
+
+func (sendInputInjector) WriteClipboard(text string) error {
+	data := utf16.Encode([]rune(text + "\x00"))
+
+	if err := openClipboard(); err != nil {
+		return err
+	}
+	defer procCloseClipboard.Call()
+
+	if ok, _, err := procEmptyClipboard.Call(); ok == 0 {
+		return fmt.Errorf("emptying clipboard: %w", err)
+	}
+	h, _, err := procGlobalAlloc.Call(gmemMoveable, uintptr(len(data)*2))
+	if h == 0 {
+		return fmt.Errorf("allocating clipboard data: %w", err)
+	}
+	p, _, err := procGlobalLock.Call(h)
+	if p == 0 {
+		procGlobalFree.Call(h)
+		return fmt.Errorf("locking clipboard data: %w", err)
+	}
+	procRtlMoveMemory.Call(p, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)*2))
+	procGlobalUnlock.Call(h)
+
+	// The clipboard owns the memory once this succeeds.
+	if ok, _, err := procSetClipboardData.Call(cfUnicodeText, h); ok == 0 {
+		procGlobalFree.Call(h)
+		return fmt.Errorf("setting clipboard data: %w", err)
+	}
+	return nil
+}
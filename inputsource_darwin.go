@@ -0,0 +1,20 @@
+//go:build darwin
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// currentKeyboardLayout returns the ID of the active keyboard layout, e.g.
+// "com.apple.keylayout.US", or "" if it can't be determined. It reads the
+// HIToolbox preferences rather than calling the Text Input Sources API,
+// which has to run on the main thread.
+func currentKeyboardLayout() string {
+	out, err := exec.Command("defaults", "read", "com.apple.HIToolbox", "AppleCurrentKeyboardLayoutInputSourceID").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
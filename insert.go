@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	insertAuto  = "auto"
+	insertType  = "type"
+	insertPaste = "paste"
+
+	copyLastHistory   = "history"
+	copyLastClipboard = "clipboard"
+	copyLastOff       = "off"
+
+	trailingNone    = "none"
+	trailingSpace   = "space"
+	trailingNewline = "newline"
+
+	// pasteSettleTime is how long to wait after pressing Cmd+V before the
+	// clipboard is restored; the target app reads it asynchronously.
+	pasteSettleTime = 300 * time.Millisecond
+	// clipboardHistoryTime is how long a transcription stays on the
+	// clipboard for clipboard managers, which poll it, to record it.
+	clipboardHistoryTime = time.Second
+)
+
+var trailingText = map[string]string{
+	trailingNone:    "",
+	trailingSpace:   " ",
+	trailingNewline: "\n",
+}
+
+// insertText puts the transcription into the focused app. Typing is the most
+// compatible, but synthesized key strokes can't reliably produce emoji,
+// CJK or accented characters, and come out scrambled on layouts other than
+// US ones (AZERTY, Dvorak, German...), so in auto mode such text is pasted
+// instead.
+func insertText(text string) {
+	insertTextThen(text, followUp{Cursor: -1})
+}
+
+// insertTextThen inserts text and then does what f says in the app. A key
+// command is pressed instead of auto-send's Return. It waits for the
+// insertions before it in the queue, see insertQueue.
+func insertTextThen(text string, f followUp) {
+	if !insertions.run(f.Recording, func() { insertNow(text, f) }) {
+		fmt.Println("Insertion cancelled")
+	}
+}
+
+func insertNow(text string, f followUp) {
+	bundleID := frontmostApp()
+	app := config.app(bundleID)
+
+	trailing := config.Output.Trailing
+	if app.Trailing != "" {
+		trailing = app.Trailing
+	}
+	trail := trailingText[trailing]
+	text += trail
+
+	// Applied last so not even a configured trailing newline gets through.
+	if app.TerminalSafe {
+		text, trail = terminalSafe(text), ""
+	}
+
+	mode := config.Output.Mode
+	if mode == insertAuto {
+		mode = insertType
+		if !isASCII(text) {
+			mode = insertPaste
+		} else if layout := currentKeyboardLayout(); layout != "" && !slices.Contains(config.Output.TypingLayouts, layout) {
+			mode = insertPaste
+		}
+	}
+
+	if mode == insertPaste {
+		if err := pasteText(text); err != nil {
+			fmt.Printf("Warning: pasting failed, typing instead: %v\n", err)
+			mode = insertType
+		}
+	}
+	if mode == insertType {
+		if err := injector.TypeString(text); err != nil {
+			fmt.Printf("Error: typing failed: %v\n", err)
+		}
+	}
+
+	if len(f.Actions) > 0 {
+		runAfterInsert(f, strings.TrimSuffix(text, trail), trail)
+	}
+	if f.Key != nil {
+		pressKeyCommand(f.Key)
+	} else if config.autoSend(bundleID) || f.Submit && bundleID != "" && !app.TerminalSafe {
+		autoSend(bundleID)
+	}
+}
+
+// autoSend presses Return to send what was just dictated, after asking if
+// configured to. It only does so if the app still has focus, the dialog
+// or the user may have moved it.
+func autoSend(bundleID string) {
+	if config.Output.AutoSendConfirm && !confirm(tr("Send the dictated message?")) {
+		fmt.Println("Auto-send cancelled")
+		return
+	}
+	if frontmostApp() != bundleID {
+		fmt.Println("Focus changed, not auto-sending")
+		return
+	}
+	if err := injector.TapKey("enter"); err != nil {
+		fmt.Printf("Warning: auto-send failed: %v\n", err)
+	}
+}
+
+// copyLast puts a transcription on the clipboard as output.copy_last says,
+// whether or not it was typed, so a dictation that went to the wrong window
+// or couldn't be inserted is never lost. In history mode the clipboard is
+// put back afterwards, unless its content can't be read, which would lose
+// it.
+func copyLast(text string) {
+	if text == "" || config.Output.CopyLast == copyLastOff {
+		return
+	}
+	var previous string
+	if config.Output.CopyLast == copyLastHistory {
+		var err error
+		if previous, err = injector.ReadClipboard(); err != nil {
+			return
+		}
+		if previous == text {
+			return // already there, copied from the preview
+		}
+	}
+
+	if err := injector.WriteClipboard(text); err != nil {
+		fmt.Printf("Warning: copying the transcription failed: %v\n", err)
+		return
+	}
+	if config.Output.CopyLast == copyLastHistory {
+		time.Sleep(clipboardHistoryTime)
+		if err := injector.WriteClipboard(previous); err != nil {
+			fmt.Printf("Warning: failed to restore clipboard: %v\n", err)
+		}
+	}
+}
+
+// terminalSafe removes what could make a shell do something when typed:
+// control characters and line breaks (a newline would run the command)
+// become spaces, backticks are dropped, and trailing whitespace is trimmed.
+func terminalSafe(text string) string {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '`':
+			return -1
+		case unicode.IsControl(r):
+			return ' '
+		}
+		return r
+	}, text)
+	return strings.TrimRightFunc(text, unicode.IsSpace)
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// pasteText pastes through the clipboard and puts the previous clipboard
+// text back afterwards.
+func pasteText(text string) error {
+	previous, readErr := injector.ReadClipboard()
+
+	if err := injector.WriteClipboard(text); err != nil {
+		return fmt.Errorf("writing clipboard: %w", err)
+	}
+	restore := func() {
+		if readErr != nil {
+			return
+		}
+		if err := injector.WriteClipboard(previous); err != nil {
+			fmt.Printf("Warning: failed to restore clipboard: %v\n", err)
+		}
+	}
+	if err := injector.TapKey("v", pasteModifier); err != nil {
+		// Nothing was pasted, so the clipboard can go back right away.
+		restore()
+		return fmt.Errorf("pressing %s+V: %w", pasteModifier, err)
+	}
+
+	time.Sleep(pasteSettleTime)
+	restore()
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// cancelledTTL is how long a cancelled recording's insertions keep being
+// dropped, for those still on their way to the queue.
+const cancelledTTL = time.Minute
+
+// insertion is a place in the insertion queue: an insertion waiting for its
+// turn, or one kept for a recording that is still being transcribed.
+type insertion struct {
+	recording string
+	// reserved is set for a place kept for a recording, until its text is
+	// ready or output.insert_order_wait is over.
+	reserved  bool
+	since     time.Time
+	cancelled bool
+}
+
+// insertQueue makes everything that types, pastes or presses keys in the
+// focused app do so one after the other: a segment of one dictation,
+// another dictation that was transcribed meanwhile and the reinsert hotkey
+// would otherwise interleave their characters. Insertions go in the order
+// they were queued, except that each recording keeps its place from when
+// it stopped, so a short dictation that comes back fast doesn't go before
+// the long one said first.
+type insertQueue struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	queue   []*insertion
+	busy    bool
+	// cancelled are recordings whose insertions are dropped, by when they
+	// were cancelled.
+	cancelled map[string]time.Time
+}
+
+var insertions = newInsertQueue()
+
+func newInsertQueue() *insertQueue {
+	q := &insertQueue{cancelled: map[string]time.Time{}}
+	q.changed = sync.NewCond(&q.mu)
+	return q
+}
+
+// run does fn once it's the turn of an insertion for recording ("" for
+// one that isn't part of a recording). It reports false when the insertion
+// was cancelled while it waited.
+func (q *insertQueue) run(recording string, fn func()) bool {
+	q.mu.Lock()
+	if _, ok := q.cancelled[recording]; ok && recording != "" {
+		q.mu.Unlock()
+		return false
+	}
+	e := &insertion{recording: recording, since: time.Now()}
+	// A recording's insertions go where its place was kept, before it.
+	if i := q.reservation(recording); i >= 0 {
+		q.queue = slices.Insert(q.queue, i, e)
+	} else {
+		q.queue = append(q.queue, e)
+	}
+	for !e.cancelled && (q.queue[0] != e || q.busy) {
+		q.waitForHead()
+	}
+	if e.cancelled {
+		q.remove(e)
+		q.mu.Unlock()
+		return false
+	}
+	q.busy = true
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		q.busy = false
+		q.remove(e)
+		q.mu.Unlock()
+	}()
+	fn()
+	return true
+}
+
+// reserve keeps a place for a recording that stopped and is now being
+// transcribed, for output.insert_order_wait.
+func (q *insertQueue) reserve(recording string) {
+	if config.Output.insertOrderWait() <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.queue = append(q.queue, &insertion{recording: recording, reserved: true, since: time.Now()})
+}
+
+// release gives up a recording's place if it still has one: once it is
+// done, whether or not it inserted anything, and before it asks what to do
+// with its text, as only its insertions through run go before its place.
+func (q *insertQueue) release(recording string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i := q.reservation(recording); i >= 0 {
+		q.remove(q.queue[i])
+	}
+}
+
+// cancel drops the insertions of a recording that are waiting, and those
+// that come later.
+func (q *insertQueue) cancel(recording string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	for id, at := range q.cancelled {
+		if now.Sub(at) > cancelledTTL {
+			delete(q.cancelled, id)
+		}
+	}
+	q.cancelled[recording] = now
+	for _, e := range slices.Clone(q.queue) {
+		if e.recording == recording {
+			q.drop(e)
+		}
+	}
+}
+
+// cancelAll drops every insertion that is waiting, but not the one being
+// typed. It returns how many there were.
+func (q *insertQueue) cancelAll() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for i, e := range slices.Clone(q.queue) {
+		if i == 0 && q.busy {
+			continue
+		}
+		if !e.reserved {
+			n++
+		}
+		q.drop(e)
+	}
+	return n
+}
+
+// drop cancels a waiting insertion, or gives up a place.
+func (q *insertQueue) drop(e *insertion) {
+	if e.reserved {
+		q.remove(e)
+		return
+	}
+	e.cancelled = true
+	q.changed.Broadcast()
+}
+
+// reservation returns where the place kept for a recording is, or -1.
+func (q *insertQueue) reservation(recording string) int {
+	if recording == "" {
+		return -1
+	}
+	return slices.IndexFunc(q.queue, func(e *insertion) bool { return e.reserved && e.recording == recording })
+}
+
+func (q *insertQueue) remove(e *insertion) {
+	if i := slices.Index(q.queue, e); i >= 0 {
+		q.queue = slices.Delete(q.queue, i, i+1)
+		q.changed.Broadcast()
+	}
+}
+
+// waitForHead waits for the queue to change. A place at the front that was
+// kept longer than output.insert_order_wait is given up first, so a
+// recording whose transcription takes long doesn't hold up the others.
+func (q *insertQueue) waitForHead() {
+	if len(q.queue) > 1 && q.queue[0].reserved && !q.busy {
+		left := q.queue[0].since.Add(config.Output.insertOrderWait()).Sub(time.Now())
+		if left <= 0 {
+			q.remove(q.queue[0])
+			return
+		}
+		timer := time.AfterFunc(left, func() {
+			q.mu.Lock()
+			q.changed.Broadcast()
+			q.mu.Unlock()
+		})
+		defer timer.Stop()
+	}
+	q.changed.Wait()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// timeFields are the placeholders of journal timestamps and target paths,
+// with the time layout each one is replaced by.
+var timeFields = map[string]string{
+	"{date}":    time.DateOnly,
+	"{time}":    "15:04",
+	"{seconds}": "15:04:05",
+	"{weekday}": "Monday",
+	"{iso}":     time.RFC3339,
+}
+
+var placeholder = regexp.MustCompile(`\{[a-z]+\}`)
+
+// expandTime replaces the time placeholders in s with t.
+func expandTime(s string, t time.Time) string {
+	return placeholder.ReplaceAllStringFunc(s, func(field string) string {
+		if layout, ok := timeFields[field]; ok {
+			return t.Format(layout)
+		}
+		return field
+	})
+}
+
+// checkTimeFields reports a placeholder that isn't one of timeFields, most
+// likely a typo.
+func checkTimeFields(name, s string) error {
+	for _, field := range placeholder.FindAllString(s, -1) {
+		if _, ok := timeFields[field]; !ok {
+			return fmt.Errorf("%s has unknown placeholder %s, use {date}, {time}, {seconds}, {weekday} or {iso}", name, field)
+		}
+	}
+	return nil
+}
+
+// journalEntry puts the profile's timestamp in front of a dictation, for
+// keeping a work log or lab notes.
+func journalEntry(profile Profile, text string, now time.Time) string {
+	if profile.Timestamp == "" || strings.TrimSpace(text) == "" {
+		return text
+	}
+	return expandTime(profile.Timestamp, now) + text
+}
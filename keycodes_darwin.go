@@ -0,0 +1,49 @@
+//go:build darwin
+
+package main
+
+// defaultHotkey is the key bound to the default profile when the config
+// has no hotkeys.
+const defaultHotkey = "globe"
+
+const globeKeyCode = 179
+
+// keyCodes maps the key names usable in the config to macOS virtual key codes.
+var keyCodes = map[string]uint16{
+	"globe": globeKeyCode,
+	"f1":    122,
+	"f2":    120,
+	"f3":    99,
+	"f4":    118,
+	"f5":    96,
+	"f6":    97,
+	"f7":    98,
+	"f8":    100,
+	"f9":    101,
+	"f10":   109,
+	"f11":   103,
+	"f12":   111,
+	"f13":   105,
+	"f14":   107,
+	"f15":   113,
+	"f16":   106,
+	"f17":   64,
+	"f18":   79,
+	"f19":   80,
+
+	"a": 0, "b": 11, "c": 8, "d": 2, "e": 14, "f": 3, "g": 5, "h": 4, "i": 34,
+	"j": 38, "k": 40, "l": 37, "m": 46, "n": 45, "o": 31, "p": 35, "q": 12,
+	"r": 15, "s": 1, "t": 17, "u": 32, "v": 9, "w": 13, "x": 7, "y": 16, "z": 6,
+	"1": 18, "2": 19, "3": 20, "4": 21, "5": 23, "6": 22, "7": 26, "8": 28,
+	"9": 25, "0": 29,
+	"return": 36, "tab": 48, "space": 49, "escape": 53,
+}
+
+// modifierKeys maps the key codes of the modifier keys, left and right, to
+// their modifier.
+var modifierKeys = map[uint16]modifiers{
+	59: modCtrl, 62: modCtrl,
+	56: modShift, 60: modShift,
+	58: modAlt, 61: modAlt,
+	55: modCmd, 54: modCmd,
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+package main
+
+import "fmt"
+
+// There is no Globe key on PC keyboards, F9 starts and stops instead.
+const defaultHotkey = "f9"
+
+// Linux key codes are the evdev ones (linux/input-event-codes.h).
+
+// globeKeyCode is KEY_FN, which most keyboards handle in firmware and never
+// send.
+const globeKeyCode = 0x1d0
+
+var keyCodes = func() map[string]uint16 {
+	codes := map[string]uint16{
+		"globe": globeKeyCode,
+		"f11":   87, "f12": 88,
+		"return": 28, "tab": 15, "space": 57, "escape": 1,
+	}
+	for i := range 10 {
+		codes[fmt.Sprintf("f%d", i+1)] = uint16(59 + i) // KEY_F1..KEY_F10
+	}
+	for i := range 12 {
+		codes[fmt.Sprintf("f%d", i+13)] = uint16(183 + i) // KEY_F13..KEY_F24
+	}
+	// Letters follow the rows of the keyboard.
+	for row, keys := range map[uint16]string{16: "qwertyuiop", 30: "asdfghjkl", 44: "zxcvbnm"} {
+		for i, k := range keys {
+			codes[string(k)] = row + uint16(i)
+		}
+	}
+	for i, k := range "1234567890" {
+		codes[string(k)] = uint16(2 + i)
+	}
+	return codes
+}()
+
+var modifierKeys = map[uint16]modifiers{
+	29: modCtrl, 97: modCtrl,
+	42: modShift, 54: modShift,
+	56: modAlt, 100: modAlt,
+	125: modCmd, 126: modCmd,
+}
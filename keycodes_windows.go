@@ -0,0 +1,38 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+// There is no Globe key on PC keyboards, F9 starts and stops instead.
+const defaultHotkey = "f9"
+
+// Key codes on Windows are virtual key codes, which is what RegisterHotKey
+// takes.
+
+// globeKeyCode doesn't exist on Windows; 0xff is reserved and never sent.
+const globeKeyCode = 0xff
+
+var keyCodes = func() map[string]uint16 {
+	codes := map[string]uint16{
+		"globe":  globeKeyCode,
+		"return": 0x0d, "tab": 0x09, "space": 0x20, "escape": 0x1b,
+	}
+	for i := range 24 {
+		codes[fmt.Sprintf("f%d", i+1)] = uint16(0x70 + i) // VK_F1..VK_F24
+	}
+	for k := 'a'; k <= 'z'; k++ {
+		codes[string(k)] = uint16(k - 'a' + 'A')
+	}
+	for k := '0'; k <= '9'; k++ {
+		codes[string(k)] = uint16(k)
+	}
+	return codes
+}()
+
+var modifierKeys = map[uint16]modifiers{
+	0xa2: modCtrl, 0xa3: modCtrl,
+	0xa0: modShift, 0xa1: modShift,
+	0xa4: modAlt, 0xa5: modAlt,
+	0x5b: modCmd, 0x5c: modCmd,
+}
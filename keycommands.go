@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// keyPress is a key with the modifiers held, as the Injector's TapKey
+// takes it.
+type keyPress struct {
+	Key       string
+	Modifiers []string
+}
+
+func (k keyPress) String() string {
+	return strings.Join(append(append([]string{}, k.Modifiers...), k.Key), "+")
+}
+
+// keyAliases are other names accepted for keys and modifiers in the
+// config.
+var keyAliases = map[string]string{
+	"return":  "enter",
+	"option":  "alt",
+	"command": "cmd",
+	"control": "ctrl",
+	"esc":     "escape",
+}
+
+// parseKeyPress parses a key command's or an after_insert action's key,
+// such as "enter", "tab" or "cmd+enter". Only the keys every Injector
+// knows are accepted.
+func parseKeyPress(s string) (keyPress, error) {
+	parts := strings.Split(strings.ToLower(strings.ReplaceAll(s, " ", "")), "+")
+	for i, p := range parts {
+		if alias, ok := keyAliases[p]; ok {
+			parts[i] = alias
+		}
+	}
+	k := keyPress{Key: parts[len(parts)-1], Modifiers: parts[:len(parts)-1]}
+	switch k.Key {
+	case "enter", "tab", "escape", "left", "right", "up", "down":
+	default:
+		if !(len(k.Key) == 1 && 'a' <= k.Key[0] && k.Key[0] <= 'z') {
+			return k, fmt.Errorf("unknown key %q in %q, use enter, tab, escape, an arrow (left...) or a letter", k.Key, s)
+		}
+	}
+	for _, m := range k.Modifiers {
+		switch m {
+		case "cmd", "ctrl", "shift", "alt":
+		default:
+			return k, fmt.Errorf("unknown modifier %q in %q, use cmd, ctrl, shift or alt", m, s)
+		}
+	}
+	return k, nil
+}
+
+// keyCommands returns the key commands in effect in an app, phrase to key:
+// output.key_commands with the app's own on top, where an empty key turns
+// a phrase off. terminal_safe apps only get their own, so a shell never
+// gets a Return it wasn't set up for.
+func (c Config) keyCommands(bundleID string) map[string]string {
+	app := c.app(bundleID)
+	commands := map[string]string{}
+	if !app.TerminalSafe {
+		for phrase, key := range c.Output.KeyCommands {
+			commands[strings.ToLower(phrase)] = key
+		}
+	}
+	for phrase, key := range app.KeyCommands {
+		commands[strings.ToLower(phrase)] = key
+	}
+	for phrase, key := range commands {
+		if key == "" {
+			delete(commands, phrase)
+		}
+	}
+	return commands
+}
+
+// splitKeyCommand takes a key command said at the end of a dictation off
+// it: "…see you tomorrow, send it" or "…see you tomorrow. Send it." The
+// phrase has to be set off by punctuation, or be all there is, so a
+// sentence that merely ends with it ("I'll review the draft and send it")
+// stays text. It returns the rest of the text and the key to press after
+// inserting it, nil when the dictation doesn't end with one. The longest
+// phrase that matches wins.
+func splitKeyCommand(text, bundleID string) (string, *keyPress) {
+	commands := config.keyCommands(bundleID)
+	if len(commands) == 0 {
+		return text, nil
+	}
+	phrases := make([]string, 0, len(commands))
+	for phrase := range commands {
+		phrases = append(phrases, phrase)
+	}
+	sort.Slice(phrases, func(i, j int) bool { return len(phrases[i]) > len(phrases[j]) })
+
+	trimmed := strings.TrimRightFunc(text, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) })
+	for _, phrase := range phrases {
+		re := regexp.MustCompile(`(?i)(?:^\s*|[,;:.!?]\s*)` + strings.ReplaceAll(regexp.QuoteMeta(strings.Join(strings.Fields(phrase), " ")), " ", `[\s,]+`) + `$`)
+		loc := re.FindStringIndex(trimmed)
+		if loc == nil {
+			continue
+		}
+		// The key was validated with the config.
+		key, _ := parseKeyPress(commands[phrase])
+		rest := strings.TrimRightFunc(trimmed[:loc[0]], unicode.IsSpace)
+		if end := trimmed[loc[0]]; rest != "" && (end == '.' || end == '!' || end == '?') {
+			// "…tomorrow. Send it" keeps the end of its sentence.
+			rest += string(end)
+		}
+		if i := strings.IndexAny(text[len(trimmed):], ".!?"); i >= 0 && rest != "" && !strings.ContainsAny(rest[len(rest)-1:], ".!?") {
+			// Keep the end of the sentence, which went after the command.
+			rest += text[len(trimmed)+i : len(trimmed)+i+1]
+		}
+		return rest, &key
+	}
+	return text, nil
+}
+
+// pressKeyCommand presses the key of a key command.
+func pressKeyCommand(key *keyPress) {
+	fmt.Printf("Pressing %s\n", key)
+	if err := injector.TapKey(key.Key, key.Modifiers...); err != nil {
+		fmt.Printf("Warning: pressing %s failed: %v\n", key, err)
+	}
+}
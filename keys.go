@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// api_key_selection settings, how a provider with several API keys picks
+// one.
+const (
+	keyFailover   = "failover"    // the first one that isn't rate limited
+	keyRoundRobin = "round_robin" // each in turn, skipping rate limited ones
+)
+
+// keyCooldown is how long a key that got a 429 is passed over when the
+// provider didn't say how long to wait.
+const keyCooldown = time.Minute
+
+// APIKey is one of a provider's API keys, from an environment variable, a
+// command or a file. A file is read on every request, so a rotated key is
+// picked up without a restart.
+type APIKey struct {
+	APIKeyEnv     string   `json:"api_key_env"`
+	APIKeyCommand []string `json:"api_key_command"`
+	APIKeyFile    string   `json:"api_key_file"`
+}
+
+func (k APIKey) lookup() (string, error) {
+	if k.APIKeyFile != "" {
+		path, err := expandHome(k.APIKeyFile)
+		if err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading API key: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return lookupSecret(k.APIKeyEnv, k.APIKeyCommand)
+}
+
+func (k APIKey) check() error {
+	if k.APIKeyFile == "" {
+		return checkSecret(k.APIKeyEnv, k.APIKeyCommand)
+	}
+	key, err := k.lookup()
+	if err == nil && key == "" {
+		err = errors.New(k.APIKeyFile + " is empty")
+	}
+	return err
+}
+
+// forget drops what the key's command printed, so the next request runs it
+// again for a new key.
+func (k APIKey) forget() {
+	if len(k.APIKeyCommand) > 0 {
+		secrets.Delete(strings.Join(k.APIKeyCommand, "\x00"))
+	}
+}
+
+func (k APIKey) String() string {
+	switch {
+	case k.APIKeyFile != "":
+		return k.APIKeyFile
+	case len(k.APIKeyCommand) > 0:
+		return k.APIKeyCommand[0]
+	}
+	return k.APIKeyEnv
+}
+
+// keys returns the provider's API keys: api_keys, or else the one of
+// api_key_env and api_key_command.
+func (p Provider) keys() []APIKey {
+	if len(p.APIKeys) > 0 {
+		return p.APIKeys
+	}
+	return []APIKey{{APIKeyEnv: p.APIKeyEnv, APIKeyCommand: p.APIKeyCommand}}
+}
+
+// keyRing remembers, per provider, which key is next and which are rate
+// limited.
+type keyRing struct {
+	mu        sync.Mutex
+	next      map[string]int
+	limitedTo map[string]map[int]time.Time
+}
+
+var apiKeys = &keyRing{next: map[string]int{}, limitedTo: map[string]map[int]time.Time{}}
+
+// pick returns the key to use for a request to the named provider, and its
+// index for reporting back. When all keys are rate limited it returns the
+// one that is free again first.
+func (r *keyRing) pick(name string, p Provider) (int, APIKey) {
+	keys := p.keys()
+	if len(keys) == 1 {
+		return 0, keys[0]
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := 0
+	if p.APIKeySelection == keyRoundRobin {
+		start = r.next[name] % len(keys)
+	}
+	now := time.Now()
+	best := -1
+	for n := range keys {
+		i := (start + n) % len(keys)
+		until := r.limitedTo[name][i]
+		if !until.After(now) {
+			best = i
+			break
+		}
+		if best < 0 || until.Before(r.limitedTo[name][best]) {
+			best = i
+		}
+	}
+	r.next[name] = best + 1
+	return best, keys[best]
+}
+
+// rateLimited records a 429 for a key and reports whether another key is
+// free to retry with right away.
+func (r *keyRing) rateLimited(name string, p Provider, index int, retryAfter time.Duration) bool {
+	keys := p.keys()
+	if len(keys) == 1 {
+		return false
+	}
+	if retryAfter <= 0 {
+		retryAfter = keyCooldown
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limitedTo[name] == nil {
+		r.limitedTo[name] = map[int]time.Time{}
+	}
+	now := time.Now()
+	r.limitedTo[name][index] = now.Add(retryAfter)
+	for i := range keys {
+		if !r.limitedTo[name][i].After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// setAuthKey adds an API key to a request the way the provider takes it.
+// Azure takes the key in an api-key header.
+func (p Provider) setAuthKey(h http.Header, key string) {
+	switch {
+	case key == "":
+	case p.Type == providerAzure:
+		h.Set("api-key", key)
+	default:
+		h.Set("Authorization", "Bearer "+key)
+	}
+}
@@ -0,0 +1,171 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	hook "github.com/robotn/gohook"
+)
+
+const (
+	// tapCheckInterval is how often the event tap is checked.
+	tapCheckInterval = 5 * time.Second
+	// maxRehooks is how often a dead tap is re-registered in a row before
+	// giving up and telling the user.
+	maxRehooks = 3
+)
+
+// startKeyListener delivers the key presses of all apps through gohook's
+// event tap. macOS disables taps now and then (after permission changes or
+// a long sleep) without telling the owner, so the tap is watched and
+// registered again when it stops delivering.
+func startKeyListener() (<-chan keyEvent, func(), error) {
+	keys := make(chan keyEvent, 64)
+	stop := make(chan struct{})
+	rehook := make(chan struct{}, 1)
+	var lastEvent atomic.Int64 // unix nanos of the last event from the tap
+
+	go watchEventTap(&lastEvent, rehook, stop)
+	go func() {
+		defer close(keys)
+		for {
+			restart := forwardKeys(hook.Start(), keys, &lastEvent, rehook, stop)
+			hook.End()
+			if !restart {
+				return
+			}
+			fmt.Println("Keyboard event tap stopped delivering key presses, registering it again")
+		}
+	}()
+
+	var once sync.Once
+	return keys, func() { once.Do(func() { close(stop) }) }, nil
+}
+
+// forwardKeys passes key events on until the listener is stopped, or the
+// watcher asks for a new tap, in which case it returns true.
+//
+// gohook reports a press as KeyHold, followed by a KeyDown for the typed
+// character, and repeats KeyHold while the key is held. Only the first
+// KeyHold of a press is passed on, so every press arrives once.
+func forwardKeys(events chan hook.Event, keys chan<- keyEvent, lastEvent *atomic.Int64, rehook, stop <-chan struct{}) bool {
+	pressed := map[uint16]bool{}
+	for {
+		var ev hook.Event
+		select {
+		case <-stop:
+			return false
+		case <-rehook:
+			return true
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			ev = e
+		}
+		lastEvent.Store(time.Now().UnixNano())
+
+		var key keyEvent
+		switch ev.Kind {
+		case hook.KeyHold:
+			if pressed[ev.Rawcode] {
+				continue
+			}
+			pressed[ev.Rawcode] = true
+			key = keyEvent{code: ev.Rawcode, down: true}
+		case hook.KeyUp:
+			delete(pressed, ev.Rawcode)
+			key = keyEvent{code: ev.Rawcode}
+		default:
+			continue
+		}
+		select {
+		case keys <- key:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// watchEventTap compares when the system last saw a key press with when the
+// tap last delivered one. If a press never arrived, the tap is dead and a
+// new one is requested.
+func watchEventTap(lastEvent *atomic.Int64, rehook chan<- struct{}, stop <-chan struct{}) {
+	ticker := time.NewTicker(tapCheckInterval)
+	defer ticker.Stop()
+
+	var lastRehook, lastSecure time.Time
+	failures := 0
+	warnedPermission := false
+	secure := false
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if !accessibilityTrusted() {
+			if !warnedPermission {
+				fmt.Println("Error: accessibility permission was revoked, hotkeys won't work")
+				notify(tr("Dictation hotkeys stopped"), tr("Accessibility permission was revoked. Allow it again in System Settings > Privacy & Security > Accessibility."))
+				warnedPermission = true
+			}
+			continue
+		}
+		warnedPermission = false
+
+		// Secure Event Input keeps presses from the tap on purpose. Those
+		// typed until the check after it ended don't count.
+		if secureEventInput() {
+			secure = true
+			continue
+		}
+		if secure {
+			secure, lastSecure = false, time.Now()
+			continue
+		}
+
+		since, ok := secondsSinceLastKeyPress()
+		if !ok {
+			return
+		}
+		lastKey := time.Now().Add(-time.Duration(since * float64(time.Second)))
+		last := time.Unix(0, lastEvent.Load())
+		if last.After(lastRehook) {
+			// The current tap delivered something, it works.
+			failures = 0
+		}
+
+		// The tap gets a second to deliver a press before it counts as
+		// missed.
+		baseline := last
+		if lastRehook.After(baseline) {
+			baseline = lastRehook
+		}
+		if lastSecure.After(baseline) {
+			baseline = lastSecure
+		}
+		if !lastKey.After(baseline.Add(time.Second)) || time.Since(lastKey) < time.Second {
+			continue
+		}
+
+		failures++
+		if failures > maxRehooks {
+			if failures == maxRehooks+1 {
+				fmt.Println("Error: the keyboard event tap can't be restored")
+				notify(tr("Dictation hotkeys stopped"), tr("The keyboard event tap can't be restored. Restart dictation."))
+			}
+			continue
+		}
+		lastRehook = time.Now()
+		select {
+		case rehook <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// startKeyListener reads the keyboards' evdev devices, which works the same
+// on X11 and Wayland (where apps can't see each other's key presses). It
+// needs read access to /dev/input, usually by being in the input group.
+func startKeyListener() (<-chan keyEvent, func(), error) {
+	paths, err := keyboardDevices()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []*os.File
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if errors.Is(err, fs.ErrPermission) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening %s: %w", path, err)
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, nil, errors.New("no keyboard device readable, add yourself to the input group (sudo usermod -aG input $USER) and log in again")
+	}
+
+	keys := make(chan keyEvent, 64)
+	var wg sync.WaitGroup
+	for _, f := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readKeyEvents(f, keys)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(keys)
+	}()
+
+	stop := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	return keys, stop, nil
+}
+
+// keyboardDevices lists the event devices of keyboards: devices with the
+// kbd handler that support key repeat, which leaves out power buttons and
+// the like.
+func keyboardDevices() ([]string, error) {
+	data, err := os.ReadFile("/proc/bus/input/devices")
+	if err != nil {
+		return nil, fmt.Errorf("listing input devices: %w", err)
+	}
+
+	const evKey, evRep = 1 << 1, 1 << 20
+	var paths []string
+	for _, block := range strings.Split(string(data), "\n\n") {
+		var handlers []string
+		var ev uint64
+		for _, line := range strings.Split(block, "\n") {
+			if h, ok := strings.CutPrefix(line, "H: Handlers="); ok {
+				handlers = strings.Fields(h)
+			}
+			if e, ok := strings.CutPrefix(line, "B: EV="); ok {
+				ev, _ = strconv.ParseUint(strings.TrimSpace(e), 16, 64)
+			}
+		}
+		if ev&evKey == 0 || ev&evRep == 0 || !strings.Contains(strings.Join(handlers, " "), "kbd") {
+			continue
+		}
+		for _, h := range handlers {
+			if strings.HasPrefix(h, "event") {
+				paths = append(paths, "/dev/input/"+h)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no keyboard found in /proc/bus/input/devices")
+	}
+	return paths, nil
+}
+
+// readKeyEvents reads struct input_event records until the device is closed:
+// a timeval (two longs), then type, code and value.
+func readKeyEvents(f *os.File, keys chan<- keyEvent) {
+	const evKeyType = 1
+	timeSize := 2 * strconv.IntSize / 8
+	buf := make([]byte, timeSize+8)
+	for {
+		if _, err := f.Read(buf); err != nil {
+			return
+		}
+		typ := binary.NativeEndian.Uint16(buf[timeSize:])
+		code := binary.NativeEndian.Uint16(buf[timeSize+2:])
+		value := int32(binary.NativeEndian.Uint32(buf[timeSize+4:]))
+		// Values are 1 for a press, 0 for a release and 2 for auto-repeat,
+		// which is left out so a held hotkey doesn't toggle over and over.
+		if typ != evKeyType || value == 2 {
+			continue
+		}
+		keys <- keyEvent{code: code, down: value == 1}
+	}
+}
@@ -0,0 +1,93 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"unsafe"
+)
+
+// startKeyListener registers the configured hotkeys with RegisterHotKey,
+// which, unlike a keyboard hook, needs no special permission and only ever
+// sees these keys. Windows reports presses only, so each one is delivered as
+// a press immediately followed by a release, which means hotkeys with a
+// hold never fire. Ctrl+C reaches the console as an interrupt instead.
+func startKeyListener() (<-chan keyEvent, func(), error) {
+	keys := make(chan keyEvent, 64)
+	started := make(chan error, 1)
+	var threadID uintptr
+
+	go func() {
+		// Hotkeys belong to the thread that registered them, which then
+		// has to run the message loop.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(keys)
+
+		threadID, _, _ = procGetCurrentThreadId.Call()
+
+		// Every chord of every hotkey is registered, with its modifiers.
+		var chords []chord
+		for _, h := range config.Trigger.Hotkeys {
+			hk, _ := parseHotkey(h) // checked by validate
+			for _, c := range hk.steps {
+				if c.key == globeKeyCode || slices.Contains(chords, c) {
+					continue
+				}
+				id := uintptr(len(chords) + 1)
+				if ok, _, err := procRegisterHotKey.Call(0, id, modNoRepeat|win32Modifiers(c.mods), uintptr(c.key)); ok == 0 {
+					started <- fmt.Errorf("registering %s: %w (is another app using it?)", h.Key, err)
+					return
+				}
+				defer procUnregisterHotKey.Call(0, id)
+				chords = append(chords, c)
+			}
+		}
+		if len(chords) == 0 {
+			started <- errors.New("no hotkey that exists on Windows is configured")
+			return
+		}
+		started <- nil
+
+		var m msg
+		for {
+			ret, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+			if ret == 0 || int32(ret) == -1 { // WM_QUIT or error
+				return
+			}
+			if m.message == wmHotkey && m.wParam >= 1 && int(m.wParam) <= len(chords) {
+				c := chords[m.wParam-1]
+				keys <- keyEvent{code: c.key, down: true, mods: c.mods}
+				keys <- keyEvent{code: c.key, mods: c.mods}
+			}
+		}
+	}()
+
+	if err := <-started; err != nil {
+		return nil, nil, err
+	}
+	stop := func() {
+		procPostThreadMessageW.Call(threadID, wmQuit, 0, 0)
+	}
+	return keys, stop, nil
+}
+
+func win32Modifiers(mods modifiers) uintptr {
+	var m uintptr
+	if mods&modAlt != 0 {
+		m |= 0x0001 // MOD_ALT
+	}
+	if mods&modCtrl != 0 {
+		m |= 0x0002 // MOD_CONTROL
+	}
+	if mods&modShift != 0 {
+		m |= 0x0004 // MOD_SHIFT
+	}
+	if mods&modCmd != 0 {
+		m |= 0x0008 // MOD_WIN
+	}
+	return m
+}
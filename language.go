@@ -0,0 +1,64 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// layoutLanguages lists the keyboard layouts typed with each language, by
+// the last part of the layout ID in lower case ("com.apple.keylayout.
+// Spanish-ISO" is "spanish-iso"). Layouts used for many languages (Dvorak,
+// Colemak) aren't in it.
+var layoutLanguages = map[string][]string{
+	"en": {"us", "abc", "usextended", "usinternational-pc", "british", "british-pc", "australian", "irish", "canadian"},
+	"es": {"spanish", "spanish-iso", "latinamerican"},
+	"fr": {"french", "french-pc", "french-numerical", "canadian-csa", "canadianfrench-pc", "swissfrench", "belgian"},
+	"de": {"german", "swissgerman", "austrian"},
+	"it": {"italian", "italian-pro"},
+	"pt": {"portuguese", "brazilian", "brazilian-abnt2", "brazilian-pro"},
+	"nl": {"dutch"},
+	"sv": {"swedish", "swedish-pro"},
+	"no": {"norwegian"},
+	"da": {"danish"},
+	"fi": {"finnish"},
+	"is": {"icelandic"},
+	"pl": {"polish", "polishpro"},
+	"cs": {"czech", "czech-qwerty"},
+	"sk": {"slovak", "slovak-qwerty"},
+	"hu": {"hungarian", "hungarian-qwerty"},
+	"ro": {"romanian"},
+	"hr": {"croatian"},
+	"sl": {"slovenian"},
+	"sr": {"serbian"},
+	"bg": {"bulgarian"},
+	"tr": {"turkish", "turkish-qwerty", "turkish-qwerty-pc"},
+	"el": {"greek"},
+	"ru": {"russian", "russian-phonetic", "russianwin"},
+	"uk": {"ukrainian", "ukrainian-pc"},
+	"he": {"hebrew", "hebrew-qwerty"},
+	"ar": {"arabic", "arabic-qwerty"},
+	"fa": {"persian"},
+	"hi": {"hindi", "devanagari-qwerty"},
+	"th": {"thai"},
+	"vi": {"vietnamese"},
+}
+
+// inputSourceLanguage returns the language (ISO-639-1) of the active
+// keyboard layout, from input_languages or the built-in table, or "" when
+// it isn't known.
+func inputSourceLanguage() string {
+	layout := currentKeyboardLayout()
+	if layout == "" {
+		return ""
+	}
+	if lang, ok := config.InputLanguages[layout]; ok {
+		return lang
+	}
+	name := strings.ToLower(layout[strings.LastIndex(layout, ".")+1:])
+	for lang, layouts := range layoutLanguages {
+		if slices.Contains(layouts, name) {
+			return lang
+		}
+	}
+	return ""
+}
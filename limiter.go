@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// apiLimiter caps how many provider requests run at once and how many start
+// per minute. All API calls go through the same limiter, so retries and
+// parallel uploads share one budget.
+type apiLimiter struct {
+	slots chan struct{} // nil when in-flight requests aren't limited
+	// queued counts the requests waiting or in flight.
+	queued atomic.Int32
+
+	mu        sync.Mutex
+	perMinute int
+	started   []time.Time // start times within the last minute, oldest first
+}
+
+func newAPILimiter(cfg LimitsConfig) *apiLimiter {
+	l := &apiLimiter{perMinute: cfg.RequestsPerMinute}
+	if cfg.MaxInFlight > 0 {
+		l.slots = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return l
+}
+
+var limiter = newAPILimiter(LimitsConfig{})
+
+// acquire blocks until a request may be sent. The returned function must be
+// called once the request is done.
+func (l *apiLimiter) acquire() (release func()) {
+	l.queued.Add(1)
+	if l.slots != nil {
+		l.slots <- struct{}{}
+	}
+	l.waitForRate()
+
+	return func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+		l.queued.Add(-1)
+	}
+}
+
+func (l *apiLimiter) waitForRate() {
+	if l.perMinute <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for {
+		now := time.Now()
+		for len(l.started) > 0 && now.Sub(l.started[0]) >= time.Minute {
+			l.started = l.started[1:]
+		}
+		if len(l.started) < l.perMinute {
+			l.started = append(l.started, now)
+			return
+		}
+
+		wait := time.Minute - now.Sub(l.started[0])
+		fmt.Printf("Request limit of %d per minute reached, waiting %s\n", l.perMinute, wait.Round(time.Second))
+		// Holding the lock while sleeping is deliberate: requests queue up
+		// behind this one in order.
+		time.Sleep(wait)
+	}
+}
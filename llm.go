@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultCleanupPrompt is the system prompt of the cleanup stage when
+// llm.cleanup_prompt isn't set.
+const defaultCleanupPrompt = `You clean up dictated text. Fix punctuation, capitalization and obvious transcription mistakes, remove filler words and false starts, and break long text into paragraphs where the topic changes. Keep the wording, meaning, language and tone of the speaker; don't summarize, answer, or add anything. Reply with the cleaned up text only.`
+
+// llmTimeout bounds a cleanup request, so a hanging endpoint can't hold up
+// the dictation forever.
+const llmTimeout = 60 * time.Second
+
+// cleanupStage runs the LLM cleanup of a profile, with the review dialog
+// if the profile asks for it.
+func cleanupStage(text string, profile Profile) string {
+	cleaned := cleanupText(text, config.llm(profile))
+	if profile.CleanupReview {
+		cleaned = reviewCleanup(text, cleaned)
+	}
+	return cleaned
+}
+
+// cleanupText has the LLM rewrite a transcription. On failure the text is
+// returned as is, with a warning: a dictation that isn't cleaned up is
+// better than a lost one.
+func cleanupText(text string, llm LLMConfig) string {
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	prompt := llm.CleanupPrompt
+	if prompt == "" {
+		prompt = defaultCleanupPrompt
+	}
+
+	started := time.Now()
+	cleaned, err := chatCompletion(llm, prompt, text)
+	if err != nil {
+		fmt.Printf("Warning: LLM cleanup failed, using the transcription as is: %v\n", err)
+		return text
+	}
+	fmt.Printf("Cleaned up in %dms\n", time.Since(started).Milliseconds())
+	return cleaned
+}
+
+// chatCompletion sends a system and a user message to an OpenAI compatible
+// chat completions endpoint, or Ollama, and returns the reply.
+func chatCompletion(llm LLMConfig, system, user string) (string, error) {
+	if llm.Type == llmOllama {
+		// Local, the API limits don't apply.
+		return ollamaChat(llm, system, user)
+	}
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	body, err := json.Marshal(struct {
+		Model       string    `json:"model"`
+		Messages    []message `json:"messages"`
+		Temperature *float64  `json:"temperature,omitempty"`
+	}{
+		Model:       llm.Model,
+		Messages:    []message{{"system", system}, {"user", user}},
+		Temperature: llm.Temperature,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encoding request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", llm.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := llm.apiKey(); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	release := limiter.acquire()
+	defer release()
+
+	client := &http.Client{Timeout: llmTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("LLM returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("LLM returned no choices")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	localeEnglish = "en"
+	// localeAuto follows the language of the system.
+	localeAuto = "auto"
+)
+
+// locale holds what dictation says and understands in a language other
+// than English. English is always understood as well: its symbol names and
+// "send to" keep working, as code is often talked about in English anyway.
+type locale struct {
+	// Messages translate the notifications and dialogs, keyed by the
+	// English text as it is passed to tr, format verbs included.
+	Messages map[string]string
+	// Symbols are the spoken names of symbols in code mode, lower case.
+	Symbols map[string]string
+	// SendTo matches "send to <target>" at the start of a dictation, with
+	// the rest in the first group.
+	SendTo *regexp.Regexp
+	// Casing are casing commands of voice_commands, phrase to the style
+	// of the English one ("upper" for "upper case"), and EndCase the
+	// locale's "end case".
+	Casing  map[string]string
+	EndCase string
+}
+
+var systemLocale = sync.OnceValue(func() string {
+	var tag string
+	if runtime.GOOS == "darwin" {
+		if out, err := exec.Command("defaults", "read", "-g", "AppleLocale").Output(); err == nil {
+			tag = strings.TrimSpace(string(out))
+		}
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if tag != "" {
+			break
+		}
+		tag = os.Getenv(name)
+	}
+	// "de_DE.UTF-8", "fr-CA" and "es" all come down to the language.
+	lang, _, _ := strings.Cut(strings.ToLower(tag), "_")
+	lang, _, _ = strings.Cut(lang, "-")
+	lang, _, _ = strings.Cut(lang, ".")
+	if _, ok := locales[lang]; ok {
+		return lang
+	}
+	return localeEnglish
+})
+
+// activeLocale returns the language set with locale, the system's for
+// "auto", English if it isn't one of locales.
+func activeLocale() string {
+	if config.Locale == localeAuto {
+		return systemLocale()
+	}
+	if _, ok := locales[config.Locale]; ok {
+		return config.Locale
+	}
+	return localeEnglish
+}
+
+// tr translates a notification or dialog text into the active locale and
+// formats it with args like fmt.Sprintf. Texts without a translation stay
+// in English.
+func tr(format string, args ...any) string {
+	if l, ok := locales[activeLocale()]; ok {
+		if translated, ok := l.Messages[format]; ok {
+			format = translated
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+func checkLocale(name string) error {
+	if _, ok := locales[name]; ok || name == localeEnglish || name == localeAuto {
+		return nil
+	}
+	names := []string{localeEnglish}
+	for n := range locales {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	return fmt.Errorf("locale must be \"auto\" or one of %s, got %q", strings.Join(names, ", "), name)
+}
@@ -0,0 +1,308 @@
+package main
+
+import "regexp"
+
+// locales are the languages besides English, by their ISO 639-1 code. To
+// add one, translate the messages of an existing one; a message left out
+// stays in English.
+var locales = map[string]locale{
+	"de": {
+		Messages: map[string]string{
+			"Dictation":                         "Diktat",
+			"Dictation: %s":                     "Diktat: %s",
+			"Dictation can't record":            "Diktat kann nicht aufnehmen",
+			"Dictation: low quality microphone": "Diktat: Mikrofon mit niedriger Qualität",
+			"Dictation: microphone muted":       "Diktat: Mikrofon stummgeschaltet",
+			"%s is muted or turned all the way down, unmute it and record again": "%s ist stummgeschaltet oder ganz leise gestellt, Stummschaltung aufheben und erneut aufnehmen",
+			"The input device":                 "Das Eingabegerät",
+			"Dictation budget reached":         "Diktat-Budget erreicht",
+			"Dictation: disk almost full":      "Diktat: Festplatte fast voll",
+			"Dictation not inserted":           "Diktat nicht eingefügt",
+			"Dictation hotkeys stopped":        "Diktat-Tastenkürzel gestoppt",
+			"Dictation failed":                 "Diktat fehlgeschlagen",
+			"Dictation lost audio":             "Diktat hat Audio verloren",
+			"Still recording":                  "Aufnahme läuft noch",
+			"Dictation provider problem":       "Problem mit dem Diktat-Anbieter",
+			"Dictation recovered":              "Diktat wiederhergestellt",
+			"Dictation recovered from a crash": "Diktat nach Absturz wiederhergestellt",
+			"Dictation rate limited":           "Diktat: Anfragelimit erreicht",
+			"Dictation update available":       "Diktat-Update verfügbar",
+			"Recording":                        "Aufnahme",
+			"Transcribing":                     "Transkription",
+			"Inserted":                         "Eingefügt",
+
+			"The answer is on the clipboard":                                                                                "Die Antwort ist in der Zwischenablage",
+			"No audio input is available: %v":                                                                               "Kein Audioeingang verfügbar: %v",
+			"%s records at %.0f kHz over Bluetooth, transcriptions will be less accurate":                                   "%s nimmt über Bluetooth mit %.0f kHz auf, Transkriptionen werden ungenauer",
+			"Spent an estimated $%.2f today and $%.2f this month, over the budget.":                                         "Heute geschätzt $%.2f und diesen Monat $%.2f ausgegeben, über dem Budget.",
+			" Using profile %s instead.":                                                                                    " Stattdessen wird das Profil %s verwendet.",
+			"The recording was stopped so it can still be saved":                                                            "Die Aufnahme wurde beendet, damit sie noch gespeichert werden kann",
+			"Only %s left, long recordings may not fit":                                                                     "Nur noch %s frei, lange Aufnahmen passen eventuell nicht",
+			"Focus moved to another app; the text is kept for the reinsert hotkey: %s":                                      "Der Fokus ist zu einer anderen App gewechselt; der Text wird für das Tastenkürzel zum erneuten Einfügen aufbewahrt: %s",
+			"It looks like a Whisper hallucination, press the reinsert hotkey to type it anyway: %s":                        "Das sieht nach einer Whisper-Halluzination aus, drücke das Tastenkürzel zum erneuten Einfügen, um es trotzdem zu tippen: %s",
+			"Accessibility permission was revoked. Allow it again in System Settings > Privacy & Security > Accessibility.": "Die Berechtigung für Bedienungshilfen wurde entzogen. Erlaube sie wieder unter Systemeinstellungen > Datenschutz & Sicherheit > Bedienungshilfen.",
+			"The keyboard event tap can't be restored. Restart dictation.":                                                  "Die Tastaturüberwachung kann nicht wiederhergestellt werden. Starte das Diktat neu.",
+			"The assistant didn't answer: %s":                                                                               "Der Assistent hat nicht geantwortet: %s",
+			"Sending to %s failed, the text is on the clipboard":                                                            "Senden an %s fehlgeschlagen, der Text ist in der Zwischenablage",
+			"Audio was lost %d times, the transcription may be missing words":                                               "Audio ging %d-mal verloren, in der Transkription können Wörter fehlen",
+			"Dictation has been recording for %s":                                                                           "Das Diktat nimmt seit %s auf",
+			"An earlier dictation that wasn't delivered is on the clipboard: %s":                                            "Ein früheres, nicht eingefügtes Diktat ist in der Zwischenablage: %s",
+			"%s is a remote session; press the reinsert hotkey to type it there: %s":                                        "%s ist eine Fernsitzung; drücke das Tastenkürzel zum erneuten Einfügen, um es dort zu tippen: %s",
+			"The %s crashed and is restarted":                                                                               "%s ist abgestürzt und wird neu gestartet",
+			"Copied to the clipboard":                                                                                       "In die Zwischenablage kopiert",
+			"Sent to %s":                                                                                                    "An %s gesendet",
+			"%s is rate limiting, retrying in %ds":                                                                          "%s begrenzt die Anfragen, neuer Versuch in %d s",
+			"%s is available, you have %s":                                                                                  "%s ist verfügbar, du hast %s",
+
+			"Dictation: focus changed":          "Diktat: Fokus gewechselt",
+			"Dictation: possible hallucination": "Diktat: mögliche Halluzination",
+			"Dictation: type into %s?":          "Diktat: in %s tippen?",
+			"Send the dictated message?":        "Die diktierte Nachricht senden?",
+			"Review cleanup":                    "Bereinigung prüfen",
+			"Use original":                      "Original verwenden",
+			"Cancel":                            "Abbrechen",
+			"Copy":                              "Kopieren",
+			"Insert":                            "Einfügen",
+		},
+		Symbols: map[string]string{
+			"klammer auf":             "(",
+			"klammer zu":              ")",
+			"eckige klammer auf":      "[",
+			"eckige klammer zu":       "]",
+			"geschweifte klammer auf": "{",
+			"geschweifte klammer zu":  "}",
+			"gleich gleich":           "==",
+			"ist gleich":              "=",
+			"ungleich":                "!=",
+			"kleiner gleich":          "<=",
+			"größer gleich":           ">=",
+			"kleiner als":             "<",
+			"größer als":              ">",
+			"und und":                 "&&",
+			"oder oder":               "||",
+			"ausrufezeichen":          "!",
+			"fragezeichen":            "?",
+			"stern":                   "*",
+			"schrägstrich":            "/",
+			"unterstrich":             "_",
+			"bindestrich":             "-",
+			"punkt":                   ".",
+			"komma":                   ",",
+			"doppelpunkt":             ":",
+			"semikolon":               ";",
+			"strichpunkt":             ";",
+			"raute":                   "#",
+			"prozent":                 "%",
+			"klammeraffe":             "@",
+			"anführungszeichen":       `"`,
+			"apostroph":               "'",
+			"neue zeile":              "\n",
+			"tabulator":               "\t",
+		},
+		SendTo: regexp.MustCompile(`(?i)^\s*(?:schicke?|sende)(?: das| dies)? an (?:den |die |das |meinen |meine |mein )?(.+)$`),
+		Casing: map[string]string{
+			"großbuchstaben":  "upper",
+			"kleinbuchstaben": "lower",
+			"titelschreibung": "title",
+		},
+		EndCase: "ende schreibweise",
+	},
+	"fr": {
+		Messages: map[string]string{
+			"Dictation":                         "Dictée",
+			"Dictation: %s":                     "Dictée : %s",
+			"Dictation can't record":            "La dictée ne peut pas enregistrer",
+			"Dictation: low quality microphone": "Dictée : micro de faible qualité",
+			"Dictation: microphone muted":       "Dictée : micro coupé",
+			"%s is muted or turned all the way down, unmute it and record again": "%s est coupé ou réglé au minimum, réactivez-le et enregistrez à nouveau",
+			"The input device":                 "Le périphérique d'entrée",
+			"Dictation budget reached":         "Budget de dictée atteint",
+			"Dictation: disk almost full":      "Dictée : disque presque plein",
+			"Dictation not inserted":           "Dictée non insérée",
+			"Dictation hotkeys stopped":        "Raccourcis de dictée arrêtés",
+			"Dictation failed":                 "Échec de la dictée",
+			"Dictation lost audio":             "La dictée a perdu de l'audio",
+			"Still recording":                  "Enregistrement en cours",
+			"Dictation provider problem":       "Problème de fournisseur de dictée",
+			"Dictation recovered":              "Dictée récupérée",
+			"Dictation recovered from a crash": "Dictée rétablie après un plantage",
+			"Dictation rate limited":           "Dictée : limite de requêtes atteinte",
+			"Dictation update available":       "Mise à jour de la dictée disponible",
+			"Recording":                        "Enregistrement",
+			"Transcribing":                     "Transcription",
+			"Inserted":                         "Inséré",
+
+			"The answer is on the clipboard":                                                                                "La réponse est dans le presse-papiers",
+			"No audio input is available: %v":                                                                               "Aucune entrée audio disponible : %v",
+			"%s records at %.0f kHz over Bluetooth, transcriptions will be less accurate":                                   "%s enregistre à %.0f kHz en Bluetooth, les transcriptions seront moins précises",
+			"Spent an estimated $%.2f today and $%.2f this month, over the budget.":                                         "Environ %.2f $ dépensés aujourd'hui et %.2f $ ce mois-ci, au-delà du budget.",
+			" Using profile %s instead.":                                                                                    " Le profil %s est utilisé à la place.",
+			"The recording was stopped so it can still be saved":                                                            "L'enregistrement a été arrêté pour pouvoir encore être sauvegardé",
+			"Only %s left, long recordings may not fit":                                                                     "Plus que %s de libre, les longs enregistrements risquent de ne pas tenir",
+			"Focus moved to another app; the text is kept for the reinsert hotkey: %s":                                      "Le focus est passé à une autre app ; le texte est gardé pour le raccourci de réinsertion : %s",
+			"It looks like a Whisper hallucination, press the reinsert hotkey to type it anyway: %s":                        "Cela ressemble à une hallucination de Whisper, appuyez sur le raccourci de réinsertion pour le taper quand même : %s",
+			"Accessibility permission was revoked. Allow it again in System Settings > Privacy & Security > Accessibility.": "L'autorisation d'accessibilité a été retirée. Autorisez-la à nouveau dans Réglages Système > Confidentialité et sécurité > Accessibilité.",
+			"The keyboard event tap can't be restored. Restart dictation.":                                                  "L'écoute du clavier ne peut pas être rétablie. Redémarrez la dictée.",
+			"The assistant didn't answer: %s":                                                                               "L'assistant n'a pas répondu : %s",
+			"Sending to %s failed, the text is on the clipboard":                                                            "L'envoi vers %s a échoué, le texte est dans le presse-papiers",
+			"Audio was lost %d times, the transcription may be missing words":                                               "De l'audio a été perdu %d fois, il peut manquer des mots dans la transcription",
+			"Dictation has been recording for %s":                                                                           "La dictée enregistre depuis %s",
+			"An earlier dictation that wasn't delivered is on the clipboard: %s":                                            "Une dictée précédente non insérée est dans le presse-papiers : %s",
+			"%s is a remote session; press the reinsert hotkey to type it there: %s":                                        "%s est une session à distance ; appuyez sur le raccourci de réinsertion pour le taper là-bas : %s",
+			"The %s crashed and is restarted":                                                                               "%s a planté et redémarre",
+			"Copied to the clipboard":                                                                                       "Copié dans le presse-papiers",
+			"Sent to %s":                                                                                                    "Envoyé à %s",
+			"%s is rate limiting, retrying in %ds":                                                                          "%s limite les requêtes, nouvel essai dans %d s",
+			"%s is available, you have %s":                                                                                  "%s est disponible, vous avez %s",
+
+			"Dictation: focus changed":          "Dictée : le focus a changé",
+			"Dictation: possible hallucination": "Dictée : hallucination possible",
+			"Dictation: type into %s?":          "Dictée : taper dans %s ?",
+			"Send the dictated message?":        "Envoyer le message dicté ?",
+			"Review cleanup":                    "Vérifier le nettoyage",
+			"Use original":                      "Garder l'original",
+			"Cancel":                            "Annuler",
+			"Copy":                              "Copier",
+			"Insert":                            "Insérer",
+		},
+		// Go's \b only knows ASCII letters, so names starting or ending
+		// with an accented one ("égal", "inférieur à") can't be used.
+		Symbols: map[string]string{
+			"parenthèse ouvrante":   "(",
+			"parenthèse fermante":   ")",
+			"crochet ouvrant":       "[",
+			"crochet fermant":       "]",
+			"accolade ouvrante":     "{",
+			"accolade fermante":     "}",
+			"double signe égal":     "==",
+			"signe égal":            "=",
+			"différent de":          "!=",
+			"plus petit que":        "<",
+			"plus grand que":        ">",
+			"point d'exclamation":   "!",
+			"point d'interrogation": "?",
+			"astérisque":            "*",
+			"barre oblique":         "/",
+			"barre verticale":       "|",
+			"esperluette":           "&",
+			"tiret bas":             "_",
+			"tiret":                 "-",
+			"point virgule":         ";",
+			"point-virgule":         ";",
+			"deux points":           ":",
+			"point":                 ".",
+			"virgule":               ",",
+			"dièse":                 "#",
+			"pour cent":             "%",
+			"arobase":               "@",
+			"guillemet":             `"`,
+			"apostrophe":            "'",
+			"nouvelle ligne":        "\n",
+			"tabulation":            "\t",
+		},
+		SendTo: regexp.MustCompile(`(?i)^\s*(?:envoie|envoyer|envoyez)(?: ça| ceci| cela)? (?:à |au |aux )(?:la |le |l'|les |mon |ma |mes )?(.+)$`),
+		Casing: map[string]string{
+			"en majuscules": "upper",
+			"en minuscules": "lower",
+			"en titre":      "title",
+		},
+		EndCase: "fin de casse",
+	},
+	"es": {
+		Messages: map[string]string{
+			"Dictation":                         "Dictado",
+			"Dictation: %s":                     "Dictado: %s",
+			"Dictation can't record":            "El dictado no puede grabar",
+			"Dictation: low quality microphone": "Dictado: micrófono de baja calidad",
+			"Dictation: microphone muted":       "Dictado: micrófono silenciado",
+			"%s is muted or turned all the way down, unmute it and record again": "%s está silenciado o al mínimo, actívalo y vuelve a grabar",
+			"The input device":                 "El dispositivo de entrada",
+			"Dictation budget reached":         "Presupuesto de dictado alcanzado",
+			"Dictation: disk almost full":      "Dictado: disco casi lleno",
+			"Dictation not inserted":           "Dictado no insertado",
+			"Dictation hotkeys stopped":        "Atajos de dictado detenidos",
+			"Dictation failed":                 "Error de dictado",
+			"Dictation lost audio":             "El dictado perdió audio",
+			"Still recording":                  "Sigue grabando",
+			"Dictation provider problem":       "Problema con el proveedor de dictado",
+			"Dictation recovered":              "Dictado recuperado",
+			"Dictation recovered from a crash": "Dictado recuperado tras un fallo",
+			"Dictation rate limited":           "Dictado: límite de solicitudes alcanzado",
+			"Dictation update available":       "Actualización de dictado disponible",
+			"Recording":                        "Grabando",
+			"Transcribing":                     "Transcribiendo",
+			"Inserted":                         "Insertado",
+
+			"The answer is on the clipboard":                                                                                "La respuesta está en el portapapeles",
+			"No audio input is available: %v":                                                                               "No hay ninguna entrada de audio disponible: %v",
+			"%s records at %.0f kHz over Bluetooth, transcriptions will be less accurate":                                   "%s graba a %.0f kHz por Bluetooth, las transcripciones serán menos precisas",
+			"Spent an estimated $%.2f today and $%.2f this month, over the budget.":                                         "Gastado aproximadamente $%.2f hoy y $%.2f este mes, por encima del presupuesto.",
+			" Using profile %s instead.":                                                                                    " Se usa el perfil %s en su lugar.",
+			"The recording was stopped so it can still be saved":                                                            "La grabación se detuvo para que aún pueda guardarse",
+			"Only %s left, long recordings may not fit":                                                                     "Solo quedan %s, puede que las grabaciones largas no quepan",
+			"Focus moved to another app; the text is kept for the reinsert hotkey: %s":                                      "El foco pasó a otra app; el texto se guarda para el atajo de reinserción: %s",
+			"It looks like a Whisper hallucination, press the reinsert hotkey to type it anyway: %s":                        "Parece una alucinación de Whisper, pulsa el atajo de reinserción para escribirlo de todos modos: %s",
+			"Accessibility permission was revoked. Allow it again in System Settings > Privacy & Security > Accessibility.": "Se retiró el permiso de accesibilidad. Vuelve a permitirlo en Ajustes del Sistema > Privacidad y seguridad > Accesibilidad.",
+			"The keyboard event tap can't be restored. Restart dictation.":                                                  "No se puede restablecer la escucha del teclado. Reinicia el dictado.",
+			"The assistant didn't answer: %s":                                                                               "El asistente no respondió: %s",
+			"Sending to %s failed, the text is on the clipboard":                                                            "Falló el envío a %s, el texto está en el portapapeles",
+			"Audio was lost %d times, the transcription may be missing words":                                               "Se perdió audio %d veces, puede que falten palabras en la transcripción",
+			"Dictation has been recording for %s":                                                                           "El dictado lleva %s grabando",
+			"An earlier dictation that wasn't delivered is on the clipboard: %s":                                            "Un dictado anterior que no se insertó está en el portapapeles: %s",
+			"%s is a remote session; press the reinsert hotkey to type it there: %s":                                        "%s es una sesión remota; pulsa el atajo de reinserción para escribirlo allí: %s",
+			"The %s crashed and is restarted":                                                                               "%s falló y se reinicia",
+			"Copied to the clipboard":                                                                                       "Copiado al portapapeles",
+			"Sent to %s":                                                                                                    "Enviado a %s",
+			"%s is rate limiting, retrying in %ds":                                                                          "%s está limitando las solicitudes, reintentando en %d s",
+			"%s is available, you have %s":                                                                                  "%s está disponible, tienes %s",
+
+			"Dictation: focus changed":          "Dictado: el foco cambió",
+			"Dictation: possible hallucination": "Dictado: posible alucinación",
+			"Dictation: type into %s?":          "Dictado: ¿escribir en %s?",
+			"Send the dictated message?":        "¿Enviar el mensaje dictado?",
+			"Review cleanup":                    "Revisar la limpieza",
+			"Use original":                      "Usar el original",
+			"Cancel":                            "Cancelar",
+			"Copy":                              "Copiar",
+			"Insert":                            "Insertar",
+		},
+		Symbols: map[string]string{
+			"abre paréntesis":   "(",
+			"cierra paréntesis": ")",
+			"abre corchete":     "[",
+			"cierra corchete":   "]",
+			"abre llave":        "{",
+			"cierra llave":      "}",
+			"igual igual":       "==",
+			"igual":             "=",
+			"distinto de":       "!=",
+			"menor o igual":     "<=",
+			"mayor o igual":     ">=",
+			"menor que":         "<",
+			"mayor que":         ">",
+			"asterisco":         "*",
+			"barra":             "/",
+			"guion bajo":        "_",
+			"guion":             "-",
+			"menos":             "-",
+			"punto y coma":      ";",
+			"dos puntos":        ":",
+			"punto":             ".",
+			"coma":              ",",
+			"almohadilla":       "#",
+			"por ciento":        "%",
+			"arroba":            "@",
+			"comillas":          `"`,
+			"comilla simple":    "'",
+			"nueva línea":       "\n",
+			"tabulador":         "\t",
+		},
+		SendTo: regexp.MustCompile(`(?i)^\s*(?:envía|envia|enviar|manda|mandar)(?: esto)? (?:a |al )(?:la |el |los |las |mi |mis )?(.+)$`),
+		Casing: map[string]string{
+			"en mayúsculas": "upper",
+			"en minúsculas": "lower",
+			"tipo título":   "title",
+		},
+		EndCase: "fin de formato",
+	},
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// mlxWorkerScript is run with the provider's Python to serve mlx_whisper
+// over the worker protocol. The model is loaded on the first request and
+// stays in memory for the lifetime of the process. Audio is read with the
+// standard library so ffmpeg isn't needed; it has to be 16 kHz mono.
+const mlxWorkerScript = `
+import json, sys, wave
+import numpy as np
+import mlx_whisper
+
+model = sys.argv[1]
+for line in sys.stdin:
+    try:
+        req = json.loads(line)
+        with wave.open(req["audio"]) as w:
+            pcm = w.readframes(w.getnframes())
+        audio = np.frombuffer(pcm, np.int16).astype(np.float32) / 32768.0
+        kwargs = {}
+        if req.get("language"):
+            kwargs["language"] = req["language"]
+        if req.get("prompt"):
+            kwargs["initial_prompt"] = req["prompt"]
+        if req.get("temperature") is not None:
+            kwargs["temperature"] = req["temperature"]
+        result = mlx_whisper.transcribe(audio, path_or_hf_repo=model, **kwargs)
+        out = {"text": result["text"].strip()}
+    except Exception as e:
+        out = {"error": str(e)}
+    print(json.dumps(out), flush=True)
+`
+
+// workerRequest and workerResponse make up the worker protocol: one JSON
+// object per line on stdin, answered by one JSON object per line on stdout.
+type workerRequest struct {
+	Audio       string   `json:"audio"`
+	Language    string   `json:"language,omitempty"`
+	Prompt      string   `json:"prompt,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type workerResponse struct {
+	Text  string `json:"text"`
+	Error string `json:"error"`
+}
+
+// localWorker is a long-lived transcription subprocess, so the model is
+// loaded once and not for every dictation. Requests are handled one at a
+// time. A worker that fails or hangs is killed and replaced on the next
+// request; errors it reports for a request don't count.
+type localWorker struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+}
+
+var (
+	workersMu sync.Mutex
+	workers   = map[string]*localWorker{}
+)
+
+// workerCommand returns the command line that runs the provider's worker.
+func (p Provider) workerCommand(model string) []string {
+	if p.Type == providerMLXWhisper {
+		python := p.Command
+		if len(python) == 0 {
+			python = []string{"python3"}
+		}
+		return append(append([]string{}, python...), "-u", "-c", mlxWorkerScript, model)
+	}
+	return p.Command
+}
+
+// workerFor returns the running worker for a provider and model, starting
+// it if needed.
+func workerFor(name string, provider Provider, model string) (*localWorker, error) {
+	workersMu.Lock()
+	defer workersMu.Unlock()
+
+	key := name + "\x00" + model
+	if w, ok := workers[key]; ok {
+		return w, nil
+	}
+
+	command := provider.workerCommand(model)
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating worker stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating worker stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", command[0], err)
+	}
+	fmt.Printf("Started local transcription worker (pid %d)\n", cmd.Process.Pid)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	w := &localWorker{cmd: cmd, stdin: stdin, stdout: scanner}
+	workers[key] = w
+	return w, nil
+}
+
+// forgetWorker drops a worker that stopped responding so the next request
+// starts a fresh one.
+func forgetWorker(w *localWorker) {
+	workersMu.Lock()
+	defer workersMu.Unlock()
+	for key, other := range workers {
+		if other == w {
+			delete(workers, key)
+		}
+	}
+	w.stdin.Close()
+	w.cmd.Process.Kill()
+	w.cmd.Wait()
+}
+
+// localWorkerTimeout is how long a worker gets to answer a request before
+// it is taken for hung and killed. The first request loads the model, which
+// mlx_whisper downloads first if it has to.
+const localWorkerTimeout = 5 * time.Minute
+
+// workerError is an error the worker reported for a request, say for a
+// file it can't read. The worker itself is fine and is kept.
+type workerError struct {
+	message string
+}
+
+func (e *workerError) Error() string {
+	return "worker: " + e.message
+}
+
+func (w *localWorker) transcribe(req workerRequest) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("encoding worker request: %w", err)
+	}
+	if _, err := w.stdin.Write(append(line, '\n')); err != nil {
+		return "", fmt.Errorf("writing to worker: %w", err)
+	}
+
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(localWorkerTimeout, func() {
+		timedOut.Store(true)
+		w.cmd.Process.Kill()
+	})
+	answered := w.stdout.Scan()
+	timer.Stop()
+	if !answered {
+		if timedOut.Load() {
+			return "", fmt.Errorf("worker didn't answer within %s", localWorkerTimeout)
+		}
+		if err := w.stdout.Err(); err != nil {
+			return "", fmt.Errorf("reading from worker: %w", err)
+		}
+		return "", errors.New("worker exited")
+	}
+
+	var resp workerResponse
+	if err := json.Unmarshal(w.stdout.Bytes(), &resp); err != nil {
+		return "", fmt.Errorf("decoding worker response: %w", err)
+	}
+	if resp.Error != "" {
+		return "", &workerError{resp.Error}
+	}
+	return resp.Text, nil
+}
+
+func transcribeLocal(audioFilePath string, provider Provider, profile Profile) (string, error) {
+	w, err := workerFor(profile.Provider, provider, profile.Model)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := w.transcribe(workerRequest{
+		Audio:       audioFilePath,
+		Language:    profile.Params.Language,
+		Prompt:      profile.Params.Prompt,
+		Temperature: profile.Params.Temperature,
+	})
+	if err != nil {
+		var reported *workerError
+		if !errors.As(err, &reported) {
+			// It didn't answer properly, start a fresh one next time.
+			forgetWorker(w)
+		}
+		return "", err
+	}
+	return text, nil
+}
+
+// warmUpLocalWorkers starts the worker of every profile using a local
+// provider and has it transcribe a moment of silence, so the model is loaded
+// before the first dictation instead of during it. Dictations started while
+// this runs wait for it.
+func warmUpLocalWorkers() {
+	seen := map[string]bool{}
+	for name, profile := range config.Profiles {
+		if profile.LocalProvider != "" {
+			profile = profile.onLocal()
+		}
+		provider := config.Providers[profile.Provider]
+		key := profile.Provider + "\x00" + profile.Model
+		if !provider.local() || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		go protect("warm-up of profile "+name, func() {
+			start := time.Now()
+			if err := warmUpLocal(provider, profile); err != nil {
+				fmt.Printf("Warning: warming up profile %s failed: %v\n", name, err)
+				return
+			}
+			fmt.Printf("Profile %s warmed up in %s\n", name, time.Since(start).Round(time.Millisecond))
+		})
+	}
+}
+
+// warmUpLocal has the worker of a local provider transcribe a moment of
+// silence, which starts it and loads the model.
+func warmUpLocal(provider Provider, profile Profile) error {
+	silence := make([]float32, localSampleRate/2)
+	path, err := saveAudioToFile(silence, localSampleRate, audioMetadata{Created: time.Now()})
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	_, err = transcribeLocal(path, provider, profile)
+	return err
+}
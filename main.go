@@ -1,24 +1,15 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"mime/multipart"
-	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
-
-	"github.com/go-audio/audio"
-	"github.com/go-audio/wav"
-	"github.com/go-vgo/robotgo"
-	"github.com/gordonklaus/portaudio"
-	hook "github.com/robotn/gohook"
 )
 
 const (
@@ -26,26 +17,97 @@ const (
 	sampleRate = 44100
 	channels   = 1
 
-	// open ai api
-	openAIURL   = "https://api.openai.com/v1/audio/transcriptions"
-	openAIModel = "whisper-1"
+	// localSampleRate is the rate of the audio handed to local workers.
+	localSampleRate = 16000
 
 	// trigger
-	globeKeyCode    = 179
 	doublePressTime = 500 * time.Millisecond
 )
 
 var (
-	openAIKey string
-	dictating bool
+	// dictating is set while a recording is being made. Every trigger
+	// reads and sets it, from its own goroutine.
+	dictating atomic.Bool
+
+	// daemonMode is set when running as a service: output goes to the log
+	// file and the quit chord isn't watched for, as there is no terminal to quit.
+	daemonMode bool
+
+	// toggleRequests carries toggles from single-button triggers (media key,
+	// Stream Deck) into the listener loop; the value describes the source.
+	toggleRequests = make(chan string, 1)
+	pedalEvents    = make(chan bool, 8)
+
+	// aborted is set when the recording being made is to be thrown away
+	// once it stops.
+	aborted atomic.Bool
 )
 
 func main() {
-	// Read OpenAI API key from environment variable
-	if envKey := os.Getenv("OPENAI_API_KEY"); envKey != "" {
-		openAIKey = envKey
-	} else {
-		fmt.Println("Error: OPENAI_API_KEY environment variable not set.")
+	args := os.Args[1:]
+	modeSet := false
+	// Global flags go before the command.
+flags:
+	for len(args) > 0 {
+		switch arg := args[0]; {
+		case arg == "--foreground" || arg == "--daemon":
+			daemonMode, modeSet = arg == "--daemon", true
+			args = args[1:]
+		case arg == "--config" && len(args) > 1:
+			configOverride = args[1]
+			args = args[2:]
+		case strings.HasPrefix(arg, "--config="):
+			configOverride = strings.TrimPrefix(arg, "--config=")
+			args = args[1:]
+		default:
+			break flags
+		}
+	}
+	if !modeSet {
+		// Services (launchd, brew services, systemd) run us without a
+		// terminal; that is daemon mode unless asked otherwise.
+		daemonMode = len(args) == 0 && !isTerminal(os.Stdin)
+	}
+	cfg, cfgErr := loadConfig()
+	if cfgErr == nil {
+		config = cfg
+	}
+	// After loading the config, as its data_dir moves the log. A config
+	// that can't be loaded is reported in the default log.
+	if daemonMode {
+		if err := logToFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if cfgErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", cfgErr)
+		os.Exit(1)
+	}
+	limiter = newAPILimiter(config.Limits)
+
+	var err error
+	if usage, err = loadUsage(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if vocabulary, err = loadVocabulary(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(args) > 0 {
+		if err := runCommand(args[0], args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Every provider used by a profile needs its API key, which is read
+	// from the environment variable named in the provider config.
+	if err := config.checkAPIKeys(); err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -56,11 +118,6 @@ func main() {
 }
 
 func run() error {
-	if err := portaudio.Initialize(); err != nil {
-		return fmt.Errorf("initializing portaudio: %w", err)
-	}
-	defer portaudio.Terminate()
-
 	// We are using a context to handle the interrupt signal sent by kill command
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
@@ -74,236 +131,764 @@ func run() error {
 		fmt.Println("Received interrupt signal.")
 	}()
 
-	// Pass the cancel function as well because we are tracking the control plus C press manually using raw codes hence we need to invoke the cancel function
-	listenForKeyboardEvents(ctx, cancel)
+	if !config.Privacy.History {
+		removeDrafts()
+	}
+
+	if config.Trigger.MediaKey {
+		stopMediaKeys, err := startMediaKeyListener()
+		if err != nil {
+			return fmt.Errorf("starting media key listener: %w", err)
+		}
+		defer stopMediaKeys()
+		fmt.Println("Media key trigger enabled: press play/pause to start and stop dictation.")
+	}
+
+	if config.Trigger.MIDI.Enabled {
+		stopMIDI, err := startMIDIListener()
+		if err != nil {
+			return fmt.Errorf("starting MIDI listener: %w", err)
+		}
+		defer stopMIDI()
+		fmt.Printf("MIDI pedal trigger enabled (%s mode).\n", config.Trigger.MIDI.Mode)
+	}
+
+	// The parts that keep running are restarted if they crash, rather
+	// than taking everything down with them.
+	go supervise(ctx, "blocked app watcher", watchBlockedApps)
+	go supervise(ctx, "quiet hours watcher", watchQuietHours)
+	go supervise(ctx, "preflight", watchPreflight)
+	go supervise(ctx, "audio input check", watchAudioInput)
+	go supervise(ctx, "recovery", recoverPending)
+	warmUpLocalWorkers()
+	if config.UpdateCheck {
+		checkForUpdate()
+	}
+
+	if err := startControlServer(ctx); err != nil {
+		return fmt.Errorf("starting control server: %w", err)
+	}
+
+	if config.StreamDeck.Listen != "" {
+		if err := startStreamDeckServer(ctx, config.StreamDeck.Listen); err != nil {
+			return fmt.Errorf("starting Stream Deck server: %w", err)
+		}
+	}
+
+	// Spans still waiting to be exported are sent on the way out.
+	defer traces.flush()
+
+	// Pass the cancel function as well because we are tracking the quit chord (Ctrl+C by default) manually hence we need to invoke the cancel function
+	supervise(ctx, "keyboard listener", func(ctx context.Context) { listenForKeyboardEvents(ctx, cancel) })
 
 	fmt.Println("Shutting down now...")
 	return nil
 }
 
+// keyListenerRetry is how often a keyboard listener that stopped is
+// started again.
+const keyListenerRetry = 5 * time.Second
+
 func listenForKeyboardEvents(ctx context.Context, cancel context.CancelFunc) {
-	fmt.Println("Starting keyboard listener. Press Ctrl+C to exit.")
+	if daemonMode {
+		fmt.Println("Starting keyboard listener.")
+	} else {
+		fmt.Printf("Starting keyboard listener. Press %s to exit.\n", config.Trigger.Quit)
+	}
 
-	evChan := hook.Start()
-	defer hook.End()
+	keys, stopKeys, err := startKeyListener()
+	if err != nil {
+		fmt.Printf("Error: keyboard listener: %v\n", err)
+		fmt.Println("Hotkeys are disabled, the other triggers still work.")
+		stopKeys = func() {}
+	}
+	defer func() { stopKeys() }()
+	// reopen fires when the listener is to be started again, after it
+	// stopped (e.g. its keyboard was unplugged).
+	var reopen <-chan time.Time
 
 	var lastGlobePressTime time.Time
-	ctrlPressed := false
+	held := modifierState{}
+	quit, _ := parseChord(config.Trigger.Quit) // checked by validate
+	engine := newChordEngine(config.Trigger.Hotkeys)
+	quick := newQuickGesture(engine)
 
 	for {
 		select {
 		case <-ctx.Done():
 			fmt.Println("Context cancelled, stopping keyboard listener")
 			return
-		case ev := <-evChan:
-			if ev.Kind == hook.KeyHold || ev.Kind == hook.KeyDown {
-				if ev.Rawcode == 59 { // Ctrl press
-					ctrlPressed = true
-				} else if ev.Rawcode == 8 && ctrlPressed { // Ctrl + C
-					fmt.Println("User pressed Ctrl+C")
-					cancel()
-					return
-				} else {
-					ctrlPressed = false
-					handleKeyEvent(ctx, ev, &lastGlobePressTime)
-				}
-			} else if ev.Kind == hook.KeyUp { // don't release Ctrl if you want to quit program
-				if ev.Rawcode == 59 {
-					ctrlPressed = false
-				}
+		case source := <-toggleRequests:
+			toggleDictation(ctx, source)
+		case down := <-pedalEvents:
+			handlePedalEvent(ctx, down)
+		case i := <-engine.holds:
+			if h, ok := engine.held(i); ok {
+				runHotkey(ctx, h, &lastGlobePressTime)
+			}
+		case <-quick.holds():
+			if quick.down {
+				startQuickDictation(ctx)
+			}
+		case <-reopen:
+			reopen = nil
+			if keys, stopKeys, err = startKeyListener(); err != nil {
+				stopKeys = func() {}
+				reopen = time.After(keyListenerRetry)
+				continue
+			}
+			held = modifierState{}
+			fmt.Println("Keyboard listener started again")
+		case ev, ok := <-keys:
+			if !ok {
+				fmt.Printf("Keyboard listener stopped, trying again every %s\n", keyListenerRetry)
+				keys = nil
+				stopKeys()
+				reopen = time.After(keyListenerRetry)
+				continue
+			}
+			if held.update(&ev) {
+				continue
+			}
+			// In daemon mode the quit chord in any app would stop the
+			// service, so it's only watched in the foreground.
+			if !daemonMode && config.Trigger.Quit != "" && quit.matches(ev) {
+				fmt.Printf("User pressed %s\n", config.Trigger.Quit)
+				cancel()
+				return
+			}
+			quick.key(ev)
+			for _, h := range engine.handle(ev) {
+				runHotkey(ctx, h, &lastGlobePressTime)
 			}
 		}
 	}
 }
 
-func handleKeyEvent(ctx context.Context, ev hook.Event, lastGlobePressTime *time.Time) {
-	if ev.Rawcode != globeKeyCode {
-		return
+// keyEvent is a key press or release in any app, with the platform's key
+// code (see keyCodes). mods are the modifiers held at the time, filled in
+// by modifierState.
+type keyEvent struct {
+	code uint16
+	down bool
+	mods modifiers
+}
+
+func runHotkey(ctx context.Context, h *hotkey, lastGlobePressTime *time.Time) {
+	source := fmt.Sprintf("%s pressed", h.Key)
+	switch {
+	case h.Action == actionAbort:
+		abortDictation(source)
+	case h.Action == actionReinsert:
+		if app := blockedApp(); app != "" {
+			fmt.Printf("%s ignored, triggers are blocked in %s\n", source, app)
+			return
+		}
+		if reason, until := triggersDisabled(time.Now()); reason != "" {
+			fmt.Printf("%s ignored, dictation is %s\n", source, disabledMessage(reason, until))
+			return
+		}
+		reinsertLast()
+	case h.globeGesture():
+		now := time.Now()
+		if now.Sub(*lastGlobePressTime) < doublePressTime {
+			handleDoublePress(ctx, h.Profile)
+		} else {
+			handleSinglePress()
+		}
+		*lastGlobePressTime = now
+	default:
+		toggleDictationWith(ctx, source, h.Profile, h.Target)
 	}
+}
 
-	now := time.Now()
-	if now.Sub(*lastGlobePressTime) < doublePressTime {
-		handleDoublePress(ctx)
-	} else {
-		handleSinglePress()
+func handleDoublePress(ctx context.Context, profile string) {
+	startDictation(ctx, "Double press detected", profile, "")
+}
+
+func handleSinglePress() {
+	stopDictation("Single press detected")
+}
+
+// toggleDictation is used by triggers that have a single button, like the
+// play/pause key on a headset, where one press starts and the next one stops.
+func toggleDictation(ctx context.Context, source string) {
+	toggleDictationWith(ctx, source, triggerProfile(), "")
+}
+
+// selectedProfile is the profile picked for the triggers that aren't bound
+// to one, "" for the default profile. It lasts until dictation restarts.
+var selectedProfile struct {
+	sync.Mutex
+	name string
+}
+
+// triggerProfile is the profile the media key, the MIDI pedal, the Stream
+// Deck and the control API start dictation with.
+func triggerProfile() string {
+	selectedProfile.Lock()
+	defer selectedProfile.Unlock()
+	if selectedProfile.name == "" {
+		return defaultProfile
 	}
-	*lastGlobePressTime = now
+	return selectedProfile.name
 }
 
-func handleDoublePress(ctx context.Context) {
-	if !dictating {
-		fmt.Println("Double press detected, starting transcription")
-		dictating = true
-		go startTranscription(ctx)
+// selectProfile picks the profile for triggerProfile. It fails for profiles
+// that aren't in the config.
+func selectProfile(name string) error {
+	if _, ok := config.Profiles[name]; !ok {
+		return fmt.Errorf("no profile %q", name)
 	}
+	selectedProfile.Lock()
+	defer selectedProfile.Unlock()
+	selectedProfile.name = name
+	return nil
 }
 
-func handleSinglePress() {
-	if dictating {
-		fmt.Println("Single press detected, stopping transcription")
-		dictating = false
+func toggleDictationWith(ctx context.Context, source, profile, target string) {
+	if dictating.Load() {
+		stopDictation(source)
+		return
 	}
+	startDictation(ctx, source, profile, target)
 }
 
-func startTranscription(ctx context.Context) {
-	audioFilePath, err := recordAudio(ctx)
-	if err != nil {
-		fmt.Printf("Error saving audio file: %v\n", err)
+// startDictation starts recording with a profile. target, if set, is the
+// named target the dictation goes to instead of the profile's.
+func startDictation(ctx context.Context, source, profile, target string) {
+	beginDictation(ctx, source, profile, target, false)
+}
+
+// beginDictation is startDictation, in quick mode (see QuickConfig) if quick
+// is set.
+func beginDictation(ctx context.Context, source, profile, target string, quick bool) {
+	if dictating.Load() {
+		return
+	}
+	if app := blockedApp(); app != "" {
+		fmt.Printf("%s ignored, triggers are blocked in %s\n", source, app)
+		status.setBlocked(app)
 		return
 	}
+	if reason, until := triggersDisabled(time.Now()); reason != "" {
+		fmt.Printf("%s ignored, dictation is %s\n", source, disabledMessage(reason, until))
+		return
+	}
+	if !dictating.CompareAndSwap(false, true) {
+		return // another trigger started one meanwhile
+	}
+	fmt.Printf("%s, starting transcription (profile %s)\n", source, profile)
+	aborted.Store(false)
+	p := config.profile(profile)
+	if target != "" {
+		p.Target = target
+	}
+	p.quick = quick
+	go func() {
+		err := protect("dictation", func() { startTranscription(ctx, p) })
+		if err == nil {
+			return
+		}
+		// The recorder was closed on the way out; the next dictation
+		// starts from scratch, only this one is lost.
+		dictating.Store(false)
+		metrics.resetLevel()
+		status.fail(err)
+	}()
+}
 
-	transcription, err := transcribeAudio(audioFilePath)
-	if err != nil {
-		fmt.Printf("Error transcribing: %v\n", err)
+// abortDictation stops the recording and throws it away. With nothing
+// being recorded it cancels the insertions waiting for their turn instead.
+func abortDictation(source string) {
+	if !dictating.Load() {
+		if n := insertions.cancelAll(); n > 0 {
+			fmt.Printf("%s, cancelled %d waiting insertions\n", source, n)
+		}
+		return
+	}
+	fmt.Printf("%s, aborting transcription\n", source)
+	aborted.Store(true)
+	dictating.Store(false)
+}
+
+// reinsertLast types the last dictation again.
+func reinsertLast() {
+	text := status.snapshot().LastText
+	if text == "" {
+		fmt.Println("Nothing to insert again yet")
 		return
 	}
+	go insertText(text)
+}
 
-	fmt.Printf("You said: %s\n", transcription)
-	robotgo.TypeStr(transcription)
+func stopDictation(source string) {
+	if !dictating.Load() {
+		return
+	}
+	fmt.Printf("%s, stopping transcription\n", source)
+	dictating.Store(false)
 }
 
-func transcribeAudio(audioFilePath string) (string, error) {
-	file, err := os.Open(audioFilePath)
-	if err != nil {
-		return "", fmt.Errorf("opening audio file: %w", err)
+func startTranscription(ctx context.Context, profile Profile) {
+	id := newRecordingID()
+	status.begin(id, profile.Name)
+
+	trace := startTrace("dictation")
+	var failure error
+	defer func() { trace.end(failure) }()
+	trace.set("recording.id", id)
+	trace.set("profile", profile.Name)
+
+	requested, quick := profile.Name, profile.quick
+	profile = applyBudget(profile)
+	if profile.Name != requested {
+		status.setProfile(profile.Name)
+		profile.quick = quick
+	}
+	profile = applyPreset(profile)
+	if profile.LanguageFromInput {
+		if lang := inputSourceLanguage(); lang != "" {
+			profile.Params.Language = lang
+		}
+	}
+	trace.set("language", profile.Params.Language)
+	if profile.CodeMode {
+		// The window title is read now, while the editor still has focus.
+		profile.Params.Prompt = codePrompt(profile.Params.Prompt)
+	}
+	profile.Params.Prompt = vocabularyPrompt(profile.Params.Prompt)
+	provider := config.provider(profile)
+	trace.set("provider", profile.Provider)
+	trace.set("model", profile.Model)
+	if provider.Type == providerFasterWhisperWS {
+		failure = streamTranscription(ctx, id, provider, profile, trace)
+		return
 	}
-	defer file.Close()
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+	// The recording is resampled to the upload rate of the providers it
+	// may go to while it comes in, not after.
+	rates := []int{provider.uploadRate()}
+	if profile.LocalProvider != "" {
+		rates = append(rates, config.provider(profile.onLocal()).uploadRate())
+	}
+	live := newLiveResampler(rates...)
+	onBuffer := live.add
+
+	// Long recordings are transcribed in segments while recording goes on,
+	// and those too big for the provider in parts it takes.
+	var segments *segmentedSession
+	if after := config.Recording.segmentAfter(); after > 0 || provider.uploadLimit() > 0 {
+		segments = newSegmentedSession(id, profile, after, trace)
+		onBuffer = func(buffer []float32) {
+			segments.add(buffer)
+			live.add(buffer)
+		}
+	}
 
-	part, err := writer.CreateFormFile("file", audioFilePath)
+	capture := trace.child("capture")
+	samples, err := recordAudio(ctx, onBuffer, profile.quick)
+	stopped, target := time.Now(), stopTarget()
+	capture.set("audio.seconds", float64(len(samples))/sampleRate)
+	capture.end(err)
 	if err != nil {
-		return "", fmt.Errorf("creating form file: %w", err)
+		fmt.Printf("Error recording audio: %v\n", err)
+		if segments != nil {
+			segments.discard()
+		}
+		status.fail(err)
+		failure = err
+		return
 	}
-	if _, err := io.Copy(part, file); err != nil {
-		return "", fmt.Errorf("copying file to form: %w", err)
+	cue(cueStop)
+	live.finish(samples)
+	if aborted.Swap(false) {
+		if segments != nil {
+			segments.discard()
+		}
+		fmt.Println("Recording discarded")
+		trace.set("aborted", true)
+		status.set(stateIdle)
+		return
 	}
+	insertions.reserve(id)
+	defer insertions.release(id)
 
-	if err := writer.WriteField("model", openAIModel); err != nil {
-		return "", fmt.Errorf("writing model field: %w", err)
+	status.set(stateTranscribing)
+	if segments != nil && segments.count > 0 {
+		segments.finish(target)
+		archiveAudio(id, samples)
+		return
 	}
 
-	if err := writer.Close(); err != nil {
-		return "", fmt.Errorf("closing multipart writer: %w", err)
+	// Kept until the text is delivered, for recoverPending if we're stopped
+	// or crash in between. Not removed when a crash unwinds this.
+	pending := savePending(id, profile, samples)
+	audioDuration := time.Duration(len(samples)) * time.Second / sampleRate
+	if chosen, reason := chooseBackend(profile, audioDuration); reason != "" {
+		profile, provider = chosen, config.provider(chosen)
+		trace.set("provider", profile.Provider)
+		trace.set("model", profile.Model)
+		trace.set("backend.reason", reason)
 	}
-
-	req, err := http.NewRequest("POST", openAIURL, body)
+	transcription, err := transcribeRecording(samples, live, profile, provider, trace)
 	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+		// Kept for recoverPending, the provider may be back by the next
+		// start.
+		fmt.Printf("Error transcribing: %v\n", err)
+		if pending != nil {
+			fmt.Println("The recording is kept and transcribed again at the next start")
+		}
+		logTranscription(transcriptionEvent{RecordingID: id}, profile, audioDuration, time.Since(stopped), "", err)
+		status.fail(err)
+		failure = err
+		return
 	}
 
-	req.Header.Set("Authorization", "Bearer "+openAIKey)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	text := postProcessTraced(transcription, profile, trace)
+	logTranscription(transcriptionEvent{RecordingID: id}, profile, audioDuration, time.Since(stopped), text, nil)
+	insert := trace.child("insert")
+	deliverTranscription(id, profile, text, target)
+	insert.end(nil)
+	pending.done()
+	archiveAudio(id, samples)
+}
+
+// postProcessTraced runs postProcess in a span of its own.
+func postProcessTraced(text string, profile Profile, trace *span) string {
+	s := trace.child("postprocess")
+	text = postProcess(text, profile)
+	s.set("chars", len([]rune(text)))
+	s.end(nil)
+	return text
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("sending request: %w", err)
+// transcribeSamples saves recorded audio to a file, transcribes it and
+// removes the file again. The request is added to the usage.
+func transcribeSamples(samples []float32, profile Profile, provider Provider, trace *span) (string, error) {
+	return transcribeRecording(samples, nil, profile, provider, trace)
+}
+
+// transcribeRecording is transcribeSamples for a recording live resampled
+// while it was captured; the resampling is only done now if live didn't
+// do it for the provider's rate.
+func transcribeRecording(samples []float32, live *liveResampler, profile Profile, provider Provider, trace *span) (string, error) {
+	encode := trace.child("encode")
+	meta := recordingMetadata(samples)
+	rate := provider.uploadRate()
+	if resampled, ok := live.at(rate); ok {
+		samples = resampled
+	} else if rate != sampleRate {
+		samples = newResampler(sampleRate, rate).process(samples, nil)
 	}
-	defer resp.Body.Close()
 
-	var result struct {
-		Text string `json:"text"`
+	audioFilePath, err := saveAudioToFile(samples, rate, meta)
+	encode.end(err)
+	if err != nil {
+		return "", fmt.Errorf("saving audio file: %w", err)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("decoding response: %w", err)
+	upload := trace.child("upload")
+	started := time.Now()
+	audio := time.Duration(len(samples)) * time.Second / time.Duration(rate)
+	transcription, err := transcribeAudio(audioFilePath, profile, audio, upload)
+	metrics.record(profile.Provider, time.Since(started), err)
+	upload.end(err)
+	// Removed even when the upload failed: the recording is kept in
+	// pending for another try, if privacy.audio allows.
+	if err != nil {
+		os.Remove(audioFilePath)
+		return "", err
 	}
 
 	if err := os.Remove(audioFilePath); err != nil {
 		fmt.Printf("Warning: failed to remove temporary audio file: %v\n", err)
 	}
-
-	return result.Text, nil
+	return transcription, nil
 }
 
-func recordAudio(ctx context.Context) (string, error) {
-	buffer := make([]float32, 1024)
-	stream, err := portaudio.OpenDefaultStream(channels, 0, float64(sampleRate), len(buffer), buffer)
+// streamTranscription sends the audio to the provider while recording, for
+// providers that transcribe in real time. It returns the error the
+// dictation failed with, for the trace.
+func streamTranscription(ctx context.Context, id string, provider Provider, profile Profile, trace *span) error {
+	session, err := openStreamSession(provider, profile)
+	if err != nil {
+		dictating.Store(false)
+		fmt.Printf("Error starting streaming transcription: %v\n", err)
+		status.fail(err)
+		return err
+	}
+
+	capture := trace.child("capture")
+	samples, recordErr := recordAudio(ctx, session.send, profile.quick)
+	target := stopTarget()
+	capture.set("audio.seconds", float64(len(samples))/sampleRate)
+	capture.end(recordErr)
+	if recordErr == nil {
+		cue(cueStop)
+	}
+	if aborted.Swap(false) {
+		session.finish()
+		fmt.Println("Recording discarded")
+		trace.set("aborted", true)
+		status.set(stateIdle)
+		return nil
+	}
+	insertions.reserve(id)
+	defer insertions.release(id)
+	status.set(stateTranscribing)
+	finish := trace.child("finish")
+	started := time.Now()
+	transcription, err := session.finish()
+	if recordErr != nil {
+		err = recordErr
+	}
+	metrics.record(profile.Provider, time.Since(started), err)
+	finish.end(err)
+	audioDuration := time.Duration(len(samples)) * time.Second / sampleRate
 	if err != nil {
-		return "", fmt.Errorf("opening audio stream: %w", err)
+		fmt.Printf("Error transcribing: %v\n", err)
+		logTranscription(transcriptionEvent{RecordingID: id}, profile, audioDuration, time.Since(started), "", err)
+		status.fail(err)
+		return err
+	}
+	usage.add(profile, audioDuration)
+
+	text := postProcessTraced(transcription, profile, trace)
+	logTranscription(transcriptionEvent{RecordingID: id}, profile, audioDuration, time.Since(started), text, nil)
+	insert := trace.child("insert")
+	deliverTranscription(id, profile, text, target)
+	insert.end(nil)
+	archiveAudio(id, samples)
+	return nil
+}
+
+// deliverTranscription types the text of a recording. Each recording is
+// typed at most once, however many times its transcription comes back.
+// target is the app that had focus when the recording stopped, see
+// output.focus_change. A dictation starting with "send to <name>" goes to
+// that target instead of the profile's.
+func deliverTranscription(id string, profile Profile, transcription, target string) {
+	if !status.markInserted(id) {
+		fmt.Printf("Recording %s was already inserted, dropping duplicate transcription\n", id)
+		return
+	}
+
+	if strings.TrimSpace(transcription) == "" {
+		fmt.Println("Nothing to insert")
+		status.set(stateIdle)
+		return
+	}
+
+	destination := profile.Target
+	question, asked := assistantQuestion(transcription)
+	if asked {
+		answer, err := askAssistant(profile, question)
+		if err != nil {
+			fmt.Printf("Error: asking the assistant: %v\n", err)
+			notify(tr("Dictation failed"), tr("The assistant didn't answer: %s", logged(question)))
+			status.fail(err)
+			return
+		}
+		if config.Assistant.Output == assistantPopup {
+			insertions.release(id)
+			status.finish(answer)
+			fmt.Printf("Answer: %s\n", logged(answer))
+			showAnswer(question, answer)
+			recordHistory(id, profile, answer, "", "")
+			return
+		}
+		transcription = answer
+	} else if name, rest, ok := spokenTarget(transcription); ok {
+		destination, transcription = name, rest
+	}
+	// Key commands press keys in the focused app, not in targets.
+	var key *keyPress
+	if !asked && destination == "" {
+		if transcription, key = splitKeyCommand(transcription, frontmostApp()); key != nil && strings.TrimSpace(transcription) == "" {
+			// Nothing but the command: only the key is pressed.
+			status.set(stateIdle)
+			insertions.run(id, func() { pressKeyCommand(key) })
+			return
+		}
+	}
+	transcription = journalEntry(profile, transcription, time.Now())
+	transcription = formatOutput(profile, destination, transcription)
+	transcription, cursor := cutCursor(transcription)
+	status.finish(transcription)
+	fmt.Printf("You said: %s\n", logged(transcription))
+
+	var app, title string
+	if config.History.Enabled && config.History.AppContext {
+		app, title = frontmostApp(), windowTitle()
+	}
+	sent := false
+	if destination != "" {
+		var err error
+		if sent, err = sendToTarget(destination, transcription); err != nil {
+			fmt.Printf("Error: sending to %s: %v\n", destination, err)
+			notify(tr("Dictation failed"), tr("Sending to %s failed, the text is on the clipboard", destination))
+			injector.WriteClipboard(transcription)
+		}
+	}
+	if sent {
+		if config.Targets[destination].Type == targetClipboard {
+			// Already where copy_last would put it, and copying it for
+			// clipboard managers would take it away again.
+			recordHistory(id, profile, transcription, app, title)
+			return
+		}
+	} else if profile.Draft == draftEditor {
+		if err := openDraft(transcription); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	} else if !asked && flaggedHallucinations(transcription) {
+		// Dialogs can stay open; later dictations don't wait for them.
+		insertions.release(id)
+		handleHallucination(transcription)
+	} else if focusMoved(target) {
+		insertions.release(id)
+		handleFocusChange(transcription, target)
+	} else if app := remoteSession(); app != "" && config.Output.RemoteSession != confirmInsert {
+		insertions.release(id)
+		handleRemoteSession(transcription, app)
+	} else {
+		insertTextThen(transcription, followUp{
+			Actions:   profile.AfterInsert,
+			Cursor:    cursor,
+			Key:       key,
+			Submit:    profile.quick && config.Trigger.Quick.Submit,
+			Recording: id,
+		})
+		if config.Vocabulary.WatchEdits && !asked {
+			// Edits of an answer aren't fixes of a mishearing.
+			go protect("edit watcher", func() { watchEdits(transcription) })
+		}
 	}
-	defer stream.Close()
+	copyLast(transcription)
+	recordHistory(id, profile, transcription, app, title)
+}
 
-	var allSamples []float32
+// recordAudio records until dictation is stopped and returns the samples.
+// If onBuffer is set it is called with every buffer as it is captured; it
+// must not hold on to the slice. In quick mode it also stops at the first
+// pause.
+func recordAudio(ctx context.Context, onBuffer func([]float32), quick bool) ([]float32, error) {
+	stopReminder := func() {}
+	started := false
+	var pause *pauseDetector
+	if quick {
+		pause = &pauseDetector{silence: config.Trigger.Quick.silence(), max: config.Trigger.Quick.max()}
+	}
+	samples, err := captureAudio(ctx, captureHooks{
+		Started: func(device string) {
+			started = true
+			status.setDevice(device)
+			if quick {
+				fmt.Println("Recording a quick dictation, it stops when you pause.")
+			} else {
+				fmt.Println("Recording... Press the dictation key again to stop.")
+			}
+			cue(cueStart)
+			warnLowDiskSpace()
+			stopReminder = remindWhileRecording()
+		},
+		Progress: func(p captureProgress) bool {
+			metrics.setLevel(p.Level)
+			if onBuffer != nil {
+				onBuffer(p.Buffer)
+			}
+			return dictating.Load() && (pause == nil || !pause.over(p))
+		},
+		Stopped: func(s captureSummary) {
+			stopReminder()
+			dictating.Store(false)
+			metrics.resetLevel()
+			switch {
+			case ctx.Err() != nil:
+				fmt.Println("Context cancelled, recording stopped")
+			case s.Err != nil:
+				fmt.Printf("Error: %v\n", s.Err)
+			default:
+				fmt.Println("Recording finished")
+			}
+			reportOverruns(s.Overruns, s.Reads)
+		},
+	})
+	if err != nil && !started {
+		// The device may be back later (plugged in again, the audio
+		// system restarted); the status says so meanwhile.
+		go supervise(ctx, "audio input check", watchAudioInput)
+	}
+	return samples, err
+}
 
-	if err := stream.Start(); err != nil {
-		return "", fmt.Errorf("starting audio stream: %w", err)
+// reportOverruns warns when audio was lost during a recording, which
+// otherwise only shows as words missing from the transcription.
+func reportOverruns(overruns, reads int) {
+	if overruns == 0 {
+		return
 	}
+	fmt.Printf("Warning: audio was lost %d times during the recording (%d buffers read); a bigger audio.frame_size may help\n", overruns, reads)
+	notify(tr("Dictation lost audio"), tr("Audio was lost %d times, the transcription may be missing words", overruns))
+}
 
-	fmt.Println("Recording... Press the dictation key again to stop.")
+// remindWhileRecording reminds the user every recording.reminder that the
+// microphone is still live, in case they forgot to stop. The returned func
+// stops it.
+func remindWhileRecording() func() {
+	interval := config.Recording.reminder()
+	if interval == 0 {
+		return func() {}
+	}
 
-	recordingDone := make(chan struct{})
+	started := time.Now()
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
 	go func() {
-		for dictating {
+		for {
 			select {
-			case <-ctx.Done():
-				fmt.Println("Context cancelled, stopping recording")
+			case <-done:
 				return
-			default:
-				fmt.Print(".")
-				if err := stream.Read(); err != nil {
-					fmt.Printf("Error reading from stream: %v\n", err)
-					return
+			case <-ticker.C:
+				elapsed := formatElapsed(time.Since(started))
+				fmt.Printf("Still recording (%s)\n", elapsed)
+				if config.Feedback.sound(cueReminder) == "" && config.Feedback.Visual != visualNotification {
+					notify(tr("Still recording"), tr("Dictation has been recording for %s", elapsed))
 				}
-				allSamples = append(allSamples, buffer...)
+				cue(cueReminder)
 			}
 		}
-		fmt.Println("stopping recording")
-		close(recordingDone)
 	}()
-
-	// Wait for either context cancellation or recording to finish
-	select {
-	case <-ctx.Done():
-		fmt.Println("Context cancelled, recording stopped")
-	case <-recordingDone:
-		fmt.Println("Recording finished")
-	}
-
-	dictating = false // Ensure dictating is set to false
-
-	if err := stream.Stop(); err != nil {
-		return "", fmt.Errorf("stopping audio stream: %w", err)
+	return func() {
+		ticker.Stop()
+		close(done)
 	}
-
-	return saveAudioToFile(allSamples)
 }
 
-func saveAudioToFile(samples []float32) (string, error) {
-	filename := fmt.Sprintf("recorded_audio_%s.wav", time.Now().Format("20060102_150405"))
-	fullPath, err := filepath.Abs(filename)
+// saveAudioToFile encodes samples at rate with uploadEncoder into a new file
+// in the cache directory and returns its path.
+func saveAudioToFile(samples []float32, rate int, meta audioMetadata) (string, error) {
+	dir, err := cacheDir()
 	if err != nil {
-		return "", fmt.Errorf("getting absolute path: %w", err)
+		return "", err
 	}
-
-	file, err := os.Create(fullPath)
-	if err != nil {
-		return "", fmt.Errorf("creating audio file: %w", err)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("creating cache directory: %w", err)
 	}
-	defer file.Close()
 
-	intBuffer := make([]int, len(samples))
-	for i, sample := range samples {
-		intBuffer[i] = int(sample * 32767)
+	if err := checkDiskSpace(dir, uploadEncoder.Size(len(samples), rate)); err != nil {
+		return "", err
 	}
 
-	wavEncoder := wav.NewEncoder(file, sampleRate, 16, channels, 1)
-	defer wavEncoder.Close()
-
-	audioIntBuffer := &audio.IntBuffer{
-		Format: &audio.Format{
-			NumChannels: channels,
-			SampleRate:  sampleRate,
-		},
-		Data:           intBuffer,
-		SourceBitDepth: 16,
+	pattern := fmt.Sprintf("recorded_audio_%s_*%s", time.Now().Format("20060102_150405"), uploadEncoder.Ext())
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", fmt.Errorf("creating audio file: %w", err)
 	}
-
-	if err := wavEncoder.Write(audioIntBuffer); err != nil {
-		return "", fmt.Errorf("encoding WAV: %w", err)
+	defer file.Close()
+	if err := uploadEncoder.Encode(file, samples, rate, meta); err != nil {
+		return "", err
 	}
-
-	return fullPath, nil
+	return file.Name(), nil
 }
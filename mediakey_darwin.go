@@ -0,0 +1,112 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework ApplicationServices -framework AppKit
+#import <AppKit/AppKit.h>
+#import <ApplicationServices/ApplicationServices.h>
+
+// NX_SYSDEFINED events carry media keys; subtype 8 is the aux control button
+// subtype and NX_KEYTYPE_PLAY is 16.
+#define MEDIA_KEY_EVENT_TYPE 14
+#define MEDIA_KEY_SUBTYPE 8
+#define MEDIA_KEY_PLAY 16
+
+extern void goMediaKeyPressed(void);
+
+static CFMachPortRef mediaKeyTap;
+static CFRunLoopRef mediaKeyLoop;
+
+static CGEventRef mediaKeyCallback(CGEventTapProxy proxy, CGEventType type, CGEventRef event, void *refcon) {
+	if (type == kCGEventTapDisabledByTimeout || type == kCGEventTapDisabledByUserInput) {
+		CGEventTapEnable(mediaKeyTap, true);
+		return event;
+	}
+	if (type != MEDIA_KEY_EVENT_TYPE) {
+		return event;
+	}
+
+	NSEvent *nsEvent = [NSEvent eventWithCGEvent:event];
+	if (nsEvent == nil || nsEvent.subtype != MEDIA_KEY_SUBTYPE) {
+		return event;
+	}
+
+	int keyCode = (int)((nsEvent.data1 & 0xFFFF0000) >> 16);
+	int keyState = (int)((nsEvent.data1 & 0x0000FF00) >> 8);
+	if (keyCode != MEDIA_KEY_PLAY) {
+		return event;
+	}
+
+	// 0xA is key down, 0xB is key up. Swallow both so the music player
+	// doesn't also react to the press.
+	if (keyState == 0xA) {
+		goMediaKeyPressed();
+	}
+	return NULL;
+}
+
+static int startMediaKeyTap(void) {
+	mediaKeyTap = CGEventTapCreate(kCGSessionEventTap, kCGHeadInsertEventTap, kCGEventTapOptionDefault,
+		CGEventMaskBit(MEDIA_KEY_EVENT_TYPE), mediaKeyCallback, NULL);
+	if (mediaKeyTap == NULL) {
+		return 0;
+	}
+
+	CFRunLoopSourceRef source = CFMachPortCreateRunLoopSource(kCFAllocatorDefault, mediaKeyTap, 0);
+	mediaKeyLoop = CFRunLoopGetCurrent();
+	CFRunLoopAddSource(mediaKeyLoop, source, kCFRunLoopCommonModes);
+	CFRelease(source);
+	CGEventTapEnable(mediaKeyTap, true);
+	return 1;
+}
+
+static void runMediaKeyLoop(void) {
+	CFRunLoopRun();
+}
+
+static void stopMediaKeyTap(void) {
+	if (mediaKeyLoop != NULL) {
+		CFRunLoopStop(mediaKeyLoop);
+	}
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+)
+
+//export goMediaKeyPressed
+func goMediaKeyPressed() {
+	// Never block the event tap callback; a press arriving while the
+	// previous one is still unhandled is dropped.
+	select {
+	case toggleRequests <- "Media key pressed":
+	default:
+	}
+}
+
+// startMediaKeyListener installs an event tap for the play/pause media key on
+// a dedicated OS thread. Presses are delivered on mediaKeyPresses.
+func startMediaKeyListener() (stop func(), err error) {
+	started := make(chan bool)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		if C.startMediaKeyTap() == 0 {
+			started <- false
+			return
+		}
+		started <- true
+		C.runMediaKeyLoop()
+	}()
+
+	if !<-started {
+		return nil, errors.New("creating media key event tap (is accessibility permission granted?)")
+	}
+	return func() { C.stopMediaKeyTap() }, nil
+}
@@ -0,0 +1,9 @@
+//go:build !darwin
+
+package main
+
+import "errors"
+
+func startMediaKeyListener() (stop func(), err error) {
+	return nil, errors.New("media key trigger is only supported on macOS")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// providerHealth is what the dashboard shows per provider.
+type providerHealth struct {
+	Requests int `json:"requests"`
+	Failures int `json:"failures"`
+	// Latencies are from the end of the recording to the transcription,
+	// in milliseconds, over the last requests.
+	LastLatencyMs   int64      `json:"last_latency_ms"`
+	MedianLatencyMs int64      `json:"median_latency_ms"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastSuccess     *time.Time `json:"last_success,omitempty"`
+	LastFailure     *time.Time `json:"last_failure,omitempty"`
+}
+
+// metricsTracker keeps the live numbers the dashboard shows: the input
+// level while recording and per provider latency and errors. Nothing is
+// persisted.
+type metricsTracker struct {
+	mu        sync.Mutex
+	level     float64
+	providers map[string]*providerHealth
+	latencies map[string][]time.Duration
+
+	// peakHeap and peakRecording are the highest memoryStats seen.
+	peakHeap      uint64
+	peakRecording int64
+}
+
+// memoryStats are the memory numbers of dictation status -debug, in bytes.
+type memoryStats struct {
+	Heap uint64 `json:"heap_bytes"`
+	// PeakHeap is the largest heap seen while recording or asked for the
+	// status. It is sampled, the real peak may be a little higher.
+	PeakHeap uint64 `json:"peak_heap_bytes"`
+	// Sys is what the Go runtime got from the system.
+	Sys uint64 `json:"sys_bytes"`
+	// PeakRecording is the most the samples of one recording took.
+	PeakRecording int64  `json:"peak_recording_bytes"`
+	GCs           uint32 `json:"gc_count"`
+}
+
+// latencyWindow is how many requests per provider the median is taken over.
+const latencyWindow = 50
+
+var metrics = &metricsTracker{
+	providers: map[string]*providerHealth{},
+	latencies: map[string][]time.Duration{},
+}
+
+// setLevel records the input level (RMS) of the last buffer.
+func (m *metricsTracker) setLevel(level float64) {
+	m.mu.Lock()
+	m.level = level
+	m.mu.Unlock()
+}
+
+// rmsLevel returns the RMS of a buffer, 0 (silence) to 1 (full scale).
+func rmsLevel(buffer []float32) float64 {
+	if len(buffer) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range buffer {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(buffer)))
+}
+
+// sampleMemory updates the peaks, with the memory the recording in progress
+// takes; 0 when not recording.
+func (m *metricsTracker) sampleMemory(recording int64) memoryStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.peakHeap = max(m.peakHeap, ms.HeapAlloc)
+	m.peakRecording = max(m.peakRecording, recording)
+	return memoryStats{
+		Heap:          ms.HeapAlloc,
+		PeakHeap:      m.peakHeap,
+		Sys:           ms.Sys,
+		PeakRecording: m.peakRecording,
+		GCs:           ms.NumGC,
+	}
+}
+
+func (m *metricsTracker) resetLevel() {
+	m.mu.Lock()
+	m.level = 0
+	m.mu.Unlock()
+}
+
+// record adds the outcome of a transcription request.
+func (m *metricsTracker) record(provider string, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.providers[provider]
+	if h == nil {
+		h = &providerHealth{}
+		m.providers[provider] = h
+	}
+	now := time.Now()
+	h.Requests++
+	if err != nil {
+		h.Failures++
+		h.LastError = err.Error()
+		h.LastFailure = &now
+		return
+	}
+	h.LastError = ""
+	h.LastSuccess = &now
+
+	window := append(m.latencies[provider], latency)
+	if len(window) > latencyWindow {
+		window = window[1:]
+	}
+	m.latencies[provider] = window
+
+	sorted := append([]time.Duration(nil), window...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	h.LastLatencyMs = latency.Milliseconds()
+	h.MedianLatencyMs = sorted[len(sorted)/2].Milliseconds()
+}
+
+// snapshot returns the level and a copy of the provider health.
+func (m *metricsTracker) snapshot() (float64, map[string]providerHealth) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	providers := make(map[string]providerHealth, len(m.providers))
+	for name, h := range m.providers {
+		providers[name] = *h
+	}
+	return m.level, providers
+}
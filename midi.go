@@ -0,0 +1,66 @@
+package main
+
+import "context"
+
+const (
+	midiNoteOff       = 0x80
+	midiNoteOn        = 0x90
+	midiControlChange = 0xB0
+)
+
+// handleMIDIMessage turns a raw MIDI channel message into a pedal down/up
+// event if it matches the configured control or note. It is called from the
+// CoreMIDI thread, so it must not block.
+func handleMIDIMessage(status, data1, data2 byte) {
+	cfg := config.Trigger.MIDI
+
+	var down bool
+	switch status & 0xF0 {
+	case midiControlChange:
+		if cfg.Control < 0 || int(data1) != cfg.Control {
+			return
+		}
+		down = data2 >= 64
+	case midiNoteOn:
+		if cfg.Note < 0 || int(data1) != cfg.Note {
+			return
+		}
+		down = data2 > 0 // note on with zero velocity means note off
+	case midiNoteOff:
+		if cfg.Note < 0 || int(data1) != cfg.Note {
+			return
+		}
+		down = false
+	default:
+		return
+	}
+
+	select {
+	case pedalEvents <- down:
+	default:
+	}
+}
+
+// pedalDown remembers the last pedal state so repeated messages (some pedals
+// send a stream of control changes while moving) only count once.
+var pedalDown bool
+
+func handlePedalEvent(ctx context.Context, down bool) {
+	if down == pedalDown {
+		return
+	}
+	pedalDown = down
+
+	if config.Trigger.MIDI.Mode == "hold" {
+		if down {
+			startDictation(ctx, "Pedal pressed", triggerProfile(), "")
+		} else {
+			stopDictation("Pedal released")
+		}
+		return
+	}
+
+	if down {
+		toggleDictation(ctx, "Pedal pressed")
+	}
+}
@@ -0,0 +1,76 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreMIDI -framework CoreFoundation
+#include <CoreMIDI/CoreMIDI.h>
+
+extern void goMIDIMessage(unsigned char status, unsigned char data1, unsigned char data2);
+
+static void midiReadProc(const MIDIPacketList *list, void *readProcRefCon, void *srcConnRefCon) {
+	const MIDIPacket *packet = &list->packet[0];
+	for (UInt32 i = 0; i < list->numPackets; i++) {
+		UInt16 j = 0;
+		while (j < packet->length) {
+			unsigned char status = packet->data[j];
+			unsigned char kind = status & 0xF0;
+			if ((kind == 0x80 || kind == 0x90 || kind == 0xB0) && j + 2 < packet->length) {
+				goMIDIMessage(status, packet->data[j + 1], packet->data[j + 2]);
+				j += 3;
+			} else {
+				j++;
+			}
+		}
+		packet = MIDIPacketNext(packet);
+	}
+}
+
+static MIDIClientRef midiClient;
+static MIDIPortRef midiPort;
+
+// startMIDIInput connects to every MIDI source currently attached and returns
+// how many there are, or -1 on failure.
+static int startMIDIInput(void) {
+	if (MIDIClientCreate(CFSTR("dictation"), NULL, NULL, &midiClient) != noErr) {
+		return -1;
+	}
+	if (MIDIInputPortCreate(midiClient, CFSTR("dictation input"), midiReadProc, NULL, &midiPort) != noErr) {
+		MIDIClientDispose(midiClient);
+		return -1;
+	}
+
+	ItemCount n = MIDIGetNumberOfSources();
+	for (ItemCount i = 0; i < n; i++) {
+		MIDIPortConnectSource(midiPort, MIDIGetSource(i), NULL);
+	}
+	return (int)n;
+}
+
+static void stopMIDIInput(void) {
+	MIDIPortDispose(midiPort);
+	MIDIClientDispose(midiClient);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+)
+
+//export goMIDIMessage
+func goMIDIMessage(status, data1, data2 C.uchar) {
+	handleMIDIMessage(byte(status), byte(data1), byte(data2))
+}
+
+func startMIDIListener() (stop func(), err error) {
+	n := C.startMIDIInput()
+	if n < 0 {
+		return nil, errors.New("creating CoreMIDI client")
+	}
+	if n == 0 {
+		fmt.Println("Warning: no MIDI devices found, plug in the pedal before starting")
+	}
+	return func() { C.stopMIDIInput() }, nil
+}
@@ -0,0 +1,9 @@
+//go:build !darwin
+
+package main
+
+import "errors"
+
+func startMIDIListener() (stop func(), err error) {
+	return nil, errors.New("MIDI trigger is only supported on macOS")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// modelInfo describes what an OpenAI transcription model accepts. The
+// gpt-4o based models only answer in json or text, have no timestamps, but
+// can stream the transcript as it is produced.
+type modelInfo struct {
+	responseFormats []string
+	timestamps      bool
+	streaming       bool
+}
+
+var (
+	whisperModel = modelInfo{
+		responseFormats: []string{"json", "text", "srt", "verbose_json", "vtt"},
+		timestamps:      true,
+	}
+	gpt4oTranscribeModel = modelInfo{
+		responseFormats: []string{"json", "text"},
+		streaming:       true,
+	}
+)
+
+// lookupModel returns the capabilities of an OpenAI model. Unknown models
+// (other providers, fine-tunes, local servers) are assumed to behave like
+// whisper-1, which is what OpenAI compatible servers implement.
+func lookupModel(model string) modelInfo {
+	if strings.HasPrefix(model, "gpt-4o-transcribe") || strings.HasPrefix(model, "gpt-4o-mini-transcribe") {
+		return gpt4oTranscribeModel
+	}
+	return whisperModel
+}
+
+func (m modelInfo) check(model string, p TranscriptionParams) error {
+	if p.ResponseFormat != "" && !slices.Contains(m.responseFormats, p.ResponseFormat) {
+		return fmt.Errorf("model %s doesn't support response_format %q", model, p.ResponseFormat)
+	}
+	if len(p.TimestampGranularities) > 0 && !m.timestamps {
+		return fmt.Errorf("model %s doesn't support timestamp_granularities", model)
+	}
+	if p.streams() && !m.streaming {
+		return fmt.Errorf("model %s doesn't support streaming", model)
+	}
+	return nil
+}
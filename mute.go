@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// audio.muted settings.
+	mutedWarn   = "warn"
+	mutedUnmute = "unmute"
+	mutedAllow  = "allow"
+
+	// unmuteVolume is what audio.muted "unmute" turns an input that is all
+	// the way down up to, 0 to 1, for the recording.
+	unmuteVolume = 0.5
+)
+
+// errInputMuted fails a recording from a muted input that came out silent,
+// which would otherwise be uploaded and come back as a hallucination like
+// "Thank you."
+var errInputMuted = errors.New("the input is muted, nothing was recorded")
+
+// inputLevel is the mute switch and volume of an input device.
+type inputLevel struct {
+	muted bool
+	// volume is 0 to 1, -1 for devices without a volume control.
+	volume float64
+}
+
+func (l inputLevel) silenced() bool {
+	return l.muted || l.volume >= 0 && l.volume < 0.001
+}
+
+// muteGuard applies audio.muted to the input about to be recorded from
+// (device is its name, "" for the default one) when it is muted or turned
+// all the way down: it warns right away, or unmutes it for the recording.
+// restore puts the input back as it was once the recording is over; muted
+// reports whether it stays muted.
+func muteGuard(device string) (restore func(), muted bool) {
+	restore = func() {}
+	if config.Audio.Muted == mutedAllow {
+		return restore, false
+	}
+	level, ok := inputMuteState(device)
+	if !ok || !level.silenced() {
+		return restore, false
+	}
+	name, shown := device, device
+	if device == "" {
+		name, shown = "the input device", tr("The input device")
+	}
+	state := "muted"
+	if !level.muted {
+		state = "turned all the way down"
+	}
+
+	if config.Audio.Muted == mutedUnmute {
+		unmuted := inputLevel{volume: level.volume}
+		if unmuted.silenced() {
+			unmuted.volume = unmuteVolume
+		}
+		err := setInputMute(device, unmuted)
+		if err == nil {
+			fmt.Printf("Unmuted %s for the recording, it was %s\n", name, state)
+			return func() {
+				if err := setInputMute(device, level); err != nil {
+					fmt.Printf("Warning: muting %s again: %v\n", name, err)
+				}
+			}, false
+		}
+		fmt.Printf("Warning: can't unmute %s: %v\n", name, err)
+	}
+
+	fmt.Printf("Warning: %s is %s, the recording will be silent; set audio.muted to \"unmute\" to unmute it while recording\n", name, state)
+	notify(tr("Dictation: microphone muted"), tr("%s is muted or turned all the way down, unmute it and record again", shown))
+	return restore, true
+}
@@ -0,0 +1,163 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreAudio -framework CoreFoundation
+#include <CoreAudio/CoreAudio.h>
+#include <stdlib.h>
+
+// muteDevice returns the input device named name, or the default input when
+// name is empty or no device has it.
+static AudioDeviceID muteDevice(const char *name) {
+	AudioObjectPropertyAddress address = {kAudioHardwarePropertyDefaultInputDevice, kAudioObjectPropertyScopeGlobal, 0};
+	AudioDeviceID found = kAudioObjectUnknown;
+	UInt32 size = sizeof found;
+	AudioObjectGetPropertyData(kAudioObjectSystemObject, &address, 0, NULL, &size, &found);
+	if (name[0] == 0) {
+		return found;
+	}
+
+	address.mSelector = kAudioHardwarePropertyDevices;
+	if (AudioObjectGetPropertyDataSize(kAudioObjectSystemObject, &address, 0, NULL, &size) != noErr) {
+		return found;
+	}
+	AudioDeviceID *ids = malloc(size);
+	CFStringRef want = CFStringCreateWithCString(NULL, name, kCFStringEncodingUTF8);
+	if (ids != NULL && want != NULL && AudioObjectGetPropertyData(kAudioObjectSystemObject, &address, 0, NULL, &size, ids) == noErr) {
+		for (UInt32 i = 0; i < size / sizeof *ids; i++) {
+			AudioObjectPropertyAddress streams = {kAudioDevicePropertyStreams, kAudioObjectPropertyScopeInput, 0};
+			UInt32 streamsSize = 0;
+			if (AudioObjectGetPropertyDataSize(ids[i], &streams, 0, NULL, &streamsSize) != noErr || streamsSize == 0) {
+				continue;
+			}
+			AudioObjectPropertyAddress nameAddress = {kAudioObjectPropertyName, kAudioObjectPropertyScopeGlobal, 0};
+			CFStringRef deviceName = NULL;
+			UInt32 nameSize = sizeof deviceName;
+			if (AudioObjectGetPropertyData(ids[i], &nameAddress, 0, NULL, &nameSize, &deviceName) != noErr || deviceName == NULL) {
+				continue;
+			}
+			Boolean same = CFStringCompare(deviceName, want, 0) == kCFCompareEqualTo;
+			CFRelease(deviceName);
+			if (same) {
+				found = ids[i];
+				break;
+			}
+		}
+	}
+	if (want != NULL) {
+		CFRelease(want);
+	}
+	free(ids);
+	return found;
+}
+
+// Devices have their input mute and volume on the main element (0) or only
+// per channel; the first channel (1) stands for them all when reading, and
+// the first two are set.
+
+static int inputElement(AudioDeviceID id, AudioObjectPropertySelector selector) {
+	for (UInt32 element = 0; element <= 1; element++) {
+		AudioObjectPropertyAddress address = {selector, kAudioObjectPropertyScopeInput, element};
+		if (AudioObjectHasProperty(id, &address)) {
+			return element;
+		}
+	}
+	return -1;
+}
+
+// inputMute returns 1 if the input is muted, 0 if not, -1 if it has no mute.
+static int inputMute(AudioDeviceID id) {
+	int element = inputElement(id, kAudioDevicePropertyMute);
+	if (element < 0) {
+		return -1;
+	}
+	AudioObjectPropertyAddress address = {kAudioDevicePropertyMute, kAudioObjectPropertyScopeInput, element};
+	UInt32 muted = 0;
+	UInt32 size = sizeof muted;
+	if (AudioObjectGetPropertyData(id, &address, 0, NULL, &size, &muted) != noErr) {
+		return -1;
+	}
+	return muted != 0;
+}
+
+// inputVolume returns the input volume, 0 to 1, or -1 if it has none.
+static double inputVolume(AudioDeviceID id) {
+	int element = inputElement(id, kAudioDevicePropertyVolumeScalar);
+	if (element < 0) {
+		return -1;
+	}
+	AudioObjectPropertyAddress address = {kAudioDevicePropertyVolumeScalar, kAudioObjectPropertyScopeInput, element};
+	Float32 volume = 0;
+	UInt32 size = sizeof volume;
+	if (AudioObjectGetPropertyData(id, &address, 0, NULL, &size, &volume) != noErr) {
+		return -1;
+	}
+	return volume;
+}
+
+// setInput sets the mute and, unless volume is negative, the volume of the
+// input. It returns 0 if something could be set.
+static int setInput(AudioDeviceID id, int muted, double volume) {
+	int set = 0;
+	for (UInt32 element = 0; element <= 2; element++) {
+		AudioObjectPropertyAddress address = {kAudioDevicePropertyMute, kAudioObjectPropertyScopeInput, element};
+		Boolean settable = false;
+		if (AudioObjectHasProperty(id, &address) && AudioObjectIsPropertySettable(id, &address, &settable) == noErr && settable) {
+			UInt32 value = muted;
+			set |= AudioObjectSetPropertyData(id, &address, 0, NULL, sizeof value, &value) == noErr;
+		}
+		if (volume < 0) {
+			continue;
+		}
+		address.mSelector = kAudioDevicePropertyVolumeScalar;
+		settable = false;
+		if (AudioObjectHasProperty(id, &address) && AudioObjectIsPropertySettable(id, &address, &settable) == noErr && settable) {
+			Float32 value = volume;
+			set |= AudioObjectSetPropertyData(id, &address, 0, NULL, sizeof value, &value) == noErr;
+		}
+	}
+	return set ? 0 : -1;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// inputMuteState reads the mute switch and volume of an input device from
+// CoreAudio. ok is false when it has neither.
+func inputMuteState(device string) (inputLevel, bool) {
+	id := muteDeviceID(device)
+	if id == C.kAudioObjectUnknown {
+		return inputLevel{}, false
+	}
+	muted, volume := C.inputMute(id), float64(C.inputVolume(id))
+	if muted < 0 && volume < 0 {
+		return inputLevel{}, false
+	}
+	return inputLevel{muted: muted == 1, volume: volume}, true
+}
+
+func setInputMute(device string, level inputLevel) error {
+	id := muteDeviceID(device)
+	if id == C.kAudioObjectUnknown {
+		return errors.New("no input device")
+	}
+	muted := C.int(0)
+	if level.muted {
+		muted = 1
+	}
+	if C.setInput(id, muted, C.double(level.volume)) != 0 {
+		return errors.New("the device doesn't allow it")
+	}
+	return nil
+}
+
+func muteDeviceID(device string) C.AudioDeviceID {
+	name := C.CString(device)
+	defer C.free(unsafe.Pointer(name))
+	return C.muteDevice(name)
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// defaultSource is how pactl names the default input. Device names from
+// portaudio aren't PulseAudio source names, so the default one is used.
+const defaultSource = "@DEFAULT_SOURCE@"
+
+// pactl runs pactl in the C locale, as its output is parsed and is
+// translated otherwise ("Mute: ja").
+func pactl(args ...string) *exec.Cmd {
+	cmd := exec.Command("pactl", args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	return cmd
+}
+
+// inputMuteState reads the mute switch and volume of the default input
+// with pactl, which PulseAudio and PipeWire both have. ok is false without
+// it.
+func inputMuteState(device string) (inputLevel, bool) {
+	out, err := pactl("get-source-mute", defaultSource).Output()
+	if err != nil {
+		return inputLevel{}, false
+	}
+	level := inputLevel{muted: strings.Contains(string(out), "yes"), volume: -1}
+	// "Volume: front-left: 0 /   0% / -inf dB,   front-right: ..."; the
+	// first channel stands for them all.
+	if out, err := pactl("get-source-volume", defaultSource).Output(); err == nil {
+		for _, field := range strings.Fields(string(out)) {
+			if percent, ok := strings.CutSuffix(field, "%"); ok {
+				if n, err := strconv.Atoi(percent); err == nil {
+					level.volume = float64(n) / 100
+				}
+				break
+			}
+		}
+	}
+	return level, true
+}
+
+func setInputMute(device string, level inputLevel) error {
+	mute := "0"
+	if level.muted {
+		mute = "1"
+	}
+	if out, err := pactl("set-source-mute", defaultSource, mute).CombinedOutput(); err != nil {
+		return fmt.Errorf("pactl: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if level.volume < 0 {
+		return nil
+	}
+	volume := fmt.Sprintf("%d%%", int(level.volume*100+0.5))
+	if out, err := pactl("set-source-volume", defaultSource, volume).CombinedOutput(); err != nil {
+		return fmt.Errorf("pactl: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !darwin && !linux
+
+package main
+
+import "errors"
+
+// Elsewhere the mute state of the input isn't known, so there is no mute
+// guard.
+
+func inputMuteState(device string) (inputLevel, bool) {
+	return inputLevel{}, false
+}
+
+func setInputMute(device string, level inputLevel) error {
+	return errors.New("not supported on this system")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// notify shows a desktop notification. It is best effort: failures are only
+// printed, and on platforms without a notification tool it does nothing.
+func notify(title, message string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(message), strconv.Quote(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "linux":
+		cmd = exec.Command("notify-send", title, message)
+	default:
+		return
+	}
+
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("Warning: failed to show notification: %v\n", err)
+	}
+}
+
+// soundsPlaying counts the sounds being played. While it isn't zero the
+// recording is ducked (see audio.duck_playback), so a cue played during
+// dictation doesn't end up in the transcription.
+var soundsPlaying atomic.Int32
+
+// playbackTail is how long capture stays ducked after a sound ended, for
+// the echo of the room and the player's own output latency.
+const playbackTail = 200 * time.Millisecond
+
+// playSound plays a sound file without waiting for it to finish. Like
+// notify it is best effort.
+func playSound(path string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("afplay", path)
+	case "linux":
+		cmd = exec.Command("paplay", path)
+	default:
+		return
+	}
+
+	soundsPlaying.Add(1)
+	if err := cmd.Start(); err != nil {
+		soundsPlaying.Add(-1)
+		fmt.Printf("Warning: failed to play sound: %v\n", err)
+		return
+	}
+	go func() {
+		cmd.Wait()
+		time.Sleep(playbackTail)
+		soundsPlaying.Add(-1)
+	}()
+}
+
+// confirm asks a yes/no question in a dialog and reports whether the user
+// agreed. It gives up, as a no, after 10 seconds and is a no where dialogs
+// aren't supported.
+func confirm(question string) bool {
+	if runtime.GOOS != "darwin" {
+		return false
+	}
+	script := fmt.Sprintf(`display dialog %s buttons {%s, "OK"} default button "OK" cancel button %[2]s giving up after 10`, strconv.Quote(question), strconv.Quote(tr("Cancel")))
+	out, err := exec.Command("osascript", "-e", script).Output()
+	if err != nil {
+		return false // Cancel makes osascript exit with an error
+	}
+	return strings.Contains(string(out), "button returned:OK") && !strings.Contains(string(out), "gave up:true")
+}
+
+// reviewCleanup shows what the LLM cleanup changed, as a word diff, in a
+// dialog whose text field holds the cleaned up text. The user can insert it
+// as is or after editing it, or go back to the original transcription. An
+// unanswered dialog, or no dialog support, keeps the cleaned up text.
+func reviewCleanup(original, cleaned string) string {
+	if original == cleaned || runtime.GOOS != "darwin" {
+		return cleaned
+	}
+	script := fmt.Sprintf(`set r to display dialog %s default answer %s buttons {%s, %s} default button %[4]s with title %s giving up after 120
+if gave up of r then return "cleaned:"
+if button returned of r is %[3]s then return "original:"
+return "edited:" & text returned of r`, strconv.Quote(wordDiff(original, cleaned)), strconv.Quote(cleaned),
+		strconv.Quote(tr("Use original")), strconv.Quote(tr("Insert")), strconv.Quote(tr("Review cleanup")))
+	out, err := exec.Command("osascript", "-e", script).Output()
+	if err != nil {
+		return cleaned
+	}
+	result := strings.TrimSuffix(string(out), "\n")
+	switch {
+	case result == "original:":
+		return original
+	case strings.HasPrefix(result, "edited:"):
+		return strings.TrimPrefix(result, "edited:")
+	default:
+		return cleaned
+	}
+}
+
+// Answers of the preview dialog.
+const (
+	previewInsert      = "insert"
+	previewCopy        = "copy"
+	previewCancel      = "cancel"
+	previewUnavailable = "unavailable"
+)
+
+// previewText shows a transcription in a dialog with Insert, Copy and
+// Cancel buttons, for text that couldn't go straight into the app it was
+// dictated for. Insert types it where the focus goes back to once the
+// dialog is closed. Without dialog support it returns previewUnavailable.
+func previewText(title, text string) string {
+	if runtime.GOOS != "darwin" {
+		return previewUnavailable
+	}
+	script := fmt.Sprintf(`set r to display dialog %s buttons {%s, %s, %s} default button %[4]s cancel button %[2]s with title %s giving up after 120
+if gave up of r then return "cancel"
+if button returned of r is %[3]s then return "copy"
+return "insert"`, strconv.Quote(text), strconv.Quote(tr("Cancel")), strconv.Quote(tr("Copy")), strconv.Quote(tr("Insert")), strconv.Quote(title))
+	out, err := exec.Command("osascript", "-e", script).Output()
+	if err != nil {
+		return previewCancel // Cancel makes osascript exit with an error
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LLM types: an OpenAI compatible chat completions endpoint, or Ollama's
+// own API.
+const (
+	llmOpenAI = "openai"
+	llmOllama = "ollama"
+)
+
+// defaultOllamaURL is where Ollama listens unless told otherwise.
+const defaultOllamaURL = "http://localhost:11434"
+
+// ollamaURL returns the base URL of an Ollama server, without a trailing /.
+func ollamaURL(llm LLMConfig) string {
+	if llm.URL == "" {
+		return defaultOllamaURL
+	}
+	return strings.TrimRight(llm.URL, "/")
+}
+
+// ollamaChat sends a system and a user message to Ollama's chat endpoint
+// and returns the reply. Ollama has no API keys, none is sent.
+func ollamaChat(llm LLMConfig, system, user string) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	options := map[string]any{}
+	if llm.Temperature != nil {
+		options["temperature"] = *llm.Temperature
+	}
+	body, err := json.Marshal(struct {
+		Model     string         `json:"model"`
+		Messages  []message      `json:"messages"`
+		Stream    bool           `json:"stream"`
+		Options   map[string]any `json:"options,omitempty"`
+		KeepAlive string         `json:"keep_alive,omitempty"`
+	}{
+		Model:     llm.Model,
+		Messages:  []message{{"system", system}, {"user", user}},
+		Options:   options,
+		KeepAlive: llm.KeepAlive,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encoding request: %w", err)
+	}
+
+	client := &http.Client{Timeout: llmTimeout}
+	resp, err := client.Post(ollamaURL(llm)+"/api/chat", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("sending request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(msg, &e) == nil && e.Error != "" {
+			msg = []byte(e.Error)
+		}
+		return "", fmt.Errorf("Ollama returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		Message message `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+	if result.Message.Content == "" {
+		return "", errors.New("Ollama returned an empty reply")
+	}
+	return strings.TrimSpace(result.Message.Content), nil
+}
+
+// ollamaModels lists the models pulled into an Ollama server.
+func ollamaModels(llm LLMConfig) ([]string, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(ollamaURL(llm) + "/api/tags")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Ollama returned %s", resp.Status)
+	}
+	var result struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding model list: %w", err)
+	}
+	names := make([]string, len(result.Models))
+	for i, m := range result.Models {
+		names[i] = m.Name
+	}
+	return names, nil
+}
+
+// checkOllama checks that the Ollama server runs and has the model, which
+// is named without a tag for :latest.
+func checkOllama(c *check, llm LLMConfig) {
+	models, err := ollamaModels(llm)
+	if err != nil {
+		c.info = fmt.Sprintf("can't reach Ollama at %s: %v", ollamaURL(llm), err)
+		c.fix = "start Ollama (ollama serve, or the Ollama app), or fix the llm url"
+		return
+	}
+	for _, m := range models {
+		if m == llm.Model || m == llm.Model+":latest" {
+			c.ok = true
+			c.info = fmt.Sprintf("Ollama has %s", llm.Model)
+			return
+		}
+	}
+	c.info = fmt.Sprintf("Ollama doesn't have the model %s", llm.Model)
+	c.fix = "ollama pull " + llm.Model
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// runOnceCommand records once, for a fixed time or until the speaker goes
+// quiet, and prints the transcription: dictation for scripts, and for
+// setups where global hotkeys can't be used. Progress goes to stderr, so
+// stdout is only the text.
+func runOnceCommand(args []string) error {
+	flags := flag.NewFlagSet("once", flag.ExitOnError)
+	profileName := flags.String("profile", defaultProfile, "transcription profile to use")
+	maxLength := flags.Duration("max", 30*time.Second, "record at most this long")
+	silence := flags.Duration("silence", 0, "stop after this much silence once speech started, e.g. 2s (0 waits for -max)")
+	copyText := flags.Bool("copy", false, "also copy the text to the clipboard")
+	typeText := flags.Bool("type", false, "also type the text into the focused app")
+	flags.Parse(args)
+	if *maxLength <= 0 {
+		return errors.New("-max must be positive")
+	}
+
+	profile, err := fileProfile(*profileName)
+	if err != nil {
+		return err
+	}
+	if profile.LanguageFromInput {
+		if lang := inputSourceLanguage(); lang != "" {
+			profile.Params.Language = lang
+		}
+	}
+	if profile.CodeMode {
+		profile.Params.Prompt = codePrompt(profile.Params.Prompt)
+	}
+	profile.Params.Prompt = vocabularyPrompt(profile.Params.Prompt)
+
+	// Ctrl+C ends the recording early, it is still transcribed.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	samples, err := recordOnce(ctx, *maxLength, *silence)
+	if err != nil {
+		return err
+	}
+	cancel()
+
+	fmt.Fprintln(os.Stderr, "Transcribing...")
+	text, err := transcribeSamples(samples, profile, config.provider(profile), nil)
+	if err != nil {
+		return fmt.Errorf("transcribing: %w", err)
+	}
+	// There is no cursor to place in a script's output.
+	text, _ = cutCursor(formatOutput(profile, "", postProcess(text, profile)))
+	fmt.Println(text)
+
+	if *copyText {
+		if err := injector.WriteClipboard(text); err != nil {
+			return fmt.Errorf("copying to the clipboard: %w", err)
+		}
+	}
+	if *typeText {
+		insertText(text)
+	}
+	recordHistory(newRecordingID(), profile, text, "", "")
+	return nil
+}
+
+// recordOnce records until maxLength passed, ctx is done or, with silence
+// set, the speaker was quiet that long after having said something.
+func recordOnce(ctx context.Context, maxLength, silence time.Duration) ([]float32, error) {
+	pause := &pauseDetector{silence: silence, max: maxLength}
+	samples, err := captureAudio(ctx, captureHooks{
+		Started: func(string) {
+			fmt.Fprintf(os.Stderr, "Recording for up to %s, Ctrl+C to stop...\n", maxLength)
+		},
+		Progress: func(p captureProgress) bool {
+			return !pause.over(p)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, errors.New("nothing was recorded")
+	}
+	return samples, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Runtime files follow the platform's conventions. On macOS that is
+// ~/Library/Application Support for config and data, ~/Library/Logs and
+// ~/Library/Caches. Elsewhere, and on macOS when XDG_* variables are set,
+// the XDG base directories are used. Installs that predate this keep using
+// ~/.config/dictation for config and data as long as it exists.
+
+func homeDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating home directory: %w", err)
+	}
+	return home, nil
+}
+
+// legacyDir returns ~/.config/dictation on macOS if it exists, or "".
+func legacyDir(home string) string {
+	if runtime.GOOS != "darwin" {
+		return ""
+	}
+	dir := filepath.Join(home, ".config", "dictation")
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return ""
+	}
+	return dir
+}
+
+// appDir picks the directory for one kind of file: the XDG variable if it
+// is set, then the macOS location, then the XDG default below home.
+func appDir(xdgVar, macOS, xdgDefault string, legacy bool) (string, error) {
+	if dir := os.Getenv(xdgVar); dir != "" {
+		return filepath.Join(dir, "dictation"), nil
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "darwin" {
+		if dir := legacyDir(home); legacy && dir != "" {
+			return dir, nil
+		}
+		return filepath.Join(home, "Library", macOS, "dictation"), nil
+	}
+	return filepath.Join(home, xdgDefault, "dictation"), nil
+}
+
+// configOverride is the config file given with --config.
+var configOverride string
+
+// configPath returns the config file: the one given with --config or in
+// DICTATION_CONFIG, or the default one.
+func configPath() (string, error) {
+	if path, ok := explicitConfigPath(); ok {
+		return expandHome(path)
+	}
+	dir, err := appDir("XDG_CONFIG_HOME", "Application Support", ".config", true)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// explicitConfigPath returns the config file the user asked for, if any.
+func explicitConfigPath() (string, bool) {
+	if configOverride != "" {
+		return configOverride, true
+	}
+	if path := os.Getenv("DICTATION_CONFIG"); path != "" {
+		return path, true
+	}
+	return "", false
+}
+
+// expandHome replaces a leading ~ in a path with the home directory.
+func expandHome(path string) (string, error) {
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || (rest != "" && rest[0] != '/' && rest[0] != filepath.Separator) {
+		return path, nil
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, rest), nil
+}
+
+// dataDir is where state that isn't configuration, like usage, is kept:
+// the config's data_dir if it has one, so separate setups keep separate
+// history and usage.
+func dataDir() (string, error) {
+	if config.DataDir != "" {
+		return expandHome(config.DataDir)
+	}
+	return appDir("XDG_DATA_HOME", "Application Support", filepath.Join(".local", "share"), true)
+}
+
+// logDir is where the log is written in daemon mode, logs in data_dir if
+// the config has one.
+func logDir() (string, error) {
+	if config.DataDir != "" {
+		return dataSubdir("logs")
+	}
+	return appDir("XDG_STATE_HOME", "Logs", filepath.Join(".local", "state"), false)
+}
+
+// cacheDir holds files that can be deleted at any time, like recordings
+// waiting to be transcribed; cache in data_dir if the config has one.
+func cacheDir() (string, error) {
+	if config.DataDir != "" {
+		return dataSubdir("cache")
+	}
+	return appDir("XDG_CACHE_HOME", "Caches", ".cache", false)
+}
+
+func dataSubdir(name string) (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// runtimeDir holds the control socket. A setup with its own data_dir has
+// its socket there too, so it can run next to another one.
+func runtimeDir() (string, error) {
+	if config.DataDir != "" {
+		return dataDir()
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "dictation"), nil
+	}
+	return dataDir()
+}
@@ -0,0 +1,55 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework ApplicationServices -framework AVFoundation -framework Carbon
+#import <ApplicationServices/ApplicationServices.h>
+#import <AVFoundation/AVFoundation.h>
+#import <Carbon/Carbon.h>
+
+static double secondsSinceKeyDown(void) {
+	return CGEventSourceSecondsSinceLastEventType(kCGEventSourceStateHIDSystemState, kCGEventKeyDown);
+}
+
+static int micAuthorizationStatus(void) {
+	return (int)[AVCaptureDevice authorizationStatusForMediaType:AVMediaTypeAudio];
+}
+*/
+import "C"
+
+// microphonePermission returns the microphone authorization of the process
+// (really of the terminal app it runs in): "granted", "denied",
+// "restricted" or "not determined".
+func microphonePermission() string {
+	switch C.micAuthorizationStatus() {
+	case 3:
+		return "granted"
+	case 2:
+		return "denied"
+	case 1:
+		return "restricted"
+	default:
+		return "not determined"
+	}
+}
+
+// accessibilityTrusted reports whether the process may observe and send
+// keyboard events.
+func accessibilityTrusted() bool {
+	return C.AXIsProcessTrusted() != 0
+}
+
+// secondsSinceLastKeyPress returns how long ago the system last saw a key
+// press, whether or not any event tap got it.
+func secondsSinceLastKeyPress() (float64, bool) {
+	return float64(C.secondsSinceKeyDown()), true
+}
+
+// secureEventInput reports whether Secure Event Input is on (a password
+// field, Terminal's Secure Keyboard Entry), which keeps key presses from
+// all event taps.
+func secureEventInput() bool {
+	return C.IsSecureEventInputEnabled() != 0
+}
@@ -0,0 +1,23 @@
+//go:build !darwin
+
+package main
+
+// Other systems don't gate the microphone or keyboard access per app.
+
+func microphonePermission() string {
+	return "granted"
+}
+
+func accessibilityTrusted() bool {
+	return true
+}
+
+// secondsSinceLastKeyPress isn't known here, so key listeners aren't
+// watched.
+func secondsSinceLastKeyPress() (float64, bool) {
+	return 0, false
+}
+
+func secureEventInput() bool {
+	return false
+}
@@ -0,0 +1,25 @@
+package main
+
+// postProcess runs the text cleanup stages on a transcription before it is
+// inserted.
+func postProcess(text string, profile Profile) string {
+	// First, before the other stages make it harder to recognize.
+	text = dropHallucinations(text)
+	if profile.VoiceCommands || profile.CodeMode {
+		text = applyVoiceCommands(text)
+	}
+	if profile.CodeMode {
+		text = applyCodeSymbols(text)
+	}
+	text = applyReplacements(text, presetReplacements(profile))
+	text = applyVocabulary(text)
+	if profile.RemoveFillers {
+		text = removeDisfluencies(text)
+	}
+	if profile.Cleanup {
+		text = cleanupStage(text, profile)
+	}
+	// Last, so the LLM can't bring a filtered word back.
+	text = filterProfanity(text, config.Profanity)
+	return text
+}
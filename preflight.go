@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// preflightInterval is how often the providers are checked again while
+// running, so a key that expired overnight is noticed before the first
+// dictation of the day fails.
+const preflightInterval = 6 * time.Hour
+
+// usedProviders returns the names of the providers profiles use, sorted.
+func usedProviders() []string {
+	used := map[string]bool{}
+	for _, profile := range config.Profiles {
+		used[profile.Provider] = true
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// providerSetup is a provider as profiles use it, with their credential's
+// API key and account. name is the provider's, with the credential's after
+// it: "openai (work)".
+type providerSetup struct {
+	name     string
+	provider Provider
+}
+
+// usedProviderSetups returns the provider setups profiles use, sorted by
+// name.
+func usedProviderSetups() []providerSetup {
+	byName := map[string]Provider{}
+	for _, profile := range config.Profiles {
+		name := profile.Provider
+		if profile.Credential != "" {
+			name += " (" + profile.Credential + ")"
+		}
+		byName[name] = config.provider(profile)
+	}
+	setups := make([]providerSetup, 0, len(byName))
+	for name, provider := range byName {
+		setups = append(setups, providerSetup{name, provider})
+	}
+	slices.SortFunc(setups, func(a, b providerSetup) int { return strings.Compare(a.name, b.name) })
+	return setups
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// preflight checks that the providers in use can be reached and accept
+// their API key, with each credential profiles use them with, by the same
+// cheap requests as dictation doctor, and records the problems in the
+// status. Local workers aren't checked, they are warmed up at startup
+// anyway.
+func preflight() map[string]string {
+	problems := map[string]string{}
+	for _, setup := range usedProviderSetups() {
+		c := check{name: "provider " + setup.name}
+		switch {
+		case setup.provider.local():
+			continue
+		case setup.provider.Type == providerFasterWhisperWS:
+			checkServer(&c, setup.provider)
+		default:
+			checkAPI(&c, setup.provider)
+		}
+		if !c.ok {
+			problems[setup.name] = c.info
+		}
+	}
+	status.setProviderProblems(problems)
+	return problems
+}
+
+// watchPreflight runs the preflight at startup and every
+// preflightInterval, with a notification for every new problem.
+func watchPreflight(ctx context.Context) {
+	if !config.Preflight {
+		return
+	}
+	var known map[string]string
+	for {
+		problems := preflight()
+		for _, name := range sortedKeys(problems) {
+			if known[name] == problems[name] {
+				continue
+			}
+			fmt.Printf("Warning: provider %s: %s\n", name, problems[name])
+			notify(tr("Dictation provider problem"), fmt.Sprintf("%s: %s", name, problems[name]))
+		}
+		known = problems
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(preflightInterval):
+		}
+	}
+}
+
+// runPreflightCommand checks the providers now. With dictation running the
+// check is done by it, so its status shows the result.
+func runPreflightCommand(args []string) error {
+	problems := map[string]string{}
+	if client, err := controlClient(); err == nil {
+		resp, err := client.Post("http://dictation/preflight", "", nil)
+		if err != nil {
+			return fmt.Errorf("connecting to dictation: %w", err)
+		}
+		defer resp.Body.Close()
+		var s controlStatus
+		if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+			return fmt.Errorf("decoding status: %w", err)
+		}
+		problems = s.ProviderProblems
+	} else {
+		problems = preflight()
+	}
+
+	for _, setup := range usedProviderSetups() {
+		if setup.provider.local() {
+			continue
+		}
+		if problem, ok := problems[setup.name]; ok {
+			fmt.Printf("✗ provider %s: %s\n", setup.name, problem)
+		} else {
+			fmt.Printf("✓ provider %s\n", setup.name)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d providers have problems, run dictation doctor for what to do", len(problems))
+	}
+	return nil
+}
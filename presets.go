@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// maxPresetPromptChars bounds a preset's prompt and words together. Whisper
+// only looks at the last 224 tokens of a prompt, and the profile's prompt
+// and the vocabulary come after the preset's.
+const maxPresetPromptChars = 600
+
+// promptPreset biases transcription toward an accent, dialect or domain:
+// a prompt written the way that speech is transcribed, words Whisper
+// should spell right, and replacements for what it still gets wrong. Built
+// in ones are below, more are TOML files in the presets directory.
+type promptPreset struct {
+	Name        string `toml:"name"`
+	Description string `toml:"description,omitempty"`
+	// Language is used for profiles that don't set one.
+	Language     string            `toml:"language,omitempty"`
+	Prompt       string            `toml:"prompt,omitempty"`
+	Words        []string          `toml:"words,omitempty"`
+	Replacements map[string]string `toml:"replacements,omitempty"`
+	// File is where the preset was read from, "" for built-in ones.
+	File string `toml:"-"`
+}
+
+var builtinPresets = []promptPreset{
+	{
+		Name:        "indian-english",
+		Description: "English as spoken in India: lakh and crore, Indian English idioms, places and names",
+		Language:    "en",
+		Prompt:      "Hi team, we'll prepone the review to Thursday, so please do the needful and revert by EOD. The project cost is 4.5 lakh rupees, about 2 crore for the year. Kindly share the PAN and GST details.",
+		Words: []string{
+			"lakh", "crore", "prepone", "Bengaluru", "Gurugram", "Hyderabad", "Chennai", "Pune",
+			"Aadhaar", "UPI", "Paytm", "IRCTC", "Diwali", "Navratri", "ji",
+		},
+		Replacements: map[string]string{
+			"pre pone": "prepone",
+			"aadhar":   "Aadhaar",
+		},
+	},
+	{
+		Name:        "british-english",
+		Description: "British spelling and vocabulary: colour, organise, programme, centre",
+		Language:    "en",
+		Prompt:      "I've organised the programme for the centre and realised the colour scheme needs work. Could we analyse the catalogue before the meeting on Whit Monday? Cheers, and speak soon.",
+		Replacements: map[string]string{
+			"color":     "colour",
+			"colors":    "colours",
+			"favorite":  "favourite",
+			"organize":  "organise",
+			"organized": "organised",
+			"realize":   "realise",
+			"realized":  "realised",
+			"analyze":   "analyse",
+			"analyzed":  "analysed",
+			"center":    "centre",
+			"catalog":   "catalogue",
+		},
+	},
+	{
+		Name:        "medical",
+		Description: "Clinical notes: drug names, doses, anatomy and common abbreviations",
+		Language:    "en",
+		Prompt:      "Patient presents with dyspnea and tachycardia. History of hypertension, type 2 diabetes mellitus and hyperlipidemia. Medications: metformin 500 mg b.i.d., lisinopril 10 mg daily. Plan: CBC, BMP, troponin, ECG; follow up in 2 weeks.",
+		Words: []string{
+			"acetaminophen", "amoxicillin", "apixaban", "atorvastatin", "levothyroxine", "metoprolol",
+			"omeprazole", "prednisone", "sertraline", "warfarin", "COPD", "CHF", "HbA1c", "edema",
+			"erythema", "pneumothorax",
+		},
+	},
+	{
+		Name:        "legal",
+		Description: "Contracts and litigation: Latin terms, defined terms and section references",
+		Language:    "en",
+		Prompt:      "Pursuant to Section 4(b) of the Agreement, the Licensee shall indemnify and hold harmless the Licensor. Notwithstanding the foregoing, the plaintiff moved for summary judgment and the court granted certiorari.",
+		Words: []string{
+			"affidavit", "amicus curiae", "bona fide", "certiorari", "de novo", "estoppel",
+			"force majeure", "habeas corpus", "inter alia", "mens rea", "prima facie", "res judicata",
+			"subpoena", "voir dire",
+		},
+		Replacements: map[string]string{
+			"sub poena":    "subpoena",
+			"force majeur": "force majeure",
+		},
+	},
+}
+
+// presetsDir holds the presets that were imported or written by hand, one
+// TOML file each, next to the config file.
+func presetsDir() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "presets"), nil
+}
+
+// loadPresets returns the built-in presets and those in the presets
+// directory, which replace built-in ones of the same name, sorted by name.
+// Files that can't be read are skipped with a warning, so one broken file
+// doesn't take all presets down.
+func loadPresets() ([]promptPreset, error) {
+	dir, err := presetsDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	var read []promptPreset
+	for _, file := range files {
+		p, err := readPreset(file)
+		if err != nil {
+			fmt.Printf("Warning: skipping %v\n", err)
+			continue
+		}
+		read = append(read, p)
+	}
+	return withPresets(builtinPresets, read), nil
+}
+
+// withPresets returns presets with more added, which replace those of the
+// same name, sorted by name.
+func withPresets(presets, more []promptPreset) []promptPreset {
+	byName := map[string]promptPreset{}
+	for _, p := range slices.Concat(presets, more) {
+		byName[p.Name] = p
+	}
+	all := make([]promptPreset, 0, len(byName))
+	for _, p := range byName {
+		all = append(all, p)
+	}
+	slices.SortFunc(all, func(a, b promptPreset) int { return strings.Compare(a.Name, b.Name) })
+	return all
+}
+
+func findPreset(presets []promptPreset, name string) (promptPreset, error) {
+	for _, p := range presets {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return promptPreset{}, fmt.Errorf("no preset %q, see dictation presets", name)
+}
+
+func readPreset(file string) (promptPreset, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return promptPreset{}, fmt.Errorf("reading preset: %w", err)
+	}
+	p, err := decodePreset(string(data))
+	if err != nil {
+		return promptPreset{}, fmt.Errorf("preset %s: %w", file, err)
+	}
+	p.File = file
+	return p, nil
+}
+
+// decodePreset reads a preset file:
+//
+//	name = "indian-english"
+//	description = "English as spoken in India"
+//	language = "en"
+//	prompt = "We'll prepone the review, please do the needful."
+//	words = ["lakh", "crore"]
+//
+//	[replacements]
+//	"pre pone" = "prepone"
+func decodePreset(data string) (promptPreset, error) {
+	var p promptPreset
+	md, err := toml.Decode(data, &p)
+	if err != nil {
+		return promptPreset{}, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return promptPreset{}, fmt.Errorf("unknown key %q", undecoded[0].String())
+	}
+	if err := p.validate(); err != nil {
+		return promptPreset{}, err
+	}
+	return p, nil
+}
+
+func (p promptPreset) validate() error {
+	if p.Name == "" || strings.ContainsAny(p.Name, `/\ `) {
+		return fmt.Errorf("name must be set and have no spaces or slashes, got %q", p.Name)
+	}
+	if p.Prompt == "" && len(p.Words) == 0 && len(p.Replacements) == 0 {
+		return errors.New("a preset needs a prompt, words or replacements")
+	}
+	if n := len(p.prompt("")); n > maxPresetPromptChars {
+		return fmt.Errorf("prompt and words are %d characters, at most %d fit", n, maxPresetPromptChars)
+	}
+	return nil
+}
+
+// encode writes the preset as a TOML file for decodePreset.
+func (p promptPreset) encode() string {
+	var b strings.Builder
+	enc := toml.NewEncoder(&b)
+	enc.Indent = ""
+	if err := enc.Encode(p); err != nil {
+		// Strings, a list and a table of strings always encode.
+		panic(err)
+	}
+	return b.String()
+}
+
+// prompt puts the preset's prompt and words before a profile's prompt.
+func (p promptPreset) prompt(profilePrompt string) string {
+	parts := []string{}
+	if p.Prompt != "" {
+		parts = append(parts, p.Prompt)
+	}
+	if len(p.Words) > 0 {
+		parts = append(parts, strings.Join(p.Words, ", ")+".")
+	}
+	if profilePrompt != "" {
+		parts = append(parts, profilePrompt)
+	}
+	return strings.Join(parts, " ")
+}
+
+// applyPreset adds the profile's preset to its transcription parameters.
+// The presets are those read with the config.
+func applyPreset(profile Profile) Profile {
+	if profile.Preset == "" {
+		return profile
+	}
+	p, err := findPreset(config.presets, profile.Preset)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return profile
+	}
+	if profile.Params.Language == "" {
+		profile.Params.Language = p.Language
+	}
+	profile.Params.Prompt = p.prompt(profile.Params.Prompt)
+	return profile
+}
+
+// presetReplacements returns the replacements of a profile's preset.
+func presetReplacements(profile Profile) map[string]string {
+	if profile.Preset == "" {
+		return nil
+	}
+	p, err := findPreset(config.presets, profile.Preset)
+	if err != nil {
+		return nil
+	}
+	return p.Replacements
+}
+
+const presetsUsage = "usage: dictation presets [list | show <name> | export <name> [file] | import <file>]"
+
+// runPresetsCommand lists the prompt presets, and exports and imports them
+// to share them.
+func runPresetsCommand(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		presets, err := loadPresets()
+		if err != nil {
+			return err
+		}
+		for _, p := range presets {
+			source := "built-in"
+			if p.File != "" {
+				source = p.File
+			}
+			fmt.Printf("%-18s %s (%s)\n", p.Name, p.Description, source)
+		}
+		return nil
+	case "show", "export":
+		if len(args) < 2 || len(args) > 3 || args[0] == "show" && len(args) != 2 {
+			return errors.New(presetsUsage)
+		}
+		presets, err := loadPresets()
+		if err != nil {
+			return err
+		}
+		p, err := findPreset(presets, args[1])
+		if err != nil {
+			return err
+		}
+		if len(args) == 2 {
+			fmt.Print(p.encode())
+			return nil
+		}
+		if err := os.WriteFile(args[2], []byte(p.encode()), 0o644); err != nil {
+			return fmt.Errorf("writing preset: %w", err)
+		}
+		fmt.Printf("Exported %s to %s\n", p.Name, args[2])
+		return nil
+	case "import":
+		if len(args) != 2 {
+			return errors.New(presetsUsage)
+		}
+		p, err := readPreset(args[1])
+		if err != nil {
+			return err
+		}
+		dir, err := presetsDir()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating presets directory: %w", err)
+		}
+		dest := filepath.Join(dir, p.Name+".toml")
+		_, statErr := os.Stat(dest)
+		if err := os.WriteFile(dest, []byte(p.encode()), 0o644); err != nil {
+			return fmt.Errorf("writing preset: %w", err)
+		}
+		if errors.Is(statErr, fs.ErrNotExist) {
+			fmt.Printf("Imported %s to %s\n", p.Name, dest)
+		} else {
+			fmt.Printf("Replaced %s in %s\n", p.Name, dest)
+		}
+		fmt.Printf("Use it with \"preset\": %q in a profile\n", p.Name)
+		return nil
+	default:
+		return errors.New(presetsUsage)
+	}
+}
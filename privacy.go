@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PrivacyConfig says what of a dictation may outlive it. Everything is
+// allowed by default, and the feature settings decide; what is turned off
+// here is off whatever they say, see applyPrivacy.
+type PrivacyConfig struct {
+	// History allows keeping dictated text: the history, the transcript
+	// cache, corrections learned from edits, and the clipboard entry
+	// copy_last leaves for clipboard managers.
+	History bool `json:"history"`
+	// Audio allows keeping recordings, also in pending until their text is
+	// delivered, for another try after a crash or a failed upload.
+	Audio bool `json:"audio"`
+	// AppContext allows recording which app and window a dictation went
+	// to, in the history and the event log.
+	AppContext bool `json:"app_context"`
+	// Logs allows dictated text in the terminal, the log file and
+	// notifications.
+	Logs bool `json:"logs"`
+	// Analytics allows the event log and exporting traces.
+	Analytics bool `json:"analytics"`
+}
+
+// applyPrivacy turns off the features the privacy settings don't allow, in
+// one place, so the rest of the program only has to honor its own
+// settings.
+func (c *Config) applyPrivacy() {
+	p := c.Privacy
+	if !p.History {
+		c.History.Enabled = false
+		c.Cache.Enabled = false
+		c.Vocabulary.WatchEdits = false
+		if c.Output.CopyLast == copyLastHistory {
+			c.Output.CopyLast = copyLastOff
+		}
+	}
+	if !p.Audio {
+		c.History.KeepAudio = false
+	}
+	if !p.AppContext {
+		c.History.AppContext = false
+	}
+	if !p.Analytics {
+		c.EventLog = false
+		c.Tracing.Endpoint = ""
+	}
+}
+
+// logged returns dictated text for the terminal, the log and
+// notifications, or only its length if privacy.logs is off.
+func logged(text string) string {
+	if config.Privacy.Logs {
+		return text
+	}
+	return fmt.Sprintf("(%d characters)", len([]rune(text)))
+}
+
+// runPrivacyCommand lists what the current config keeps of dictations on
+// this machine and what it sends where, to verify a private setup.
+func runPrivacyCommand(args []string) error {
+	keeps := func(what string, on bool, detail string) {
+		mark := "✗ not kept"
+		if on {
+			mark = "✓ kept"
+		}
+		fmt.Printf("%-12s %s, %s\n", what, mark, detail)
+	}
+	dir, _ := dataDir()
+	keeps("history", config.History.Enabled, "dictated text in "+dir)
+	keeps("audio", config.History.Enabled && config.History.KeepAudio, "recordings in "+filepath.Join(dir, "audio"))
+	keeps("app context", config.History.Enabled && config.History.AppContext, "app and window of dictations")
+	keeps("cache", config.Cache.Enabled, "transcripts by the hash of their audio")
+	drafts := "draft mode's drafts in " + filepath.Join(dir, "drafts")
+	if n := countDrafts(); n > 0 {
+		drafts += fmt.Sprintf(", %d there now", n)
+	}
+	if !config.Privacy.History {
+		drafts += ", removed once the editor is closed or at the next start"
+	}
+	keeps("drafts", config.Privacy.History, drafts)
+	keeps("edits", config.Vocabulary.WatchEdits, "corrections learned from your edits of dictations")
+	keeps("clipboard", config.Output.CopyLast != copyLastOff, "the last dictation on the clipboard, copy_last "+config.Output.CopyLast)
+	keeps("logs", config.Privacy.Logs, "dictated text in the terminal, the log file and notifications")
+	keeps("event log", config.EventLog, "one line per dictation without its text")
+
+	fmt.Println()
+	fmt.Println("Sent over the network:")
+	for _, name := range usedProviders() {
+		provider := config.Providers[name]
+		if provider.local() {
+			fmt.Printf("  audio to the local provider %s (%s)\n", name, provider.Type)
+		} else {
+			fmt.Printf("  audio to provider %s at %s\n", name, provider.URL)
+		}
+	}
+	seen := map[string]bool{}
+	var llms []string
+	for _, p := range config.Profiles {
+		if !p.Cleanup && !config.Assistant.Enabled {
+			continue
+		}
+		llm := config.llm(p)
+		where := llm.URL
+		if llm.Type == llmOllama {
+			where = ollamaURL(llm) + " (Ollama)"
+		}
+		if !seen[where] {
+			seen[where] = true
+			llms = append(llms, where)
+		}
+	}
+	slices.Sort(llms)
+	for _, where := range llms {
+		fmt.Printf("  text to the LLM at %s\n", where)
+	}
+	if config.Tracing.Endpoint != "" {
+		fmt.Printf("  traces, without text, to %s\n", config.Tracing.Endpoint)
+	}
+	if config.UpdateCheck {
+		fmt.Println("  an update check to GitHub at startup")
+	}
+	if config.Preflight {
+		fmt.Println("  model list requests to check the API keys, see preflight")
+	}
+	if config.StreamDeck.Listen != "" && !strings.HasPrefix(config.StreamDeck.Listen, "127.0.0.1:") && !strings.HasPrefix(config.StreamDeck.Listen, "localhost:") {
+		fmt.Printf("  the Stream Deck API listens on %s, reachable from other machines\n", config.StreamDeck.Listen)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	profanityKeep   = "keep"
+	profanityMask   = "mask"
+	profanityRemove = "remove"
+)
+
+// profanityWords is the built-in list, extended by ProfanityConfig.Words.
+// Common suffixes ("-s", "-ing", "-ed"...) are matched too.
+var profanityWords = []string{
+	"arse", "arsehole", "asshole", "bastard", "bitch", "bollocks", "bullshit",
+	"crap", "cunt", "damn", "dick", "dickhead", "fuck", "goddamn",
+	"motherfucker", "piss", "prick", "shit", "slut", "twat", "wanker", "whore",
+}
+
+var (
+	profanityOnce    sync.Once
+	profanityPattern *regexp.Regexp
+)
+
+func compileProfanity(extra []string) *regexp.Regexp {
+	words := append(append([]string{}, profanityWords...), extra...)
+	for i, w := range words {
+		words[i] = regexp.QuoteMeta(strings.ToLower(w))
+	}
+	return regexp.MustCompile(`(?i)\b(` + strings.Join(words, "|") + `)(s|es|ed|er|ers|ing|y)?\b`)
+}
+
+// filterProfanity masks (f***) or removes swear words, depending on the
+// configured mode.
+func filterProfanity(text string, cfg ProfanityConfig) string {
+	if cfg.Mode == "" || cfg.Mode == profanityKeep {
+		return text
+	}
+	profanityOnce.Do(func() { profanityPattern = compileProfanity(cfg.Words) })
+
+	if cfg.Mode == profanityMask {
+		return profanityPattern.ReplaceAllStringFunc(text, func(word string) string {
+			first := []rune(word)[:1]
+			return string(first) + strings.Repeat("*", len([]rune(word))-1)
+		})
+	}
+
+	text = profanityPattern.ReplaceAllString(text, "")
+	return tidySpaces(text)
+}
+
+var (
+	repeatedSpaces     = regexp.MustCompile(` {2,}`)
+	spaceBeforePunct   = regexp.MustCompile(` +([,.!?;:])`)
+	leadingPunctuation = regexp.MustCompile(`^[\s,;:]+`)
+)
+
+// tidySpaces cleans up after words were removed from a sentence: double
+// spaces, spaces before punctuation and a dangling comma at the start.
+func tidySpaces(text string) string {
+	text = repeatedSpaces.ReplaceAllString(text, " ")
+	text = spaceBeforePunct.ReplaceAllString(text, "$1")
+	text = leadingPunctuation.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// QuickConfig is quick mode: holding the Globe key instead of pressing it
+// records one thought, which ends at the first pause and is submitted, for
+// a chat message or a search without the second press.
+type QuickConfig struct {
+	Enabled bool `json:"enabled"`
+	// Hold is how long Globe must be held, "1s" by default.
+	Hold string `json:"hold"`
+	// Profile is the profile quick dictations use, the default one unless
+	// set.
+	Profile string `json:"profile"`
+	// Silence is the pause after speech that ends the recording, "1s" by
+	// default, and Max how long it can run at most, "30s".
+	Silence string `json:"silence"`
+	Max     string `json:"max"`
+	// Submit presses Return after inserting, as auto-send does, unless a
+	// key command was said. On by default.
+	Submit bool `json:"submit"`
+}
+
+func (q QuickConfig) hold() time.Duration {
+	d, _ := time.ParseDuration(q.Hold) // checked by validate
+	return d
+}
+
+func (q QuickConfig) silence() time.Duration {
+	d, _ := time.ParseDuration(q.Silence) // checked by validate
+	return d
+}
+
+func (q QuickConfig) max() time.Duration {
+	d, _ := time.ParseDuration(q.Max) // checked by validate
+	return d
+}
+
+// pauseDetector ends a recording once the speaker was quiet for silence
+// after having said something, or after max in any case; zero durations
+// don't end it.
+type pauseDetector struct {
+	silence, max time.Duration
+	spoke        bool
+	quiet        time.Duration
+}
+
+// over takes the next buffer and reports whether the recording should end.
+func (d *pauseDetector) over(p captureProgress) bool {
+	if d.max > 0 && p.Elapsed >= d.max {
+		return true
+	}
+	if d.silence <= 0 {
+		return false
+	}
+	if p.Level < captureSettings.SilenceLevel {
+		d.quiet += time.Duration(len(p.Buffer)) * time.Second / sampleRate
+	} else {
+		d.spoke, d.quiet = true, 0
+	}
+	return d.spoke && d.quiet >= d.silence
+}
+
+// quickGesture tells a held Globe key from a pressed one. The press itself
+// still goes to the Globe gesture, which doesn't mind: a single press does
+// nothing while not dictating.
+type quickGesture struct {
+	hold  time.Duration
+	down  bool
+	timer *time.Timer
+	fired chan struct{}
+}
+
+// newQuickGesture returns nil when quick mode is off, or Globe isn't bound
+// to its gesture.
+func newQuickGesture(e *chordEngine) *quickGesture {
+	if !config.Trigger.Quick.Enabled || !slices.ContainsFunc(e.hotkeys, func(h hotkey) bool { return h.globeGesture() }) {
+		return nil
+	}
+	return &quickGesture{hold: config.Trigger.Quick.hold(), fired: make(chan struct{}, 1)}
+}
+
+// key follows the Globe key: a press while not dictating starts the hold
+// timer, the release stops it.
+func (q *quickGesture) key(ev keyEvent) {
+	if q == nil || ev.code != globeKeyCode {
+		return
+	}
+	if !ev.down {
+		q.down = false
+		if q.timer != nil {
+			q.timer.Stop()
+		}
+		// A hold that fired just before the release doesn't count.
+		select {
+		case <-q.fired:
+		default:
+		}
+		return
+	}
+	if q.down || dictating.Load() {
+		return // auto-repeat, or Globe stopping a dictation
+	}
+	q.down = true
+	q.timer = time.AfterFunc(q.hold, func() {
+		select {
+		case q.fired <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// holds receives when Globe was held long enough; never when quick mode is
+// off.
+func (q *quickGesture) holds() <-chan struct{} {
+	if q == nil {
+		return nil
+	}
+	return q.fired
+}
+
+// startQuickDictation starts a quick mode dictation, once Globe was held.
+func startQuickDictation(ctx context.Context) {
+	if !dictating.Load() {
+		beginDictation(ctx, "Globe held", config.Trigger.Quick.Profile, "", true)
+	}
+}
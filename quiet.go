@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons the triggers are switched off for a while, as shown in the status.
+const (
+	disabledPaused     = "paused"      // dictation pause
+	disabledQuietHours = "quiet_hours" // trigger.quiet_hours
+)
+
+// defaultPause is how long dictation pause switches the triggers off.
+const defaultPause = time.Hour
+
+// weekdays are the day names of trigger.quiet_hours, by time.Weekday.
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// QuietHours is a time of day in which the triggers are ignored, such as a
+// daily standup or the hours a podcast is recorded.
+type QuietHours struct {
+	// Days are the weekdays it applies to, "mon" to "sun"; every day when
+	// empty.
+	Days []string `json:"days"`
+	// From and To are local times of day, "15:04". A To before From goes
+	// past midnight, into the next day.
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// parseClock parses a time of day, "15:04", as hours and minutes.
+func parseClock(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("want a time of day such as \"09:30\", got %q", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+func (q QuietHours) validate() error {
+	for _, d := range q.Days {
+		if !slices.Contains(weekdays, strings.ToLower(d)) {
+			return fmt.Errorf("unknown day %q, want mon, tue, wed, thu, fri, sat or sun", d)
+		}
+	}
+	if _, _, err := parseClock(q.From); err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	if _, _, err := parseClock(q.To); err != nil {
+		return fmt.Errorf("to: %w", err)
+	}
+	if q.From == q.To {
+		return fmt.Errorf("from and to are both %s", q.From)
+	}
+	return nil
+}
+
+// end returns when the quiet hours around now end, if now is in them. They
+// may have started the day before, when they go past midnight.
+func (q QuietHours) end(now time.Time) (time.Time, bool) {
+	fromHour, fromMinute, _ := parseClock(q.From) // checked by validate
+	toHour, toMinute, _ := parseClock(q.To)
+	for _, day := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		if len(q.Days) > 0 && !slices.ContainsFunc(q.Days, func(d string) bool {
+			return strings.EqualFold(d, weekdays[day.Weekday()])
+		}) {
+			continue
+		}
+		y, m, d := day.Date()
+		start := time.Date(y, m, d, fromHour, fromMinute, 0, 0, now.Location())
+		end := time.Date(y, m, d, toHour, toMinute, 0, 0, now.Location())
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !now.Before(start) && now.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// pausedUntil is when dictation pause ends, zero when not paused.
+var pausedUntil struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// pauseTriggers switches the triggers off for d, or ends a pause for 0.
+func pauseTriggers(d time.Duration) {
+	pausedUntil.mu.Lock()
+	pausedUntil.until = time.Time{}
+	if d > 0 {
+		pausedUntil.until = time.Now().Add(d)
+	}
+	pausedUntil.mu.Unlock()
+	updateDisabled()
+}
+
+// triggersDisabled returns why the triggers are off at now, "" if they
+// aren't, and until when.
+func triggersDisabled(now time.Time) (string, time.Time) {
+	pausedUntil.mu.Lock()
+	paused := pausedUntil.until
+	pausedUntil.mu.Unlock()
+	if now.Before(paused) {
+		return disabledPaused, paused
+	}
+
+	reason, until := "", time.Time{}
+	for _, q := range config.Trigger.QuietHours {
+		if end, ok := q.end(now); ok && end.After(until) {
+			reason, until = disabledQuietHours, end
+		}
+	}
+	return reason, until
+}
+
+// disabledMessage describes why the triggers are off, for the log and the
+// status surfaces: "paused until 14:30".
+func disabledMessage(reason string, until time.Time) string {
+	what := "paused"
+	if reason == disabledQuietHours {
+		what = "in quiet hours"
+	}
+	return what + " until " + until.Format("15:04")
+}
+
+// updateDisabled brings the disabled state in the status up to date.
+func updateDisabled() {
+	reason, until := triggersDisabled(time.Now())
+	s := status.snapshot()
+	current := time.Time{}
+	if s.DisabledUntil != nil {
+		current = *s.DisabledUntil
+	}
+	if reason != s.Disabled || !until.Equal(current) {
+		status.setDisabled(reason, until)
+	}
+}
+
+// watchQuietHours keeps the disabled state in the status up to date as quiet
+// hours and pauses begin and end, until ctx is done.
+func watchQuietHours(ctx context.Context) {
+	ticker := time.NewTicker(blockedAppPoll)
+	defer ticker.Stop()
+	for {
+		updateDisabled()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runPauseCommand switches the triggers of the running instance off for a
+// while, an hour unless given: dictation pause 30m.
+func runPauseCommand(args []string) error {
+	flags := flag.NewFlagSet("pause", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dictation pause [duration]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	d := defaultPause
+	if flags.NArg() > 0 {
+		var err error
+		if d, err = time.ParseDuration(flags.Arg(0)); err != nil || d <= 0 {
+			return fmt.Errorf("want a duration such as \"30m\", got %q", flags.Arg(0))
+		}
+	}
+	s, err := postControl("/pause?for=" + url.QueryEscape(d.String()))
+	if err != nil {
+		return err
+	}
+	if s.DisabledUntil != nil {
+		fmt.Printf("Dictation is %s\n", disabledMessage(s.Disabled, *s.DisabledUntil))
+	}
+	return nil
+}
+
+// runResumeCommand ends a pause of the running instance.
+func runResumeCommand(args []string) error {
+	flags := flag.NewFlagSet("resume", flag.ExitOnError)
+	flags.Parse(args)
+
+	s, err := postControl("/resume")
+	if err != nil {
+		return err
+	}
+	if s.DisabledUntil != nil {
+		// Quiet hours aren't ended by resume.
+		fmt.Printf("Dictation is still %s\n", disabledMessage(s.Disabled, *s.DisabledUntil))
+		return nil
+	}
+	fmt.Println("Dictation resumed")
+	return nil
+}
+
+// postControl makes a POST request to the running instance and returns the
+// status it responds with.
+func postControl(path string) (controlStatus, error) {
+	var s controlStatus
+	client, err := controlClient()
+	if err != nil {
+		return s, err
+	}
+	resp, err := client.Post("http://dictation"+path, "", nil)
+	if err != nil {
+		return s, fmt.Errorf("connecting to dictation: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return s, fmt.Errorf("dictation: %s", strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return s, fmt.Errorf("decoding status: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// The Raycast API is the part of the control socket a Raycast extension
+// (or any other launcher) uses. Unlike the rest of the control API, which
+// changes with the dashboard, it is a contract: the payloads are declared
+// here field by field instead of embedding statusSnapshot, fields are only
+// ever added, and a change that breaks clients goes to /raycast/v2 with v1
+// kept alongside.
+
+// raycastAPIVersion is the version in the paths, and in every status.
+const raycastAPIVersion = 1
+
+const (
+	defaultRaycastLimit = 20
+	maxRaycastLimit     = 100
+)
+
+type raycastStatus struct {
+	APIVersion int    `json:"api_version"`
+	Version    string `json:"version"`
+	// State is "idle", "recording", "transcribing" or "rate_limited".
+	State          string     `json:"state"`
+	RecordingSince *time.Time `json:"recording_since,omitempty"`
+	// Profile is the profile toggles start dictation with, Profiles all of
+	// them, sorted.
+	Profile  string   `json:"profile"`
+	Profiles []string `json:"profiles"`
+	// Disabled is "paused" or "quiet_hours" while the triggers are off,
+	// until DisabledUntil; BlockedApp the app they are blocked in.
+	Disabled      string     `json:"disabled,omitempty"`
+	DisabledUntil *time.Time `json:"disabled_until,omitempty"`
+	BlockedApp    string     `json:"blocked_app,omitempty"`
+	LastText      string     `json:"last_text"`
+	LastError     string     `json:"last_error,omitempty"`
+	AudioProblem  string     `json:"audio_problem,omitempty"`
+}
+
+type raycastTranscription struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Profile string    `json:"profile"`
+	Text    string    `json:"text"`
+	// App is where it was inserted, with history.app_context on.
+	App string `json:"app,omitempty"`
+}
+
+type raycastTranscriptions struct {
+	// HistoryEnabled is false when history is off; Transcriptions is
+	// empty then.
+	HistoryEnabled bool                   `json:"history_enabled"`
+	Transcriptions []raycastTranscription `json:"transcriptions"`
+}
+
+type raycastError struct {
+	Error string `json:"error"`
+}
+
+func currentRaycastStatus() raycastStatus {
+	s := status.snapshot()
+	profiles := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		profiles = append(profiles, name)
+	}
+	slices.Sort(profiles)
+	return raycastStatus{
+		APIVersion:     raycastAPIVersion,
+		Version:        version,
+		State:          string(s.State),
+		RecordingSince: s.RecordingSince,
+		Profile:        triggerProfile(),
+		Profiles:       profiles,
+		Disabled:       s.Disabled,
+		DisabledUntil:  s.DisabledUntil,
+		BlockedApp:     s.BlockedApp,
+		LastText:       s.LastText,
+		LastError:      s.LastError,
+		AudioProblem:   s.AudioProblem,
+	}
+}
+
+// registerRaycastAPI adds the Raycast API to the control socket's mux. See
+// the README for the contract.
+func registerRaycastAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /raycast/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, currentRaycastStatus())
+	})
+	mux.HandleFunc("POST /raycast/v1/toggle", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case toggleRequests <- "Raycast toggle":
+		default:
+		}
+		writeJSON(w, http.StatusAccepted, currentRaycastStatus())
+	})
+	mux.HandleFunc("POST /raycast/v1/profile", handleRaycastProfile)
+	mux.HandleFunc("GET /raycast/v1/transcriptions", handleRaycastTranscriptions)
+	mux.HandleFunc("POST /raycast/v1/transcriptions/{id}/copy", handleRaycastCopy)
+}
+
+func writeRaycastError(w http.ResponseWriter, code int, format string, args ...any) {
+	writeJSON(w, code, raycastError{Error: fmt.Sprintf(format, args...)})
+}
+
+func handleRaycastProfile(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Profile string `json:"profile"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Profile == "" {
+		writeRaycastError(w, http.StatusBadRequest, `the body must be {"profile": "name"}`)
+		return
+	}
+	if err := selectProfile(body.Profile); err != nil {
+		writeRaycastError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+	fmt.Printf("Profile %s selected for toggles\n", body.Profile)
+	writeJSON(w, http.StatusOK, currentRaycastStatus())
+}
+
+// handleRaycastTranscriptions lists the last transcriptions from the
+// history, newest first, ?limit= of them.
+func handleRaycastTranscriptions(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRaycastLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeRaycastError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = min(n, maxRaycastLimit)
+	}
+
+	result := raycastTranscriptions{HistoryEnabled: config.History.Enabled, Transcriptions: []raycastTranscription{}}
+	if !config.History.Enabled {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	entries, err := readHistory()
+	if err != nil {
+		writeRaycastError(w, http.StatusInternalServerError, "reading history: %v", err)
+		return
+	}
+	for i := len(entries) - 1; i >= 0 && len(result.Transcriptions) < limit; i-- {
+		e := entries[i]
+		result.Transcriptions = append(result.Transcriptions, raycastTranscription{
+			ID: e.ID, Time: e.Time, Profile: e.Profile, Text: e.Text, App: e.App,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleRaycastCopy puts a transcription from the history on the clipboard.
+func handleRaycastCopy(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	entries, err := readHistory()
+	if err != nil {
+		writeRaycastError(w, http.StatusInternalServerError, "reading history: %v", err)
+		return
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].ID != id {
+			continue
+		}
+		if err := injector.WriteClipboard(entries[i].Text); err != nil {
+			writeRaycastError(w, http.StatusInternalServerError, "copying: %v", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeRaycastError(w, http.StatusNotFound, "no transcription %q in the history", id)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errRecorderStopped is what Read returns once Stop was called and every
+// buffer captured before was read.
+var errRecorderStopped = errors.New("recorder stopped")
+
+// Recorder captures mono float32 samples at sampleRate from the default
+// input device.
+type Recorder interface {
+	Start() error
+	// Read blocks until the next buffer is captured, or fails once the
+	// recorder is stopped. The buffers can have any length, and the slice
+	// is only valid until the next call.
+	Read() ([]float32, error)
+	Stop() error
+	// Close releases the device; the recorder can't be used afterwards.
+	Close() error
+	// Device describes the input device, for diagnostics.
+	Device() string
+	// DeviceName is the name of the input device, "" if unknown, which
+	// picks its audio.devices settings.
+	DeviceName() string
+	// Overruns is how many times audio was lost since Start, because the
+	// device delivered it faster than it was read.
+	Overruns() int
+}
+
+const (
+	audioBackendAuto      = "auto"
+	audioBackendCoreAudio = "coreaudio"
+	audioBackendPortAudio = "portaudio"
+)
+
+const (
+	// audioLatencyLow and audioLatencyHigh pick the device's default low
+	// or high input latency for portaudio streams.
+	audioLatencyLow  = "low"
+	audioLatencyHigh = "high"
+
+	defaultFrameSize = 1024
+
+	// defaultSilenceLevel is the level (RMS) below which audio counts as a
+	// pause unless audio.silence_level says otherwise.
+	defaultSilenceLevel = 0.01
+	// maxGain bounds audio.gain; more only amplifies noise.
+	maxGain = 16
+)
+
+// captureSettings are the audio settings of the device being recorded from,
+// set when a recording starts.
+var captureSettings DeviceSettings
+
+// newRecorder opens the configured capture backend. In auto mode that is
+// CoreAudio where there is one, with portaudio as the fallback.
+func newRecorder() (Recorder, error) {
+	switch config.Audio.Backend {
+	case audioBackendCoreAudio:
+		return newCoreAudioRecorder()
+	case audioBackendPortAudio:
+		return newPortAudioRecorder()
+	}
+
+	r, err := newCoreAudioRecorder()
+	if err == nil {
+		return r, nil
+	}
+	if coreAudioAvailable {
+		fmt.Printf("Warning: CoreAudio capture failed, using portaudio: %v\n", err)
+	}
+	return newPortAudioRecorder()
+}
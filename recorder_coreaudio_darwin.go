@@ -0,0 +1,288 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework AVFoundation -framework AudioToolbox -framework Foundation
+#import <AVFoundation/AVFoundation.h>
+#import <AudioToolbox/AudioToolbox.h>
+#include <stdlib.h>
+#include <string.h>
+
+extern void goCoreAudioSamples(float *samples, int frames, double rate);
+extern void goCoreAudioRestartFailed(char *message);
+
+static AVAudioEngine *captureEngine;
+static id captureObserver;
+
+// installCaptureTap taps the input in the device's own format; Go resamples.
+// Multi-channel input is downmixed here, or just one channel (1-based) is
+// taken if channel is set.
+static void installCaptureTap(int bufferSize, int channel) {
+	AVAudioInputNode *input = captureEngine.inputNode;
+	AVAudioFormat *format = [input outputFormatForBus:0];
+	double rate = format.sampleRate;
+	[input installTapOnBus:0 bufferSize:(AVAudioFrameCount)bufferSize format:format block:^(AVAudioPCMBuffer *buffer, AVAudioTime *when) {
+		float * const *data = buffer.floatChannelData;
+		if (data == NULL) {
+			return;
+		}
+		int count = (int)buffer.format.channelCount;
+		int frames = (int)buffer.frameLength;
+		int stride = (int)buffer.stride;
+		BOOL interleaved = buffer.format.isInterleaved;
+		if (count == 1) {
+			goCoreAudioSamples(data[0], frames, rate);
+			return;
+		}
+
+		int pick = (channel > 0 && channel <= count) ? channel : 0;
+		float *mono = malloc(frames * sizeof(float));
+		if (mono == NULL) {
+			return;
+		}
+		for (int i = 0; i < frames; i++) {
+			float sum = 0;
+			for (int c = 0; c < count; c++) {
+				if (pick > 0 && c != pick - 1) {
+					continue;
+				}
+				sum += interleaved ? data[0][i * stride + c] : data[c][i * stride];
+			}
+			mono[i] = pick > 0 ? sum : sum / count;
+		}
+		goCoreAudioSamples(mono, frames, rate);
+		free(mono);
+	}];
+}
+
+// startCapture returns NULL, or an error message the caller frees. device
+// is the input device to record from, 0 for the system's default input.
+static char *startCapture(int bufferSize, int channel, unsigned int device) {
+	@autoreleasepool {
+		captureEngine = [[AVAudioEngine alloc] init];
+		if (device != 0) {
+			AudioDeviceID id = device;
+			OSStatus status = AudioUnitSetProperty(captureEngine.inputNode.audioUnit,
+				kAudioOutputUnitProperty_CurrentDevice, kAudioUnitScope_Global, 0, &id, sizeof id);
+			if (status != noErr) {
+				return strdup("selecting the input device failed");
+			}
+		}
+		installCaptureTap(bufferSize, channel);
+
+		// The engine stops when the input device or its format changes:
+		// headphones plugged in, or a Bluetooth headset switching to its
+		// low quality mic profile. Tap the new format and carry on.
+		captureObserver = [[[NSNotificationCenter defaultCenter]
+			addObserverForName:AVAudioEngineConfigurationChangeNotification
+			object:captureEngine
+			queue:nil
+			usingBlock:^(NSNotification *note) {
+				[captureEngine.inputNode removeTapOnBus:0];
+				installCaptureTap(bufferSize, channel);
+				NSError *err = nil;
+				if (![captureEngine startAndReturnError:&err]) {
+					goCoreAudioRestartFailed((char *)err.localizedDescription.UTF8String);
+				}
+			}] retain];
+
+		NSError *err = nil;
+		if (![captureEngine startAndReturnError:&err]) {
+			return strdup(err.localizedDescription.UTF8String);
+		}
+		return NULL;
+	}
+}
+
+static void stopCapture(void) {
+	@autoreleasepool {
+		if (captureObserver != nil) {
+			[[NSNotificationCenter defaultCenter] removeObserver:captureObserver];
+			[captureObserver release];
+			captureObserver = nil;
+		}
+		if (captureEngine != nil) {
+			[captureEngine stop];
+			[captureEngine.inputNode removeTapOnBus:0];
+			[captureEngine release];
+			captureEngine = nil;
+		}
+	}
+}
+
+// inputDeviceName returns NULL without an input device, or a name the
+// caller frees.
+static char *inputDeviceName(void) {
+	@autoreleasepool {
+		AVCaptureDevice *device = [AVCaptureDevice defaultDeviceWithMediaType:AVMediaTypeAudio];
+		if (device == nil) {
+			return NULL;
+		}
+		return strdup(device.localizedName.UTF8String);
+	}
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+const coreAudioAvailable = true
+
+// coreAudioRecorder captures with AVAudioEngine. It takes the input in the
+// format the device delivers and resamples it, so devices that change rate
+// mid session (Bluetooth headsets going from 48 to 16 or 24 kHz) just work,
+// and the portaudio library isn't needed. Only one can capture at a time.
+type coreAudioRecorder struct {
+	buffers chan []float32
+	errs    chan error
+	// stopped is closed by Stop, so a Read waiting for a buffer returns.
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// input is the device recorded from instead of the default input, see
+	// audio.bluetooth.
+	input audioDevice
+
+	// Only touched from the tap callback, which runs serially.
+	rate      float64
+	resampler *resampler
+	dropped   int
+}
+
+var (
+	captureMu     sync.Mutex
+	activeCapture *coreAudioRecorder
+)
+
+func newCoreAudioRecorder() (Recorder, error) {
+	name := C.inputDeviceName()
+	if name == nil {
+		return nil, errors.New("no input device")
+	}
+	C.free(unsafe.Pointer(name))
+	r := &coreAudioRecorder{
+		buffers: make(chan []float32, 64),
+		errs:    make(chan error, 1),
+		stopped: make(chan struct{}),
+	}
+	r.input, _ = bluetoothGuard()
+	return r, nil
+}
+
+func (r *coreAudioRecorder) Start() error {
+	captureMu.Lock()
+	if activeCapture != nil {
+		captureMu.Unlock()
+		return errors.New("already recording")
+	}
+	activeCapture = r
+	captureMu.Unlock()
+
+	if msg := C.startCapture(C.int(config.Audio.FrameSize), C.int(config.Audio.Channel), C.uint(r.input.id)); msg != nil {
+		defer C.free(unsafe.Pointer(msg))
+		r.Stop()
+		return fmt.Errorf("starting audio engine: %s", C.GoString(msg))
+	}
+	return nil
+}
+
+func (r *coreAudioRecorder) Read() ([]float32, error) {
+	select {
+	case buffer := <-r.buffers:
+		return buffer, nil
+	case err := <-r.errs:
+		return nil, err
+	case <-r.stopped:
+		// Buffers captured before the stop still count.
+		select {
+		case buffer := <-r.buffers:
+			return buffer, nil
+		default:
+			return nil, errRecorderStopped
+		}
+	}
+}
+
+func (r *coreAudioRecorder) Stop() error {
+	C.stopCapture()
+	r.stopOnce.Do(func() { close(r.stopped) })
+
+	captureMu.Lock()
+	if activeCapture == r {
+		activeCapture = nil
+	}
+	captureMu.Unlock()
+	return nil
+}
+
+func (r *coreAudioRecorder) Overruns() int {
+	return r.dropped
+}
+
+func (r *coreAudioRecorder) Close() error {
+	return nil
+}
+
+func (r *coreAudioRecorder) Device() string {
+	name := r.DeviceName()
+	if name == "" {
+		return "coreaudio, no input device"
+	}
+	return "coreaudio, " + name
+}
+
+func (r *coreAudioRecorder) DeviceName() string {
+	if r.input.name != "" {
+		return r.input.name
+	}
+	name := C.inputDeviceName()
+	if name == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(name))
+	return C.GoString(name)
+}
+
+//export goCoreAudioSamples
+func goCoreAudioSamples(samples *C.float, frames C.int, rate C.double) {
+	captureMu.Lock()
+	r := activeCapture
+	captureMu.Unlock()
+	if r == nil {
+		return
+	}
+
+	if float64(rate) != r.rate {
+		r.rate = float64(rate)
+		r.resampler = newResampler(int(rate), sampleRate)
+	}
+	in := unsafe.Slice((*float32)(unsafe.Pointer(samples)), int(frames))
+	out := r.resampler.process(in, nil)
+
+	select {
+	case r.buffers <- out:
+	default:
+		// The reader fell behind; dropping beats blocking the audio thread.
+		r.dropped++
+	}
+}
+
+//export goCoreAudioRestartFailed
+func goCoreAudioRestartFailed(message *C.char) {
+	captureMu.Lock()
+	r := activeCapture
+	captureMu.Unlock()
+	if r == nil {
+		return
+	}
+	select {
+	case r.errs <- fmt.Errorf("audio device changed and capture could not restart: %s", C.GoString(message)):
+	default:
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !darwin
+
+package main
+
+import "errors"
+
+const coreAudioAvailable = false
+
+func newCoreAudioRecorder() (Recorder, error) {
+	return nil, errors.New("CoreAudio capture is only available on macOS")
+}
@@ -0,0 +1,11 @@
+//go:build noportaudio
+
+package main
+
+import "errors"
+
+// Built with -tags noportaudio, capture only works through CoreAudio and the
+// binary doesn't need the portaudio library.
+func newPortAudioRecorder() (Recorder, error) {
+	return nil, errors.New("built without portaudio")
+}
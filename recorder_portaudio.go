@@ -0,0 +1,169 @@
+//go:build !noportaudio
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// portAudioRecorder captures through portaudio, which works everywhere but
+// needs the portaudio library installed. Devices that only capture in
+// stereo or more are read with all their channels (raw) and downmixed.
+// A device opened at another rate than sampleRate (audio.devices
+// sample_rate) is resampled into out.
+type portAudioRecorder struct {
+	device       *portaudio.DeviceInfo
+	stream       *portaudio.Stream
+	raw, buffer  []float32
+	channelCount int
+	resampler    *resampler
+	out          []float32
+	overruns     int
+}
+
+func newPortAudioRecorder() (Recorder, error) {
+	// Initialize and Terminate are reference counted, so every recorder
+	// has its own pair.
+	if err := portaudio.Initialize(); err != nil {
+		return nil, fmt.Errorf("initializing portaudio: %w", err)
+	}
+
+	device, err := portaudio.DefaultInputDevice()
+	if err != nil {
+		portaudio.Terminate()
+		return nil, fmt.Errorf("finding input device: %w", err)
+	}
+	if mic, ok := bluetoothGuard(); ok {
+		if d := portAudioDevice(mic.name); d != nil {
+			device = d
+		}
+	}
+
+	rate, frames := sampleRate, config.Audio.FrameSize
+	if deviceRate := config.Audio.device(device.Name).SampleRate; deviceRate != 0 && deviceRate != sampleRate {
+		// Keep the buffers as long in time as at sampleRate.
+		rate, frames = deviceRate, frames*deviceRate/sampleRate
+	}
+	r := &portAudioRecorder{device: device, buffer: make([]float32, frames)}
+	if rate != sampleRate {
+		r.resampler = newResampler(rate, sampleRate)
+	}
+	params := portaudio.HighLatencyParameters(device, nil)
+	params.Input.Latency = inputLatency(device)
+	params.SampleRate = float64(rate)
+	params.FramesPerBuffer = len(r.buffer)
+
+	for _, n := range channelCounts(device.MaxInputChannels) {
+		params.Input.Channels = n
+		r.channelCount = n
+		r.raw = make([]float32, n*len(r.buffer))
+		r.stream, err = portaudio.OpenStream(params, r.raw)
+		if err == nil {
+			return r, nil
+		}
+	}
+	portaudio.Terminate()
+	return nil, fmt.Errorf("opening audio stream: %w", err)
+}
+
+// portAudioDevice returns the input device with the name, or nil.
+func portAudioDevice(name string) *portaudio.DeviceInfo {
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil
+	}
+	for _, d := range devices {
+		if d.Name == name && d.MaxInputChannels > 0 {
+			return d
+		}
+	}
+	return nil
+}
+
+// channelCounts returns the channel counts to try opening the device with:
+// the configured one, or mono first and then, as some interfaces refuse
+// mono, stereo and all the device has.
+func channelCounts(deviceMax int) []int {
+	if config.Audio.Channels > 0 {
+		return []int{config.Audio.Channels}
+	}
+	counts := []int{1}
+	for _, n := range []int{2, deviceMax} {
+		if n > counts[len(counts)-1] {
+			counts = append(counts, n)
+		}
+	}
+	return counts
+}
+
+// inputLatency returns the suggested latency for the stream: the device's
+// low or high default, or the configured duration.
+func inputLatency(device *portaudio.DeviceInfo) time.Duration {
+	switch config.Audio.Latency {
+	case audioLatencyLow:
+		return device.DefaultLowInputLatency
+	case "", audioLatencyHigh:
+		return device.DefaultHighInputLatency
+	}
+	d, _ := time.ParseDuration(config.Audio.Latency) // checked by validate
+	return d
+}
+
+func (r *portAudioRecorder) Start() error {
+	r.overruns = 0
+	if err := r.stream.Start(); err != nil {
+		return fmt.Errorf("starting audio stream: %w", err)
+	}
+	return nil
+}
+
+func (r *portAudioRecorder) Read() ([]float32, error) {
+	err := r.stream.Read()
+	if errors.Is(err, portaudio.InputOverflowed) {
+		// Some audio before this buffer was lost, the buffer itself is
+		// fine.
+		r.overruns++
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	mono := r.raw
+	if r.channelCount > 1 {
+		mono = downmix(r.buffer, r.raw, r.channelCount, config.Audio.Channel)
+	}
+	if r.resampler != nil {
+		r.out = r.resampler.process(mono, r.out[:0])
+		return r.out, nil
+	}
+	return mono, nil
+}
+
+func (r *portAudioRecorder) Overruns() int {
+	return r.overruns
+}
+
+func (r *portAudioRecorder) Stop() error {
+	if err := r.stream.Stop(); err != nil {
+		return fmt.Errorf("stopping audio stream: %w", err)
+	}
+	return nil
+}
+
+func (r *portAudioRecorder) Close() error {
+	err := r.stream.Close()
+	portaudio.Terminate()
+	return err
+}
+
+func (r *portAudioRecorder) Device() string {
+	return fmt.Sprintf("portaudio, %s (%.0f Hz, %d channels)", r.device.Name, r.device.DefaultSampleRate, r.device.MaxInputChannels)
+}
+
+func (r *portAudioRecorder) DeviceName() string {
+	return r.device.Name
+}
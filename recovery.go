@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-audio/wav"
+)
+
+// maxPendingAge is how long a recording that can't be transcribed is
+// retried at startup before it is given up on.
+const maxPendingAge = 7 * 24 * time.Hour
+
+// pendingDictation is a recording being transcribed, saved until its text
+// is delivered so a dictation that was in flight when dictation crashed or
+// was restarted, or whose transcription failed, is transcribed at the next
+// start instead of lost. The audio is next to it, as <id>.wav.
+type pendingDictation struct {
+	ID string `json:"id"`
+	// Profile is the profile as it was used, with the budget, language and
+	// prompts of the dictation applied; ProfileName and Params are the
+	// parts of it that don't go into its JSON.
+	ProfileName string              `json:"profile_name"`
+	Profile     Profile             `json:"profile"`
+	Params      TranscriptionParams `json:"params"`
+	Recorded    time.Time           `json:"recorded"`
+
+	dir string
+}
+
+func pendingDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pending"), nil
+}
+
+func (p *pendingDictation) audioPath() string { return filepath.Join(p.dir, p.ID+".wav") }
+func (p *pendingDictation) statePath() string { return filepath.Join(p.dir, p.ID+".json") }
+
+// savePending keeps a recording until done is called. It returns nil, and
+// the dictation isn't recovered, when privacy.audio is off or saving fails.
+func savePending(id string, profile Profile, samples []float32) *pendingDictation {
+	if !config.Privacy.Audio || len(samples) == 0 {
+		return nil
+	}
+	p, err := writePending(id, profile, samples)
+	if err != nil {
+		fmt.Printf("Warning: failed to save the recording for recovery: %v\n", err)
+		return nil
+	}
+	return p
+}
+
+func writePending(id string, profile Profile, samples []float32) (*pendingDictation, error) {
+	dir, err := pendingDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", dir, err)
+	}
+	encoder := wavEncoder{dither: true}
+	if err := checkDiskSpace(dir, encoder.Size(len(samples), sampleRate)); err != nil {
+		return nil, err
+	}
+
+	meta := recordingMetadata(samples)
+	p := &pendingDictation{ID: id, ProfileName: profile.Name, Profile: profile, Params: profile.Params, Recorded: meta.Created, dir: dir}
+	file, err := os.OpenFile(p.audioPath(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(file, samples, sampleRate, meta)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(p.audioPath())
+		return nil, err
+	}
+
+	// The state goes last: a recording without it is incomplete.
+	state, err := json.Marshal(p)
+	if err == nil {
+		err = os.WriteFile(p.statePath(), state, 0o600)
+	}
+	if err != nil {
+		os.Remove(p.audioPath())
+		return nil, fmt.Errorf("writing %s: %w", p.statePath(), err)
+	}
+	return p, nil
+}
+
+// done removes the saved recording, once its text was delivered or it
+// failed in a way a retry won't fix. It does nothing on nil.
+func (p *pendingDictation) done() {
+	if p == nil {
+		return
+	}
+	os.Remove(p.statePath())
+	os.Remove(p.audioPath())
+}
+
+// recoverPending transcribes the recordings a previous run didn't get to
+// deliver, as it was stopped or the provider failed. The text goes to the clipboard and the history, not to the
+// focused app, which has nothing to do with it anymore.
+func recoverPending(ctx context.Context) {
+	dir, err := pendingDir()
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var p pendingDictation
+		if err := json.Unmarshal(data, &p); err != nil || p.ID != name {
+			fmt.Printf("Warning: ignoring the unreadable pending dictation %s\n", e.Name())
+			continue
+		}
+		p.dir = dir
+		p.Profile.Name, p.Profile.Params = p.ProfileName, p.Params
+		recoverDictation(&p)
+	}
+
+	// Recordings without their state were cut off while being saved.
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".wav"); ok {
+			if _, err := os.Stat(filepath.Join(dir, id+".json")); os.IsNotExist(err) {
+				os.Remove(filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+}
+
+func recoverDictation(p *pendingDictation) {
+	fmt.Printf("Recovering the dictation recorded at %s\n", p.Recorded.Format("15:04 on Jan 2"))
+	samples, err := readWAVSamples(p.audioPath())
+	if err != nil {
+		fmt.Printf("Warning: can't recover the dictation: %v\n", err)
+		p.done()
+		return
+	}
+
+	started := time.Now()
+	duration := time.Duration(len(samples)) * time.Second / sampleRate
+	text, err := transcribeSamples(samples, p.Profile, config.provider(p.Profile), nil)
+	if err != nil {
+		logTranscription(transcriptionEvent{RecordingID: p.ID}, p.Profile, duration, time.Since(started), "", err)
+		if time.Since(p.Recorded) > maxPendingAge {
+			fmt.Printf("Error: giving up on the dictation recorded at %s: %v\n", p.Recorded.Format(time.DateTime), err)
+			p.done()
+			return
+		}
+		// Kept for the next start, the provider may be back by then.
+		fmt.Printf("Error: can't recover the dictation yet: %v\n", err)
+		return
+	}
+
+	text = postProcess(text, p.Profile)
+	logTranscription(transcriptionEvent{RecordingID: p.ID}, p.Profile, duration, time.Since(started), text, nil)
+	if strings.TrimSpace(text) == "" {
+		fmt.Println("The recovered dictation is empty")
+		p.done()
+		return
+	}
+	if err := injector.WriteClipboard(text); err != nil {
+		fmt.Printf("Error: copying the recovered dictation failed: %v\n", err)
+	}
+	recordHistory(p.ID, p.Profile, text, "", "")
+	fmt.Printf("Recovered dictation: %s\n", logged(text))
+	notify(tr("Dictation recovered"), tr("An earlier dictation that wasn't delivered is on the clipboard: %s", truncate(logged(text), 80)))
+	p.done()
+}
+
+// readWAVSamples reads a WAV file written by wavEncoder back as samples.
+func readWAVSamples(path string) ([]float32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := wav.NewDecoder(f)
+	buffer, err := d.FullPCMBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if int(d.SampleRate) != sampleRate || int(d.NumChans) != channels {
+		return nil, fmt.Errorf("reading %s: %d Hz with %d channels, not a recording", path, d.SampleRate, d.NumChans)
+	}
+	samples := make([]float32, len(buffer.Data))
+	for i, v := range buffer.Data {
+		samples[i] = float32(v) / math.MaxInt16
+	}
+	return samples, nil
+}
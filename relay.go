@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RelayConfig configures dictation relay, a server that transcribes for a
+// team with the provider keys it holds, so they don't have to be handed
+// out. Clients use it as an OpenAI compatible provider.
+type RelayConfig struct {
+	// Listen is the address to serve on.
+	Listen string `json:"listen"`
+	// Tokens are the API keys of the relay's users, by user name.
+	Tokens map[string]string `json:"tokens"`
+	// Profiles are the profiles clients may pick with the model field of a
+	// request; everything else uses the default profile.
+	Profiles []string `json:"profiles"`
+	// MaxMB bounds the size of a request.
+	MaxMB int `json:"max_mb"`
+}
+
+const (
+	// relayReadTimeout is how long a client has to upload, enough for
+	// max_mb over a slow connection; relayWriteTimeout covers the
+	// transcription and the response.
+	relayReadTimeout  = 2 * time.Minute
+	relayWriteTimeout = 5 * time.Minute
+)
+
+// runRelayCommand serves the relay until interrupted.
+func runRelayCommand(args []string) error {
+	flags := flag.NewFlagSet("relay", flag.ExitOnError)
+	listen := flags.String("listen", config.Relay.Listen, "address to listen on")
+	flags.Parse(args)
+
+	if len(config.Relay.Tokens) == 0 {
+		return errors.New("relay.tokens is empty, nobody could use the relay")
+	}
+	for _, name := range relayProfiles() {
+		if _, err := fileProfile(name); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/audio/transcriptions", relayAuth(handleRelayTranscription))
+	mux.HandleFunc("GET /v1/models", relayAuth(handleRelayModels))
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", *listen, err)
+	}
+	// Uploads are bounded in size and time, and so is a response, which
+	// waits for the transcription.
+	server := &http.Server{
+		Handler:           http.MaxBytesHandler(mux, int64(config.Relay.MaxMB)<<20),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       relayReadTimeout,
+		WriteTimeout:      relayWriteTimeout,
+		MaxHeaderBytes:    64 << 10,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Relay listening on http://%s/v1 for %d users\n", listener.Addr(), len(config.Relay.Tokens))
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// relayProfiles returns the profiles clients can use, the default one
+// first.
+func relayProfiles() []string {
+	names := []string{defaultProfile}
+	for _, name := range config.Relay.Profiles {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type relayUserKey struct{}
+
+// relayAuth lets requests with one of the relay tokens through, with the
+// user's name in the request context.
+func relayAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok {
+			for user, t := range config.Relay.Tokens {
+				if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+					next(w, r.WithContext(context.WithValue(r.Context(), relayUserKey{}, user)))
+					return
+				}
+			}
+		}
+		relayError(w, http.StatusUnauthorized, "invalid relay token")
+	}
+}
+
+// handleRelayTranscription takes an OpenAI style multipart upload, with
+// the file and optionally model, language, prompt, temperature and
+// response_format (json or text), and transcribes it with the relay's
+// profile.
+func handleRelayTranscription(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(relayUserKey{}).(string)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			relayError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("uploads are limited to %d MB", config.Relay.MaxMB))
+			return
+		}
+		relayError(w, http.StatusBadRequest, "invalid upload: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	profileName := defaultProfile
+	if model := r.FormValue("model"); slices.Contains(relayProfiles(), model) {
+		profileName = model
+	}
+	// The budget is checked per request, for the whole team's usage.
+	profile := applyBudget(config.Profiles[profileName])
+	if lang := r.FormValue("language"); lang != "" {
+		profile.Params.Language = lang
+	}
+	if prompt := r.FormValue("prompt"); prompt != "" {
+		profile.Params.Prompt = prompt
+	}
+	if t := r.FormValue("temperature"); t != "" {
+		temperature, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			relayError(w, http.StatusBadRequest, "invalid temperature")
+			return
+		}
+		profile.Params.Temperature = &temperature
+	}
+	format := r.FormValue("response_format")
+	if format != "" && format != "json" && format != "text" {
+		relayError(w, http.StatusBadRequest, "response_format must be json or text")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		relayError(w, http.StatusBadRequest, "no file in the upload")
+		return
+	}
+	defer file.Close()
+	path, err := saveUpload(file, filepath.Ext(header.Filename))
+	if err != nil {
+		relayError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.Remove(path)
+
+	started := time.Now()
+	text, err := transcribeFile(path, profile)
+	if err != nil {
+		fmt.Printf("Relay: %s: transcription failed: %v\n", user, err)
+		relayError(w, http.StatusBadGateway, "transcription failed: "+err.Error())
+		return
+	}
+	fmt.Printf("Relay: %s: %s, %d characters in %dms\n", user, profile.Name, len([]rune(text)), time.Since(started).Milliseconds())
+
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, text)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"text": text})
+}
+
+// handleRelayModels lists the profiles clients can pick, which is also how
+// dictation doctor checks a relay token.
+func handleRelayModels(w http.ResponseWriter, r *http.Request) {
+	type model struct {
+		ID     string `json:"id"`
+		Object string `json:"object"`
+	}
+	var models []model
+	for _, name := range relayProfiles() {
+		models = append(models, model{name, "model"})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": models})
+}
+
+// relayError answers with an error in OpenAI's format, which clients
+// already know how to show.
+func relayError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": message}})
+}
+
+// saveUpload writes an uploaded file to a temporary file with the given
+// extension, which tells transcribeFile its format.
+func saveUpload(r io.Reader, ext string) (string, error) {
+	f, err := os.CreateTemp("", "dictation-relay-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("saving upload: %w", err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// remoteApps are the remote desktop, screen sharing and VM viewer apps
+// known out of the box; their IDs as frontmostApp reports them: bundle IDs
+// on macOS, executables on Windows and window classes on Linux. Keystrokes
+// typed into them land on another machine, or in front of an audience.
+var remoteApps = []string{
+	// macOS
+	"com.apple.ScreenSharing",
+	"com.apple.ScreenContinuity", // iPhone Mirroring
+	"com.microsoft.rdc.macos",
+	"com.microsoft.rdc.mac",
+	"com.teamviewer.TeamViewer",
+	"com.realvnc.vncviewer",
+	"com.philandro.anydesk",
+	"com.citrix.receiver.icaviewer.mac",
+	"com.p5sys.jump.mac.viewer",
+	"com.vmware.horizon",
+	"tv.parsec.www",
+	// Windows
+	"mstsc.exe",
+	"msrdc.exe",
+	"TeamViewer.exe",
+	"AnyDesk.exe",
+	"vncviewer.exe",
+	"wfica32.exe",
+	"parsecd.exe",
+	// Linux
+	"Remmina",
+	"org.remmina.Remmina",
+	"Vncviewer",
+	"TeamViewer",
+	"AnyDesk",
+	"xfreerdp",
+	"Vinagre",
+	"org.gnome.Connections",
+}
+
+// remoteSession returns the focused app if it is a remote session (see
+// remoteApps and output.remote_apps), or "".
+func remoteSession() string {
+	app := frontmostApp()
+	if app == "" {
+		return ""
+	}
+	known := func(id string) bool { return strings.EqualFold(id, app) }
+	if slices.ContainsFunc(remoteApps, known) || slices.ContainsFunc(config.Output.RemoteApps, known) {
+		return app
+	}
+	return ""
+}
+
+// handleRemoteSession deals with a transcription about to be typed into a
+// remote session, per output.remote_session: confirmed in a preview first,
+// or held for the reinsert hotkey.
+func handleRemoteSession(text, app string) {
+	fmt.Printf("%s is a remote session, not typing without confirmation\n", app)
+
+	confirmText(config.Output.RemoteSession, text, tr("Dictation: type into %s?", app),
+		tr("%s is a remote session; press the reinsert hotkey to type it there: %s", app, truncate(logged(text), 80)))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+// resampler converts mono float samples between sample rates with linear
+// interpolation. When it lowers the rate, the input goes through a low-pass
+// filter first, or everything above the new Nyquist frequency (8 kHz for
+// 16 kHz audio) would fold back into the speech band as noise. It keeps its
+// position and the filter's history between calls, so a recording can be
+// fed through it buffer by buffer.
+type resampler struct {
+	step   float64 // input samples per output sample
+	pos    float64 // position of the next output sample, relative to prev
+	prev   float32 // last input sample of the previous buffer
+	primed bool
+
+	// taps is the low-pass filter, nil when raising the rate. history
+	// holds the last len(taps)-1 input samples followed by the buffer
+	// being filtered, filtered the result, both reused between calls.
+	taps     []float32
+	history  []float32
+	filtered []float32
+}
+
+// lowPassTaps is the length of the low-pass filter. At 44.1 kHz it makes
+// the transition from pass to stop band about 2 kHz wide.
+const lowPassTaps = 127
+
+func newResampler(from, to int) *resampler {
+	r := &resampler{step: float64(from) / float64(to)}
+	if to < from {
+		// The cutoff is below the new Nyquist frequency by the width of
+		// the transition band, so the stop band starts before it.
+		r.taps = lowPass(0.85*float64(to)/2/float64(from), lowPassTaps)
+		r.history = make([]float32, lowPassTaps-1)
+	}
+	return r
+}
+
+// lowPass returns a windowed-sinc low-pass filter of n taps with the cutoff
+// frequency given as a fraction of the sample rate. The Blackman window
+// keeps the stop band 70 dB down.
+func lowPass(cutoff float64, n int) []float32 {
+	taps := make([]float32, n)
+	mid := float64(n-1) / 2
+	var sum float64
+	h := make([]float64, n)
+	for i := range h {
+		x := float64(i) - mid
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		window := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1)) + 0.08*math.Cos(4*math.Pi*float64(i)/float64(n-1))
+		h[i] = sinc * window
+		sum += h[i]
+	}
+	for i := range h {
+		taps[i] = float32(h[i] / sum) // unity gain at 0 Hz
+	}
+	return taps
+}
+
+// filter low-passes a buffer, continuing from the previous one. The filter
+// delays the audio by half its length, under 1.5 ms at 44.1 kHz.
+func (r *resampler) filter(in []float32) []float32 {
+	n := len(r.taps)
+	r.history = append(r.history, in...)
+	r.filtered = r.filtered[:0]
+	for i := 0; i+n <= len(r.history); i++ {
+		var acc float32
+		for k, t := range r.taps {
+			acc += t * r.history[i+k]
+		}
+		r.filtered = append(r.filtered, acc)
+	}
+	r.history = r.history[:copy(r.history, r.history[len(r.history)-(n-1):])]
+	return r.filtered
+}
+
+// process appends the resampled version of in to out and returns it.
+func (r *resampler) process(in []float32, out []float32) []float32 {
+	if len(in) == 0 {
+		return out
+	}
+	if r.taps != nil {
+		in = r.filter(in)
+	}
+	if !r.primed {
+		r.prev = in[0]
+		r.primed = true
+	}
+
+	// at(i) treats the previous buffer's last sample as index 0 and in[0]
+	// as index 1.
+	at := func(i int) float32 {
+		if i == 0 {
+			return r.prev
+		}
+		return in[i-1]
+	}
+
+	for {
+		i := int(r.pos)
+		if i+1 > len(in) {
+			break
+		}
+		frac := float32(r.pos - float64(i))
+		out = append(out, at(i)+(at(i+1)-at(i))*frac)
+		r.pos += r.step
+	}
+
+	r.pos -= float64(len(in))
+	r.prev = in[len(in)-1]
+	return out
+}
+
+// liveResampler resamples a recording to the upload rates of the providers
+// that may transcribe it while it is being captured, buffer by buffer, so
+// it's ready to upload the moment recording stops instead of after a pass
+// over the whole recording.
+type liveResampler struct {
+	mu    sync.Mutex
+	rates map[int]*resampledAudio
+	// fed is how many samples of the recording went in so far.
+	fed  int
+	done bool
+}
+
+type resampledAudio struct {
+	r       *resampler
+	samples []float32
+}
+
+// newLiveResampler returns a liveResampler to the given rates, or nil when
+// all of them are the capture rate.
+func newLiveResampler(rates ...int) *liveResampler {
+	l := &liveResampler{rates: map[int]*resampledAudio{}}
+	for _, rate := range rates {
+		if rate != sampleRate {
+			l.rates[rate] = &resampledAudio{r: newResampler(sampleRate, rate)}
+		}
+	}
+	if len(l.rates) == 0 {
+		return nil
+	}
+	return l
+}
+
+// add resamples a captured buffer. It does nothing on nil.
+func (l *liveResampler) add(buffer []float32) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done {
+		return
+	}
+	l.feed(buffer)
+}
+
+func (l *liveResampler) feed(buffer []float32) {
+	for _, a := range l.rates {
+		a.samples = a.r.process(buffer, a.samples)
+	}
+	l.fed += len(buffer)
+}
+
+// finish resamples the end of the recording add didn't get, as the capture
+// can stop between reading a buffer and passing it on, and ends it.
+func (l *liveResampler) finish(samples []float32) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.done && l.fed < len(samples) {
+		l.feed(samples[l.fed:])
+	}
+	l.done = true
+}
+
+// at returns the recording at rate, once finished, and false if it wasn't
+// resampled to it.
+func (l *liveResampler) at(rate int) ([]float32, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, ok := l.rates[rate]
+	if !ok || !l.done {
+		return nil, false
+	}
+	return a.samples, true
+}
@@ -0,0 +1,82 @@
+package main
+
+import "sync"
+
+// sampleBlockSize is how many samples a block of a recording holds, about
+// a second and a half at 44.1 kHz.
+const sampleBlockSize = 1 << 16
+
+// sampleBlockPool keeps the blocks of finished recordings for the next
+// one, so recording doesn't allocate once the pool has enough of them.
+var sampleBlockPool = sync.Pool{New: func() any {
+	block := make([]float32, 0, sampleBlockSize)
+	return &block
+}}
+
+// sampleBlocks collects a recording while it is captured, in blocks of a
+// fixed size, instead of a slice that keeps being reallocated and copied as
+// it grows: a long recording otherwise briefly needs three times its size
+// and leaves the garbage collector a lot to do. It is safe to use from the
+// capture goroutine while another one ends it.
+type sampleBlocks struct {
+	mu     sync.Mutex
+	blocks []*[]float32
+	n      int
+	done   bool
+}
+
+// append adds a captured buffer. It reports whether it started a new
+// block.
+func (s *sampleBlocks) append(buffer []float32) (grew bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return false
+	}
+	s.n += len(buffer)
+	for len(buffer) > 0 {
+		if len(s.blocks) == 0 || len(*s.blocks[len(s.blocks)-1]) == sampleBlockSize {
+			s.blocks = append(s.blocks, sampleBlockPool.Get().(*[]float32))
+			grew = true
+		}
+		last := s.blocks[len(s.blocks)-1]
+		n := min(len(buffer), sampleBlockSize-len(*last))
+		*last = append(*last, buffer[:n]...)
+		buffer = buffer[n:]
+	}
+	return grew
+}
+
+// len returns how many samples were added.
+func (s *sampleBlocks) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n
+}
+
+// bytes returns how much memory the blocks take.
+func (s *sampleBlocks) bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.blocks)) * sampleBlockSize * 4
+}
+
+// samples ends the recording and returns it as one slice, allocated once
+// at its final size; the blocks go back to the pool. Later appends are
+// ignored.
+func (s *sampleBlocks) samples() []float32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	if s.n == 0 {
+		return nil
+	}
+	out := make([]float32, 0, s.n)
+	for _, block := range s.blocks {
+		out = append(out, *block...)
+		*block = (*block)[:0]
+		sampleBlockPool.Put(block)
+	}
+	s.blocks = nil
+	return out
+}
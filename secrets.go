@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretTimeout bounds a secret command, long enough to unlock a password
+// manager with Touch ID.
+const secretTimeout = time.Minute
+
+// secrets caches what secret commands printed, by command line, so the
+// password manager is asked once per run and not on every request.
+var secrets sync.Map
+
+// lookupSecret returns a secret such as an API key: what command prints if
+// it is set ("op read op://...", "pass show ..."), or else the value of the
+// environment variable env.
+func lookupSecret(env string, command []string) (string, error) {
+	if len(command) == 0 {
+		return os.Getenv(env), nil
+	}
+	key := strings.Join(command, "\x00")
+	if secret, ok := secrets.Load(key); ok {
+		return secret.(string), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", fmt.Errorf("running %s: %w", command[0], err)
+	}
+	secret := strings.TrimSpace(string(out))
+	if secret == "" {
+		return "", fmt.Errorf("%s printed nothing", command[0])
+	}
+	secrets.Store(key, secret)
+	return secret, nil
+}
+
+// checkSecret reports why a secret that is configured isn't available.
+func checkSecret(env string, command []string) error {
+	if env == "" && len(command) == 0 {
+		return nil
+	}
+	secret, err := lookupSecret(env, command)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return errors.New(env + " environment variable not set")
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// segmentPause is how long a pause has to be for a long recording to be cut
+// there.
+const segmentPause = 500 * time.Millisecond
+
+// A recording that would be a bigger upload than the provider takes is cut
+// at the next pause once its audio reaches uploadPauseShare of the limit,
+// and wherever it is at uploadCutShare, so no request fails for its size.
+const (
+	uploadPauseShare = 0.9
+	uploadCutShare   = 0.98
+)
+
+// segmentedSession cuts a long recording into segments at pauses, once it
+// has run for recording.segment_after. Each segment is transcribed in the
+// background while recording goes on, and typed as soon as it and all the
+// segments before it are done, so the text appears progressively and the
+// wait at the end stays short however long the dictation is. In draft mode
+// the segments are only collected, and delivered as one text at the end;
+// so are those of a recording that is only cut to stay below the
+// provider's upload limit (without segment_after).
+type segmentedSession struct {
+	id       string
+	profile  Profile
+	provider Provider
+	collect  bool
+	trace    *span
+	// minLength, maxLength (0 for none) and pause are in samples.
+	minLength, maxLength, pause int
+
+	// samples is the audio since the last cut, quiet how many samples at
+	// its end are below the silence level. leading is how many samples it
+	// started with below it, speaking tells whether speech came since, and
+	// gap is the pause at the end of the previous segment; together they
+	// make the pause between two segments.
+	samples  []float32
+	quiet    int
+	leading  int
+	speaking bool
+	gap      int
+
+	// count is the number of segments submitted. prev is closed once the
+	// last submitted one has been typed.
+	count int
+	prev  chan struct{}
+
+	mu        sync.Mutex
+	texts     []string
+	err       error
+	discarded bool
+}
+
+func newSegmentedSession(id string, profile Profile, after time.Duration, trace *span) *segmentedSession {
+	prev := make(chan struct{})
+	close(prev)
+	s := &segmentedSession{
+		id:        id,
+		profile:   profile,
+		provider:  config.provider(profile),
+		collect:   profile.Draft != "" || after == 0,
+		trace:     trace,
+		minLength: int(after.Seconds() * sampleRate),
+		pause:     int(segmentPause.Seconds() * sampleRate),
+		prev:      prev,
+	}
+	if limit := s.provider.uploadLimit(); limit > 0 {
+		// The audio is uploaded at another rate than it's recorded at.
+		rate := s.provider.uploadRate()
+		perSecond := float64(uploadEncoder.Size(rate, rate) - uploadEncoder.Size(0, rate))
+		fits := float64(limit-uploadEncoder.Size(0, rate)) / perSecond * sampleRate
+		s.maxLength = int(fits * uploadCutShare)
+		if pauseLength := int(fits * uploadPauseShare); s.minLength == 0 || pauseLength < s.minLength {
+			s.minLength = pauseLength
+		}
+	}
+	return s
+}
+
+// add takes a captured buffer, in the recording goroutine, and cuts a
+// segment when the recording is long enough and a pause has just been long
+// enough.
+func (s *segmentedSession) add(buffer []float32) {
+	s.samples = append(s.samples, buffer...)
+	if rmsLevel(buffer) < captureSettings.SilenceLevel {
+		s.quiet += len(buffer)
+		if !s.speaking {
+			s.leading += len(buffer)
+		}
+	} else {
+		s.quiet = 0
+		s.speaking = true
+	}
+	switch {
+	case len(s.samples) >= s.minLength && s.quiet >= s.pause && s.speaking:
+		s.cut("at a pause")
+	case s.maxLength > 0 && len(s.samples) >= s.maxLength:
+		s.cut("at the provider's upload limit")
+	}
+}
+
+// cut submits the audio since the last cut as a segment; where says where
+// it was cut, for the log.
+func (s *segmentedSession) cut(where string) {
+	pause := time.Duration(s.gap+s.leading) * time.Second / sampleRate
+	s.submit(s.samples, pause, where)
+	s.gap = s.quiet
+	s.samples, s.quiet, s.leading, s.speaking = nil, 0, 0, false
+}
+
+// submit transcribes a segment in the background and types it once the
+// segment before it has been typed. pause is the silence between it and the
+// previous segment.
+func (s *segmentedSession) submit(samples []float32, pause time.Duration, where string) {
+	s.count++
+	n := s.count
+	prev, done := s.prev, make(chan struct{})
+	s.prev = done
+	fmt.Printf("Segment %d (%s) cut %s, transcribing it\n", n, formatElapsed(time.Duration(len(samples))*time.Second/sampleRate), where)
+
+	cut := time.Now()
+	segment := s.trace.child("segment")
+	segment.set("segment", n)
+	go func() {
+		defer close(done)
+		defer func() {
+			// The segment is lost like one whose request failed.
+			if r := recover(); r != nil {
+				err := crashed("segment transcription", r)
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+			}
+		}()
+		text, err := transcribeSamples(samples, s.profile, s.provider, segment)
+		segment.end(err)
+		audio := time.Duration(len(samples)) * time.Second / sampleRate
+		<-prev
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err != nil {
+			fmt.Printf("Error transcribing segment %d: %v\n", n, err)
+			logTranscription(transcriptionEvent{RecordingID: s.id}, s.profile, audio, time.Since(cut), "", err)
+			s.err = err
+			return
+		}
+		profile := s.profile
+		if s.collect {
+			// Collected text is cleaned up as a whole once it is
+			// complete.
+			profile.Cleanup = false
+		}
+		text = postProcess(text, profile)
+		logTranscription(transcriptionEvent{RecordingID: s.id}, s.profile, audio, time.Since(cut), text, nil)
+		if s.discarded || text == "" {
+			return
+		}
+		if len(s.texts) > 0 {
+			separator := " "
+			if !s.collect {
+				separator = segmentSeparator()
+			}
+			text = stitch(s.texts[len(s.texts)-1], text, pause, separator, profile.CodeMode)
+		} else if !s.collect {
+			// Collected text gets its timestamp when it is delivered as a
+			// whole.
+			text = journalEntry(profile, text, time.Now())
+		}
+		s.texts = append(s.texts, text)
+		fmt.Printf("Segment %d: %s\n", n, logged(text))
+		if !s.collect {
+			insertTextThen(text, followUp{Cursor: -1, Recording: s.id})
+		}
+	}()
+}
+
+// stitch prepares a segment to be typed after the previous one so the two
+// read as one text. Whisper transcribes each segment as if it were a whole
+// dictation, starting it with a capital, so when the previous segment
+// didn't end a sentence the capital is taken back. A pause of at least
+// recording.paragraph_pause starts a new paragraph, otherwise separator goes
+// between the two.
+func stitch(previous, text string, pause time.Duration, separator string, codeMode bool) string {
+	if paragraph := config.Recording.paragraphPause(); paragraph > 0 && pause >= paragraph {
+		return "\n\n" + capitalizeFirst(strings.TrimLeftFunc(text, unicode.IsSpace))
+	}
+
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	if !codeMode {
+		if endsSentence(previous) {
+			text = capitalizeFirst(text)
+		} else {
+			text = uncapitalizeFirst(text, previous)
+		}
+	}
+	return separator + text
+}
+
+// segmentSeparator goes between segments, a space unless the trailing text
+// configured for the focused app already put one there.
+func segmentSeparator() string {
+	trailing := config.Output.Trailing
+	if app := config.app(frontmostApp()); app.Trailing != "" {
+		trailing = app.Trailing
+	}
+	if trailing == trailingNone {
+		return " "
+	}
+	return ""
+}
+
+func endsSentence(text string) bool {
+	text = strings.TrimRight(strings.TrimRightFunc(text, unicode.IsSpace), `"')]`)
+	return text == "" || strings.ContainsAny(text[len(text)-1:], ".!?:")
+}
+
+func capitalizeFirst(text string) string {
+	if text == "" {
+		return text
+	}
+	r, size := utf8.DecodeRuneInString(text)
+	return string(unicode.ToUpper(r)) + text[size:]
+}
+
+// uncapitalizeFirst lowercases the first letter unless the first word looks
+// like it is capitalized for its own sake: "I", "I'm", acronyms and names,
+// see properNoun. previous is the text before it.
+func uncapitalizeFirst(text, previous string) string {
+	if text == "" {
+		return text
+	}
+	first, rest, _ := strings.Cut(text, " ")
+	word := strings.TrimRightFunc(first, unicode.IsPunct)
+	if word == "I" || strings.HasPrefix(word, "I'") || strings.HasPrefix(word, "I’") {
+		return text
+	}
+	r, size := utf8.DecodeRuneInString(word)
+	if size < len(word) {
+		if next, _ := utf8.DecodeRuneInString(word[size:]); unicode.IsUpper(next) {
+			return text
+		}
+	}
+	if properNoun(word, previous+" "+rest) {
+		return text
+	}
+	return string(unicode.ToLower(r)) + text[size:]
+}
+
+// properNoun reports whether a capitalized word is a name, as far as can be
+// told without a dictionary: it is one of the vocabulary words or one the
+// corrections bring in, or it is capitalized in the middle of a sentence
+// elsewhere in the dictation.
+func properNoun(word, dictation string) bool {
+	for _, w := range config.Vocabulary.Words {
+		if slices.Contains(strings.Fields(w), word) {
+			return true
+		}
+	}
+	for _, meant := range vocabulary.replacements() {
+		if slices.Contains(strings.Fields(meant), word) {
+			return true
+		}
+	}
+	fields := strings.Fields(dictation)
+	for i := 1; i < len(fields); i++ {
+		if strings.TrimRightFunc(fields[i], unicode.IsPunct) == word && !endsSentence(fields[i-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// discard drops the segments that haven't been typed yet, for an aborted
+// recording.
+func (s *segmentedSession) discard() {
+	// Before locking, a segment waiting for its turn holds the lock.
+	insertions.cancel(s.id)
+	s.mu.Lock()
+	s.discarded = true
+	s.mu.Unlock()
+}
+
+// finish transcribes what was recorded since the last cut, waits for all
+// segments to be typed and records the dictation as a whole. target is the
+// app that had focus when the recording stopped, for a draft.
+func (s *segmentedSession) finish(target string) {
+	if s.speaking {
+		s.cut("at the end")
+	}
+	<-s.prev
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	text := strings.Join(s.texts, "")
+
+	if s.collect {
+		if s.profile.Cleanup {
+			cleanup := s.trace.child("cleanup")
+			text = filterProfanity(cleanupStage(text, s.profile), config.Profanity)
+			cleanup.end(nil)
+		}
+		insert := s.trace.child("insert")
+		deliverTranscription(s.id, s.profile, text, target)
+		insert.end(nil)
+	} else {
+		s.recordTyped(text)
+	}
+
+	if s.err != nil {
+		status.fail(fmt.Errorf("some segments failed: %w", s.err))
+	}
+}
+
+// recordTyped records a dictation whose segments were already typed.
+func (s *segmentedSession) recordTyped(text string) {
+	var app, title string
+	if config.History.Enabled && config.History.AppContext {
+		app, title = frontmostApp(), windowTitle()
+	}
+	status.finish(text)
+	fmt.Printf("You said: %s\n", logged(text))
+	copyLast(text)
+	if text != "" {
+		recordHistory(s.id, s.profile, text, app, title)
+	}
+}
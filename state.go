@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+type dictationState string
+
+const (
+	stateIdle         dictationState = "idle"
+	stateRecording    dictationState = "recording"
+	stateTranscribing dictationState = "transcribing"
+	// stateRateLimited means a transcription is waiting for the provider's
+	// rate limit to pass; RetryAt says until when.
+	stateRateLimited dictationState = "rate_limited"
+)
+
+// statusSnapshot is what status surfaces (the Stream Deck API and friends)
+// get to see. It is also the JSON shape sent to them.
+type statusSnapshot struct {
+	State dictationState `json:"state"`
+	// RecordingID identifies the current (or last) recording.
+	RecordingID string `json:"recording_id,omitempty"`
+	// RecordingSince is when the current recording started, only set while
+	// recording.
+	RecordingSince *time.Time `json:"recording_since,omitempty"`
+	// Profile and Device are the profile and input device of the current
+	// (or last) recording.
+	Profile  string `json:"profile,omitempty"`
+	Device   string `json:"device,omitempty"`
+	LastText string `json:"last_text"`
+	// BlockedApp is the focused app when triggers are blocked in it.
+	BlockedApp string `json:"blocked_app,omitempty"`
+	// Disabled says why the triggers are off for a while, "paused" or
+	// "quiet_hours", and DisabledUntil until when.
+	Disabled      string     `json:"disabled,omitempty"`
+	DisabledUntil *time.Time `json:"disabled_until,omitempty"`
+	// LastTranscriptionAt is when the last dictation was done.
+	LastTranscriptionAt *time.Time `json:"last_transcription_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	// ProviderProblems are what the last preflight found wrong with the
+	// providers in use (unreachable, key rejected), by provider.
+	ProviderProblems map[string]string `json:"provider_problems,omitempty"`
+	// AudioProblem is why the audio input can't be opened, while it can't.
+	AudioProblem string     `json:"audio_problem,omitempty"`
+	RetryAt      *time.Time `json:"retry_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// statusTracker records the dictation state and fans changes out to
+// subscribers. Subscribers that fall behind miss intermediate updates but
+// always end up with the latest one.
+type statusTracker struct {
+	mu          sync.Mutex
+	current     statusSnapshot
+	subscribers map[chan statusSnapshot]struct{}
+
+	// inserted holds the IDs of the last recordings whose text was typed,
+	// oldest first, so a late duplicate result can't be typed again.
+	inserted []string
+}
+
+// maxInsertedIDs bounds how many inserted recording IDs are remembered.
+const maxInsertedIDs = 100
+
+// newRecordingID returns a random (version 4) UUID.
+func newRecordingID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// elapsed returns how long the current recording has been going, as m:ss,
+// or "" when not recording.
+func (s statusSnapshot) elapsed() string {
+	if s.RecordingSince == nil {
+		return ""
+	}
+	return formatElapsed(time.Since(*s.RecordingSince))
+}
+
+func formatElapsed(d time.Duration) string {
+	seconds := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+var status = &statusTracker{
+	current:     statusSnapshot{State: stateIdle, UpdatedAt: time.Now()},
+	subscribers: make(map[chan statusSnapshot]struct{}),
+}
+
+func (t *statusTracker) snapshot() statusSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current
+}
+
+// begin marks the start of a new recording.
+func (t *statusTracker) begin(id, profile string) {
+	t.update(func(s *statusSnapshot) {
+		now := time.Now()
+		s.State = stateRecording
+		s.RecordingID = id
+		s.RecordingSince = &now
+		s.Profile = profile
+		s.LastError = ""
+	})
+}
+
+// setProfile records the profile a recording ended up using, when the
+// budget switched it to the fallback.
+func (t *statusTracker) setProfile(profile string) {
+	t.update(func(s *statusSnapshot) {
+		s.Profile = profile
+	})
+}
+
+// setBlocked records the focused app triggers are blocked in, "" for none.
+func (t *statusTracker) setBlocked(app string) {
+	t.update(func(s *statusSnapshot) {
+		s.BlockedApp = app
+	})
+}
+
+// setDisabled records why the triggers are off and until when, "" for on.
+func (t *statusTracker) setDisabled(reason string, until time.Time) {
+	t.update(func(s *statusSnapshot) {
+		s.Disabled, s.DisabledUntil = reason, nil
+		if reason != "" {
+			s.DisabledUntil = &until
+		}
+	})
+}
+
+// setProviderProblems records the result of a preflight.
+func (t *statusTracker) setProviderProblems(problems map[string]string) {
+	t.update(func(s *statusSnapshot) {
+		s.ProviderProblems = problems
+	})
+}
+
+// setAudioProblem records why the audio input can't be opened, "" once it
+// can.
+func (t *statusTracker) setAudioProblem(problem string) {
+	t.update(func(s *statusSnapshot) {
+		s.AudioProblem = problem
+	})
+}
+
+// setDevice records the input device being recorded from.
+func (t *statusTracker) setDevice(device string) {
+	t.update(func(s *statusSnapshot) {
+		s.Device = device
+	})
+}
+
+// markInserted records that the text of a recording is about to be typed.
+// It returns false if that already happened.
+func (t *statusTracker) markInserted(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if slices.Contains(t.inserted, id) {
+		return false
+	}
+	t.inserted = append(t.inserted, id)
+	if len(t.inserted) > maxInsertedIDs {
+		t.inserted = t.inserted[1:]
+	}
+	return true
+}
+
+func (t *statusTracker) set(state dictationState) {
+	t.update(func(s *statusSnapshot) {
+		s.State = state
+		s.RetryAt = nil
+	})
+}
+
+func (t *statusTracker) rateLimited(retryAt time.Time) {
+	t.update(func(s *statusSnapshot) {
+		s.State = stateRateLimited
+		s.RetryAt = &retryAt
+	})
+}
+
+// finish marks the end of a successful dictation.
+func (t *statusTracker) finish(text string) {
+	cue(cueDone)
+	t.update(func(s *statusSnapshot) {
+		now := time.Now()
+		s.State = stateIdle
+		s.RetryAt = nil
+		s.LastText = text
+		s.LastTranscriptionAt = &now
+	})
+}
+
+func (t *statusTracker) fail(err error) {
+	cue(cueError)
+	t.update(func(s *statusSnapshot) {
+		s.State = stateIdle
+		s.RetryAt = nil
+		s.LastError = err.Error()
+	})
+}
+
+func (t *statusTracker) update(fn func(*statusSnapshot)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fn(&t.current)
+	if t.current.State != stateRecording {
+		t.current.RecordingSince = nil
+	}
+	t.current.UpdatedAt = time.Now()
+
+	for ch := range t.subscribers {
+		select {
+		case <-ch: // drop the stale update nobody read yet
+		default:
+		}
+		ch <- t.current
+	}
+}
+
+// subscribe returns a channel that receives the current status immediately
+// and then every change, until cancel is called.
+func (t *statusTracker) subscribe() (updates <-chan statusSnapshot, cancel func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ch := make(chan statusSnapshot, 1)
+	ch <- t.current
+	t.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.subscribers, ch)
+	}
+}
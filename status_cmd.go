@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// runStatusCommand prints the state of the running instance once, as text
+// or, for scripts and status bars, as JSON.
+func runStatusCommand(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the status as JSON")
+	debug := flags.Bool("debug", false, "also print memory use")
+	flags.Parse(args)
+
+	client, err := controlClient()
+	var s controlStatus
+	if err == nil {
+		s, err = fetchControlStatus(client)
+	}
+	if err != nil {
+		if *asJSON {
+			// Still valid JSON for the script, with the error on stderr.
+			fmt.Println(`{"state":"not_running"}`)
+		}
+		return err
+	}
+
+	if !*asJSON {
+		printStatus(s)
+		if *debug && s.Memory != nil {
+			printMemory(*s.Memory)
+		}
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encoding status: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printMemory prints the memory numbers of dictation status -debug.
+func printMemory(m memoryStats) {
+	mb := func(n int64) string { return fmt.Sprintf("%.1f MB", float64(n)/(1<<20)) }
+	fmt.Printf("Heap:               %s (peak %s)\n", mb(int64(m.Heap)), mb(int64(m.PeakHeap)))
+	fmt.Printf("From the system:    %s\n", mb(int64(m.Sys)))
+	fmt.Printf("Largest recording:  %s\n", mb(m.PeakRecording))
+	fmt.Printf("GC runs:            %d\n", m.GCs)
+}
+
+func printStatus(s controlStatus) {
+	state := string(s.State)
+	if s.State == stateRecording {
+		state += " " + s.elapsed()
+	}
+	fmt.Printf("State:              %s\n", state)
+	if s.BlockedApp != "" {
+		fmt.Printf("Blocked in:         %s\n", s.BlockedApp)
+	}
+	if s.DisabledUntil != nil {
+		fmt.Printf("Triggers off:       %s\n", disabledMessage(s.Disabled, *s.DisabledUntil))
+	}
+	if s.Profile != "" {
+		fmt.Printf("Profile:            %s\n", s.Profile)
+	}
+	if s.SelectedProfile != "" && s.SelectedProfile != defaultProfile {
+		fmt.Printf("Toggle profile:     %s\n", s.SelectedProfile)
+	}
+	if s.Backend != "" {
+		fmt.Printf("Backend:            %s (dictation backend)\n", s.Backend)
+	}
+	if s.Device != "" {
+		fmt.Printf("Input device:       %s\n", s.Device)
+	}
+	last := "none yet"
+	if s.LastTranscriptionAt != nil {
+		last = fmt.Sprintf("%s ago", time.Since(*s.LastTranscriptionAt).Round(time.Second))
+	}
+	fmt.Printf("Last transcription: %s\n", last)
+	fmt.Printf("Queued requests:    %d\n", s.Queue)
+	if s.LastError != "" {
+		fmt.Printf("Last error:         %s\n", s.LastError)
+	}
+	if s.AudioProblem != "" {
+		fmt.Printf("Audio problem:      %s\n", s.AudioProblem)
+	}
+	for _, name := range sortedKeys(s.ProviderProblems) {
+		fmt.Printf("Provider problem:   %s: %s\n", name, s.ProviderProblems[name])
+	}
+	if s.UpdateAvailable != "" {
+		fmt.Printf("Update available:   %s\n", s.UpdateAvailable)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Exit codes of dictation statusline, so a status bar script can branch on
+// the state without parsing the line.
+const (
+	statuslineBusy       = 0 // recording or transcribing
+	statuslineIdle       = 1
+	statuslineNotRunning = 2
+)
+
+// runStatuslineCommand prints the state of the running instance as one
+// short line for status bars (SketchyBar, i3blocks, tmux...). With -follow
+// it keeps running and prints a new line whenever the state changes, and
+// every second while recording for the timer.
+func runStatuslineCommand(args []string) error {
+	flags := flag.NewFlagSet("statusline", flag.ExitOnError)
+	emoji := flags.Bool("emoji", false, "use emoji instead of words")
+	color := flags.Bool("color", false, "color the line with ANSI escape codes")
+	follow := flags.Bool("follow", false, "keep running and print a line on every change")
+	flags.Parse(args)
+
+	render := func(s *controlStatus) string {
+		line, _ := statusLine(s, *emoji, *color)
+		return line
+	}
+
+	if !*follow {
+		var s *controlStatus
+		if client, err := controlClient(); err == nil {
+			if current, err := fetchControlStatus(client); err == nil {
+				s = &current
+			}
+		}
+		line, code := statusLine(s, *emoji, *color)
+		fmt.Println(line)
+		if code != statuslineBusy {
+			os.Exit(code)
+		}
+		return nil
+	}
+
+	last := ""
+	show := func(line string) {
+		if line != last {
+			fmt.Println(line)
+			last = line
+		}
+	}
+	for {
+		client, err := controlClient()
+		var updates <-chan controlStatus
+		var stop func()
+		if err == nil {
+			updates, stop, err = watchControlStatus(client)
+		}
+		if err != nil {
+			// Not running (yet); keep showing that and try again.
+			show(render(nil))
+			time.Sleep(2 * time.Second)
+			continue
+		}
+
+		ticker := time.NewTicker(time.Second)
+		var current *controlStatus
+	stream:
+		for {
+			select {
+			case s, ok := <-updates:
+				if !ok {
+					break stream
+				}
+				current = &s
+			case <-ticker.C:
+				if current == nil {
+					continue
+				}
+			}
+			show(render(current))
+		}
+		ticker.Stop()
+		stop()
+		show(render(nil))
+	}
+}
+
+// statusLine renders a status, nil when dictation isn't running, and
+// returns the exit code for it.
+func statusLine(s *controlStatus, emoji, color bool) (string, int) {
+	const (
+		red    = "\x1b[31m"
+		yellow = "\x1b[33m"
+		dim    = "\x1b[2m"
+	)
+	var text, icon, ansi string
+	code := statuslineIdle
+	switch {
+	case s == nil:
+		text, icon, ansi, code = "off", "⚪", dim, statuslineNotRunning
+	case s.State == stateRecording:
+		text, icon, ansi, code = "REC "+s.elapsed(), "🔴 "+s.elapsed(), red, statuslineBusy
+	case s.State == stateTranscribing:
+		text, icon, ansi, code = "transcribing", "⏳", yellow, statuslineBusy
+	case s.State == stateRateLimited:
+		wait := ""
+		if s.RetryAt != nil {
+			wait = fmt.Sprintf(" %ds", max(0, int(time.Until(*s.RetryAt).Round(time.Second).Seconds())))
+		}
+		text, icon, ansi, code = "waiting"+wait, "⏳"+wait, yellow, statuslineBusy
+	case s.BlockedApp != "":
+		text, icon, ansi = "blocked", "🚫", dim
+	case s.DisabledUntil != nil:
+		until := s.DisabledUntil.Format("15:04")
+		text, icon, ansi = "off until "+until, "🌙 "+until, dim
+	case s.LastError != "" || len(s.ProviderProblems) > 0 || s.AudioProblem != "":
+		text, icon, ansi = "error", "⚠️", yellow
+	default:
+		text, icon, ansi = "idle", "🎤", dim
+	}
+
+	line := text
+	if emoji {
+		line = icon
+	}
+	if color {
+		line = ansi + line + "\x1b[0m"
+	}
+	return line, code
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// deckStatus is the status payload of the Stream Deck API. On top of the raw
+// state it carries ready-made values for a button face so plugins don't have
+// to map states themselves. Fields are only ever added, never renamed.
+type deckStatus struct {
+	statusSnapshot
+	// Icon is one of "idle", "recording", "transcribing", "rate_limited",
+	// "error" or "blocked".
+	Icon  string `json:"icon"`
+	Title string `json:"title"`
+	Color string `json:"color"`
+}
+
+func newDeckStatus(s statusSnapshot) deckStatus {
+	d := deckStatus{statusSnapshot: s, Icon: string(s.State)}
+	switch s.State {
+	case stateRecording:
+		d.Title, d.Color = "REC", "#d62d20"
+		if s.RecordingSince != nil {
+			d.Title = s.elapsed()
+		}
+	case stateTranscribing:
+		d.Title, d.Color = "...", "#f4a300"
+	case stateRateLimited:
+		d.Title, d.Color = "WAIT", "#8e44ad"
+		if s.RetryAt != nil {
+			d.Title = fmt.Sprintf("%ds", int(time.Until(*s.RetryAt).Round(time.Second).Seconds()))
+		}
+	default:
+		d.Title, d.Color = "", "#2b2b2b"
+		if s.LastError != "" || s.AudioProblem != "" {
+			d.Icon, d.Title, d.Color = "error", "ERR", "#7a1f1f"
+		}
+		if s.DisabledUntil != nil {
+			d.Icon, d.Title, d.Color = "paused", s.DisabledUntil.Format("15:04"), "#555555"
+		}
+		if s.BlockedApp != "" {
+			d.Icon, d.Title, d.Color = "blocked", "OFF", "#555555"
+		}
+	}
+	return d
+}
+
+// startStreamDeckServer serves the Stream Deck API on addr until ctx is done.
+// See the README for the contract.
+func startStreamDeckServer(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /streamdeck/status", handleDeckStatus)
+	mux.HandleFunc("POST /streamdeck/toggle", handleDeckToggle)
+	mux.HandleFunc("GET /streamdeck/events", handleDeckEvents)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", addr, err)
+	}
+
+	server := &http.Server{Handler: refuseBrowsers(addr, mux), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Stream Deck server stopped: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("Stream Deck API listening on http://%s/streamdeck\n", listener.Addr())
+	return nil
+}
+
+// refuseBrowsers turns away requests made by web pages. The API has no
+// authentication, and any site could otherwise post to it and start
+// recording the microphone, or read the last dictation from the status;
+// plugins and scripts make none of these requests. Cross-origin requests
+// carry an Origin header. Same-origin ones from a page whose domain was
+// rebound to this machine don't, but their Host is that domain, while
+// plugins use the address they connect to.
+func refuseBrowsers(addr string, h http.Handler) http.Handler {
+	listenHost, _, _ := net.SplitHostPort(addr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" || !allowedHost(r.Host, listenHost) {
+			http.Error(w, "requests from web pages aren't allowed", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether the Host of a request names this machine:
+// localhost, a loopback address, or the address listened on. Listening on
+// all interfaces, any IP address is allowed; domain names never are, as
+// those are what DNS rebinding uses.
+func allowedHost(host, listenHost string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || host == listenHost {
+		return true
+	}
+	listen := net.ParseIP(listenHost)
+	return listenHost == "" || listen != nil && listen.IsUnspecified()
+}
+
+func handleDeckStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, newDeckStatus(status.snapshot()))
+}
+
+func handleDeckToggle(w http.ResponseWriter, r *http.Request) {
+	select {
+	case toggleRequests <- "Stream Deck toggle":
+	default:
+		// A toggle is already queued; a second one would undo it.
+	}
+	writeJSON(w, http.StatusAccepted, newDeckStatus(status.snapshot()))
+}
+
+// handleDeckEvents streams status changes as server-sent events, starting
+// with the current status.
+func handleDeckEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	updates, cancel := status.subscribe()
+	defer cancel()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case s := <-updates:
+			data, err := json.Marshal(newDeckStatus(s))
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// streamingSampleRate is what faster-whisper-server expects: 16 kHz
+	// mono, signed 16 bit little endian PCM.
+	streamingSampleRate = 16000
+
+	// streamingFinishTimeout bounds the wait for the final transcript after
+	// the recording stopped.
+	streamingFinishTimeout = 15 * time.Second
+)
+
+// streamSession streams audio to a faster-whisper-server websocket while
+// recording and collects the transcript it sends back. The server sends the
+// whole transcript so far with each message, so partials just replace each
+// other.
+type streamSession struct {
+	conn      *websocket.Conn
+	params    TranscriptionParams
+	resampler *resampler
+	audio     chan []byte
+	sent      chan struct{}
+	received  chan struct{}
+
+	mu   sync.Mutex
+	text string
+	err  error
+}
+
+func openStreamSession(provider Provider, profile Profile) (*streamSession, error) {
+	u, err := url.Parse(provider.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing provider url: %w", err)
+	}
+	q := u.Query()
+	q.Set("model", profile.Model)
+	if profile.Params.Language != "" {
+		q.Set("language", profile.Params.Language)
+	}
+	if profile.Params.Prompt != "" {
+		q.Set("prompt", profile.Params.Prompt)
+	}
+	if profile.Params.Temperature != nil {
+		q.Set("temperature", strconv.FormatFloat(*profile.Params.Temperature, 'f', -1, 64))
+	}
+	if profile.Params.ResponseFormat != "" {
+		q.Set("response_format", profile.Params.ResponseFormat)
+	}
+	u.RawQuery = q.Encode()
+
+	wsConfig, err := websocket.NewConfig(u.String(), "http://localhost/")
+	if err != nil {
+		return nil, fmt.Errorf("configuring websocket: %w", err)
+	}
+	provider.setAuthHeaders(wsConfig.Header)
+	if wsConfig.TlsConfig, err = provider.TLS.load(); err != nil {
+		return nil, err
+	}
+
+	// Opening the connection counts as a request; the session itself isn't
+	// limited once it's running.
+	release := limiter.acquire()
+	conn, err := websocket.DialConfig(wsConfig)
+	release()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", u.Host, err)
+	}
+
+	s := &streamSession{
+		conn:      conn,
+		params:    profile.Params,
+		resampler: newResampler(sampleRate, streamingSampleRate),
+		audio:     make(chan []byte, 64),
+		sent:      make(chan struct{}),
+		received:  make(chan struct{}),
+	}
+	go s.sendLoop()
+	go s.receiveLoop()
+	return s, nil
+}
+
+// send queues captured samples for upload. It never blocks the capture loop;
+// if the connection can't keep up the audio is dropped and reported.
+func (s *streamSession) send(samples []float32) {
+	resampled := s.resampler.process(samples, nil)
+
+	pcm := make([]byte, 2*len(resampled))
+	for i, v := range resampled {
+		v = max(-1, min(1, v))
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(int16(math.Round(float64(v)*32767))))
+	}
+
+	select {
+	case s.audio <- pcm:
+	default:
+		fmt.Println("Warning: streaming connection too slow, dropped audio")
+	}
+}
+
+func (s *streamSession) sendLoop() {
+	defer close(s.sent)
+	for pcm := range s.audio {
+		if err := websocket.Message.Send(s.conn, pcm); err != nil {
+			s.setErr(fmt.Errorf("sending audio: %w", err))
+			return
+		}
+	}
+}
+
+func (s *streamSession) receiveLoop() {
+	defer close(s.received)
+	for {
+		var msg string
+		if err := websocket.Message.Receive(s.conn, &msg); err != nil {
+			// The server closes the connection after sending the final
+			// transcript; that's the normal way for this loop to end.
+			return
+		}
+
+		text, err := s.decode(msg)
+		if err != nil {
+			s.setErr(err)
+			return
+		}
+
+		s.mu.Lock()
+		s.text = text
+		s.mu.Unlock()
+		fmt.Printf("\r... %s", logged(text))
+	}
+}
+
+func (s *streamSession) decode(msg string) (string, error) {
+	switch s.params.ResponseFormat {
+	case "", "json", "verbose_json":
+		var result struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(msg), &result); err != nil {
+			return "", fmt.Errorf("decoding transcript: %w", err)
+		}
+		return result.Text, nil
+	default:
+		return strings.TrimSpace(msg), nil
+	}
+}
+
+func (s *streamSession) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// finish waits for the last audio to go out and for the server to send its
+// final transcript, then closes the connection.
+func (s *streamSession) finish() (string, error) {
+	close(s.audio)
+	<-s.sent
+
+	select {
+	case <-s.received:
+	case <-time.After(streamingFinishTimeout):
+		fmt.Println("\nWarning: timed out waiting for the final transcript, using the last partial")
+	}
+	s.conn.Close()
+	fmt.Println()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.text, s.err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	// A supervised part that panics is restarted after restartDelay, twice
+	// as long after every crash up to maxRestartDelay. One that ran for
+	// stableRun before crashing starts over at restartDelay.
+	restartDelay    = time.Second
+	maxRestartDelay = time.Minute
+	stableRun       = time.Minute
+	// maxCrashReports is how many crash reports are kept.
+	maxCrashReports = 20
+)
+
+// crashed reports a recovered panic of part: it is logged and written to a
+// crash report in the crashes folder of the log directory, with the stack
+// of the goroutine that panicked. Call it from the deferred func that
+// recovered, while that stack is still there. It returns the panic as an
+// error.
+func crashed(part string, r any) error {
+	stack := debug.Stack()
+	err := fmt.Errorf("%s crashed: %v", part, r)
+	fmt.Printf("Error: %v\n", err)
+
+	path, werr := writeCrashReport(part, r, stack)
+	if werr != nil {
+		fmt.Printf("Warning: failed to write the crash report: %v\n%s", werr, stack)
+		return err
+	}
+	fmt.Printf("Crash report written to %s\n", path)
+	return err
+}
+
+func writeCrashReport(part string, r any, stack []byte) (string, error) {
+	dir, err := logDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "crashes")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("creating %s: %w", dir, err)
+	}
+
+	now := time.Now()
+	var b strings.Builder
+	fmt.Fprintf(&b, "dictation %s crashed at %s\n", part, now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "version %s, %s, %s/%s\n\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "panic: %v\n\n%s", r, stack)
+
+	name := fmt.Sprintf("%s-%s.txt", now.Format("20060102-150405"), strings.ReplaceAll(part, " ", "-"))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	// The names sort by time, the oldest go first.
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > maxCrashReports {
+		for _, e := range entries[:len(entries)-maxCrashReports] {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	return path, nil
+}
+
+// protect runs fn, and recovers and reports a panic in it instead of letting
+// it take the process down. It returns the panic as an error. For one-off
+// work, like a dictation, that isn't restarted.
+func protect(part string, fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = crashed(part, r)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// supervise runs fn until it returns or ctx is done, restarting it when it
+// panics. The state it works on lives outside of it (the status, the
+// history, the config), so a restarted part carries on where it was.
+func supervise(ctx context.Context, part string, fn func(ctx context.Context)) {
+	delay := restartDelay
+	for {
+		started := time.Now()
+		err := protect(part, func() { fn(ctx) })
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= stableRun {
+			delay = restartDelay
+		}
+		notify(tr("Dictation recovered from a crash"), tr("The %s crashed and is restarted", part))
+		fmt.Printf("Restarting the %s in %s\n", part, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRestartDelay)
+	}
+}
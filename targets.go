@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Target types, where a dictation sent to a named target goes.
+const (
+	targetApp       = "app" // typed into the focused app, as usual
+	targetClipboard = "clipboard"
+	targetFile      = "file" // appended to a file
+)
+
+// TargetConfig is a named place dictations can be sent to instead of the
+// focused app, with a hotkey's or profile's target or by starting the
+// dictation with "send to <name>".
+type TargetConfig struct {
+	// Type is "app", "clipboard" or "file".
+	Type string `json:"type"`
+	// Path is the file of a "file" target. A leading ~ is the home
+	// directory, and {date} (2006-01-02) and the other time placeholders
+	// are filled in, for daily notes.
+	Path string `json:"path"`
+	// Template formats what is sent here, after the profile's template.
+	Template string `json:"template"`
+}
+
+// spokenTargetPrefix is how a dictation names its target: "send to notes,
+// buy milk". Whisper punctuates it in all sorts of ways.
+var spokenTargetPrefix = regexp.MustCompile(`(?i)^\s*send (?:this )?to (?:the |my )?(.+)$`)
+
+// spokenTarget splits "send to <name>," off the start of a transcription,
+// for one of the configured targets, or the same in the locale's language.
+// Longer names are tried first, so "daily note" wins over "daily".
+func spokenTarget(text string) (name, rest string, ok bool) {
+	m := spokenTargetPrefix.FindStringSubmatch(text)
+	if l, ok := locales[activeLocale()]; ok && m == nil {
+		m = l.SendTo.FindStringSubmatch(text)
+	}
+	if m == nil {
+		return "", text, false
+	}
+	names := make([]string, 0, len(config.Targets))
+	for n := range config.Targets {
+		names = append(names, n)
+	}
+	slices.SortFunc(names, func(a, b string) int { return len(b) - len(a) })
+	for _, n := range names {
+		if len(m[1]) < len(n) || !strings.EqualFold(m[1][:len(n)], n) {
+			continue
+		}
+		// The name has to be followed by punctuation, the pause after
+		// it: "send this to the app store team" stays a dictation.
+		after := strings.TrimLeft(m[1][len(n):], " ")
+		trimmed := strings.TrimLeft(after, " ,.:;!-")
+		if trimmed == "" || trimmed == after {
+			continue
+		}
+		return n, capitalizeFirst(trimmed), true
+	}
+	return "", text, false
+}
+
+// sendToTarget delivers a dictation to a named target. It reports false for
+// an "app" target, which the caller inserts as usual.
+func sendToTarget(name, text string) (bool, error) {
+	t := config.Targets[name]
+	switch t.Type {
+	case targetClipboard:
+		if err := injector.WriteClipboard(text); err != nil {
+			return true, fmt.Errorf("copying to the clipboard: %w", err)
+		}
+		fmt.Println("Copied to the clipboard")
+		notify(tr("Dictation"), tr("Copied to the clipboard"))
+		return true, nil
+	case targetFile:
+		path, err := targetPath(t.Path, time.Now())
+		if err != nil {
+			return true, err
+		}
+		if err := appendToFile(path, text); err != nil {
+			return true, err
+		}
+		fmt.Printf("Appended to %s\n", path)
+		notify(tr("Dictation"), tr("Sent to %s", name))
+		return true, nil
+	}
+	return false, nil
+}
+
+// targetPath expands ~ and the time placeholders in the path of a file
+// target.
+func targetPath(path string, now time.Time) (string, error) {
+	return expandHome(expandTime(path, now))
+}
+
+// appendToFile adds text as a line of its own at the end of a file, creating
+// it and its directory if needed.
+func appendToFile(path, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// Start on a new line if the file doesn't end with one.
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			text = "\n" + text
+		}
+	}
+	if _, err := f.WriteString(text + "\n"); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
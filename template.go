@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateData is what an output template can use: {{.Text}} is the
+// dictation, the rest is context.
+type templateData struct {
+	Text    string
+	Profile string
+	Target  string
+	App     string
+	Time    time.Time
+}
+
+// templateFuncs are helpers for output templates besides Go's builtins.
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	// quote makes a Markdown block quote: "> " in front of every line.
+	"quote": func(s string) string {
+		return "> " + strings.ReplaceAll(s, "\n", "\n> ")
+	},
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	// cursor marks where after_insert's cursor action puts the cursor.
+	"cursor": func() string { return cursorMarker },
+}
+
+// parseTemplate parses an output template; name is the setting, its errors
+// start with it.
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// applyTemplate formats a dictation with an output template, such as a
+// profile's or a target's: `"{{.Text}}" — me` or `{{quote .Text}}`. An empty
+// template leaves the text alone, and so does one that fails, with a
+// warning rather than losing the dictation.
+func applyTemplate(name, tmpl string, data templateData) string {
+	if tmpl == "" || strings.TrimSpace(data.Text) == "" {
+		return data.Text
+	}
+	t, err := parseTemplate(name, tmpl)
+	if err == nil {
+		var out bytes.Buffer
+		if err = t.Execute(&out, data); err == nil {
+			return out.String()
+		}
+	}
+	fmt.Printf("Warning: %v, left the text as it was\n", err)
+	return data.Text
+}
+
+// checkTemplate reports a template that doesn't parse or can't be filled
+// in, at startup instead of on the first dictation.
+func checkTemplate(name, tmpl string) error {
+	if tmpl == "" {
+		return nil
+	}
+	t, err := parseTemplate(name, tmpl)
+	if err != nil {
+		return err
+	}
+	return t.Execute(&bytes.Buffer{}, templateData{Text: "test", Time: time.Now()})
+}
+
+// formatOutput applies the profile's output template and then the
+// target's, if the dictation goes to one.
+func formatOutput(profile Profile, target, text string) string {
+	targetTemplate := config.Targets[target].Template
+	if profile.Template == "" && targetTemplate == "" {
+		return text
+	}
+	data := templateData{Text: text, Profile: profile.Name, Target: target, App: frontmostApp(), Time: time.Now()}
+	data.Text = applyTemplate("template", profile.Template, data)
+	if target != "" {
+		data.Text = applyTemplate("targets."+target+".template", targetTemplate, data)
+	}
+	return data.Text
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// terminal puts the controlling terminal into raw mode for the dashboard.
+// stty is used so no terminal library is needed for it; the history browser
+// is built on bubbletea.
+type terminal struct {
+	saved string
+}
+
+func openTerminal() (*terminal, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("not a terminal: %w", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	// Alternate screen, hidden cursor.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	return &terminal{saved: strings.TrimSpace(saved)}, nil
+}
+
+func (t *terminal) close() {
+	fmt.Print("\x1b[?25h\x1b[?1049l")
+	stty(t.saved)
+}
+
+// size returns the number of rows and columns, with a fallback for when it
+// can't be determined.
+func (t *terminal) size() (rows, cols int) {
+	out, err := stty("size")
+	if err == nil {
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return rows, cols
+		}
+	}
+	return 24, 80
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Keys returned by readKeys besides plain characters.
+const (
+	keyUp    = "up"
+	keyDown  = "down"
+	keyEnter = "enter"
+	keyEsc   = "esc"
+	keyBack  = "backspace"
+	keyCtrlC = "ctrl+c"
+)
+
+// readKeys reads key presses from stdin until it is closed. Arrow keys and
+// the other special keys are translated to the constants above.
+func readKeys() <-chan string {
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, k := range parseKeys(buf[:n]) {
+				keys <- k
+			}
+		}
+	}()
+	return keys
+}
+
+func parseKeys(b []byte) []string {
+	var keys []string
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == 0x1b && i+2 < len(b) && b[i+1] == '[':
+			switch b[i+2] {
+			case 'A':
+				keys = append(keys, keyUp)
+			case 'B':
+				keys = append(keys, keyDown)
+			}
+			i += 2
+		case c == 0x1b:
+			keys = append(keys, keyEsc)
+		case c == '\r' || c == '\n':
+			keys = append(keys, keyEnter)
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, keyBack)
+		case c == 0x03:
+			keys = append(keys, keyCtrlC)
+		default:
+			// Multi-byte UTF-8 characters come through whole.
+			r := []rune(string(b[i:]))[0]
+			keys = append(keys, string(r))
+			i += len(string(r)) - 1
+		}
+	}
+	return keys
+}
+
+// truncate shortens s to at most width runes.
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	if width <= 1 {
+		return string(r[:width])
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// wrap breaks text into lines of at most width runes, at spaces.
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracing records the steps of a dictation (capture, encode, upload,
+// post-processing, insertion) as OpenTelemetry spans and exports them to an
+// OTLP/HTTP collector (Jaeger, Tempo, the OpenTelemetry Collector...) in
+// the OTLP JSON encoding. Uploads carry a W3C traceparent header so the
+// spans of an inference server that is traced too end up in the same trace.
+//
+// A nil *span is valid and does nothing, which is what startTrace returns
+// with tracing off, so callers don't have to check.
+
+// span is one timed step of a trace.
+type span struct {
+	name     string
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs map[string]any
+}
+
+// startTrace starts the root span of a new trace, or returns nil when
+// tracing is off.
+func startTrace(name string) *span {
+	if config.Tracing.Endpoint == "" {
+		return nil
+	}
+	s := &span{name: name, start: time.Now(), attrs: map[string]any{}}
+	rand.Read(s.traceID[:])
+	rand.Read(s.spanID[:])
+	return s
+}
+
+// child starts a span inside s.
+func (s *span) child(name string) *span {
+	if s == nil {
+		return nil
+	}
+	c := &span{name: name, traceID: s.traceID, parentID: s.spanID, start: time.Now(), attrs: map[string]any{}}
+	rand.Read(c.spanID[:])
+	return c
+}
+
+// set adds an attribute. Values are strings, ints, floats or bools.
+func (s *span) set(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs[key] = value
+	s.mu.Unlock()
+}
+
+// end finishes the span, failed if err isn't nil, and queues it for export.
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              1, // internal
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes:        otlpAttributes(s.attrs),
+	}
+	if s.parentID != ([8]byte{}) {
+		o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if err != nil {
+		o.Status = &otlpStatus{Code: 2, Message: err.Error()}
+	}
+	traces.add(o)
+}
+
+// traceparent returns the W3C traceparent header value for requests made
+// within the span.
+func (s *span) traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%x-%x-01", s.traceID, s.spanID)
+}
+
+// The OTLP JSON encoding of spans.
+type (
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+func otlpAttributes(attrs map[string]any) []otlpAttribute {
+	var out []otlpAttribute
+	for k, v := range attrs {
+		var value map[string]any
+		switch v := v.(type) {
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpAttribute{Key: k, Value: value})
+	}
+	return out
+}
+
+// traceExporter batches ended spans and posts them to the collector every
+// few seconds, so a dictation doesn't wait for it.
+type traceExporter struct {
+	mu      sync.Mutex
+	pending []otlpSpan
+	timer   *time.Timer
+}
+
+// traceExportDelay is how long ended spans are held to be sent together.
+const traceExportDelay = 2 * time.Second
+
+var traces = &traceExporter{}
+
+func (e *traceExporter) add(s otlpSpan) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending = append(e.pending, s)
+	if e.timer == nil {
+		e.timer = time.AfterFunc(traceExportDelay, e.flush)
+	}
+}
+
+// flush sends the pending spans. Failures are printed and the spans
+// dropped; tracing must never get in the way of dictation.
+func (e *traceExporter) flush() {
+	e.mu.Lock()
+	spans := e.pending
+	e.pending, e.timer = nil, nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	if err := exportSpans(spans); err != nil {
+		fmt.Printf("Warning: exporting traces failed: %v\n", err)
+	}
+}
+
+func exportSpans(spans []otlpSpan) error {
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{
+					"service.name":    "dictation",
+					"service.version": version,
+				}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "dictation"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	url := strings.TrimSuffix(config.Tracing.Endpoint, "/") + "/v1/traces"
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range config.Tracing.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transcribeAudio transcribes an audio file with a profile. The file is left
+// in place. trace is the span the request is made in, nil when not tracing.
+// The request is added to the usage with audio as its length, unless the
+// cache answered it, which costs nothing, or the length isn't known (0).
+func transcribeAudio(audioFilePath string, profile Profile, audio time.Duration, trace *span) (string, error) {
+	provider := config.provider(profile)
+	if !config.Cache.Enabled {
+		return requestCharged(audioFilePath, provider, profile, audio, trace)
+	}
+
+	key, err := transcriptCacheKey(audioFilePath, provider, profile)
+	if err != nil {
+		return "", err
+	}
+	if text, ok := cachedTranscript(key); ok {
+		fmt.Println("Same audio was transcribed before, using the cached result")
+		trace.set("cache.hit", true)
+		return text, nil
+	}
+	text, err := requestCharged(audioFilePath, provider, profile, audio, trace)
+	if err != nil {
+		return "", err
+	}
+	storeTranscript(key, text)
+	return text, nil
+}
+
+// requestCharged is requestTranscription, adding the request to the usage.
+func requestCharged(audioFilePath string, provider Provider, profile Profile, audio time.Duration, trace *span) (string, error) {
+	text, err := requestTranscription(audioFilePath, provider, profile, trace)
+	if err == nil && audio > 0 {
+		usage.add(profile, audio)
+	}
+	return text, err
+}
+
+// requestTranscription has the provider transcribe an audio file.
+func requestTranscription(audioFilePath string, provider Provider, profile Profile, trace *span) (string, error) {
+	if len(profile.Bilingual) > 0 {
+		return requestBilingual(audioFilePath, provider, profile, trace)
+	}
+	text, _, err := requestTranscript(audioFilePath, provider, profile, trace)
+	return text, err
+}
+
+// requestTranscript is requestTranscription without the bilingual handling.
+// It also returns the language the provider detected, which only comes with
+// the verbose_json response format.
+func requestTranscript(audioFilePath string, provider Provider, profile Profile, trace *span) (text, language string, err error) {
+	if provider.local() {
+		text, err := transcribeLocal(audioFilePath, provider, profile)
+		return text, "", err
+	}
+
+	file, err := os.Open(audioFilePath)
+	if err != nil {
+		return "", "", fmt.Errorf("opening audio file: %w", err)
+	}
+	defer file.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", audioFilePath)
+	if err != nil {
+		return "", "", fmt.Errorf("creating form file: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return "", "", fmt.Errorf("copying file to form: %w", err)
+	}
+
+	if err := writer.WriteField("model", profile.Model); err != nil {
+		return "", "", fmt.Errorf("writing model field: %w", err)
+	}
+	if err := profile.Params.writeFields(writer); err != nil {
+		return "", "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", "", fmt.Errorf("closing multipart writer: %w", err)
+	}
+
+	retriedKey := false
+	for attempt := 1; ; attempt++ {
+		trace.set("attempts", attempt)
+		text, language, err := sendTranscription(provider, profile, body.Bytes(), writer.FormDataContentType(), trace)
+
+		var rateLimited *rateLimitError
+		if errors.As(err, &rateLimited) && apiKeys.rateLimited(profile.Provider, provider, rateLimited.key, rateLimited.retryAfter) {
+			fmt.Printf("%s rate limited the request, trying another API key\n", profile.Provider)
+			continue
+		}
+		var rejected *keyRejectedError
+		if errors.As(err, &rejected) && !retriedKey {
+			// The key may have been rotated: ask its command again.
+			retriedKey = true
+			rejected.key.forget()
+			continue
+		}
+		if errors.As(err, &rateLimited) && attempt < maxRateLimitAttempts {
+			wait := rateLimited.retryAfter
+			if wait <= 0 {
+				wait = min(time.Duration(1<<attempt)*time.Second, maxRateLimitWait)
+			}
+			waitForRateLimit(profile.Provider, wait)
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return text, language, nil
+	}
+}
+
+const (
+	// maxRateLimitAttempts is how often a rate limited request is tried
+	// before giving up.
+	maxRateLimitAttempts = 8
+	// maxRateLimitWait caps the wait between attempts when the provider
+	// doesn't say how long to wait.
+	maxRateLimitWait = time.Minute
+)
+
+// rateLimitError is returned for HTTP 429 responses. retryAfter is zero if
+// the provider didn't send a usable Retry-After header.
+type rateLimitError struct {
+	provider   string
+	key        int // index of the API key, see keyRing
+	retryAfter time.Duration
+	message    string
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limited the request: %s", e.provider, e.message)
+}
+
+// keyRejectedError is returned for HTTP 401 responses, when the API key
+// was wrong or revoked.
+type keyRejectedError struct {
+	key APIKey
+	err error
+}
+
+func (e *keyRejectedError) Error() string { return e.err.Error() }
+func (e *keyRejectedError) Unwrap() error { return e.err }
+
+// waitForRateLimit tells the user about the rate limit and sleeps until the
+// request can be retried. The audio stays on disk meanwhile.
+func waitForRateLimit(provider string, wait time.Duration) {
+	seconds := int(wait.Round(time.Second).Seconds())
+	fmt.Printf("%s rate limited the request, retrying in %ds\n", provider, seconds)
+	notify(tr("Dictation rate limited"), tr("%s is rate limiting, retrying in %ds", provider, seconds))
+
+	status.rateLimited(time.Now().Add(wait))
+	time.Sleep(wait)
+	status.set(stateTranscribing)
+}
+
+func sendTranscription(provider Provider, profile Profile, body []byte, contentType string, trace *span) (text, language string, err error) {
+	req, err := http.NewRequest("POST", provider.transcriptionURL(profile), bytes.NewReader(body))
+	if err != nil {
+		return "", "", fmt.Errorf("creating request: %w", err)
+	}
+
+	keyIndex, key := apiKeys.pick(profile.Provider, provider)
+	secret, err := key.lookup()
+	if err != nil {
+		return "", "", fmt.Errorf("API key %s: %w", key, err)
+	}
+	provider.setAuthKey(req.Header, secret)
+	provider.setAccountHeaders(req.Header)
+	req.Header.Set("Content-Type", contentType)
+	if tp := trace.traceparent(); tp != "" {
+		req.Header.Set("traceparent", tp)
+	}
+
+	release := limiter.acquire()
+	defer release()
+
+	client, err := provider.httpClient()
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", "", &rateLimitError{
+			provider:   profile.Provider,
+			key:        keyIndex,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			message:    string(bytes.TrimSpace(msg)),
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := fmt.Errorf("%s returned %s: %s", profile.Provider, resp.Status, bytes.TrimSpace(msg))
+		if resp.StatusCode == http.StatusUnauthorized {
+			return "", "", &keyRejectedError{key, err}
+		}
+		return "", "", err
+	}
+
+	return profile.Params.decodeResponse(resp.Body)
+}
+
+// parseRetryAfter understands both forms of the Retry-After header, a
+// number of seconds and an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// writeFields adds the optional request parameters. Unset ones are left out
+// so the provider's defaults apply.
+func (p TranscriptionParams) writeFields(writer *multipart.Writer) error {
+	fields := [][2]string{
+		{"language", p.Language},
+		{"prompt", p.Prompt},
+		{"response_format", p.ResponseFormat},
+	}
+	if p.Temperature != nil {
+		fields = append(fields, [2]string{"temperature", strconv.FormatFloat(*p.Temperature, 'f', -1, 64)})
+	}
+	if p.streams() {
+		fields = append(fields, [2]string{"stream", "true"})
+	}
+	for _, g := range p.TimestampGranularities {
+		fields = append(fields, [2]string{"timestamp_granularities[]", g})
+	}
+
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if err := writer.WriteField(f[0], f[1]); err != nil {
+			return fmt.Errorf("writing %s field: %w", f[0], err)
+		}
+	}
+	return nil
+}
+
+// decodeResponse extracts the transcription, and with verbose_json the
+// language the provider detected. The json formats wrap it in an object, the
+// others (text, srt, vtt) are the body as is.
+func (p TranscriptionParams) decodeResponse(body io.Reader) (text, language string, err error) {
+	if p.streams() {
+		text, err := decodeStream(body)
+		return text, "", err
+	}
+
+	switch p.ResponseFormat {
+	case "", "json", "verbose_json":
+		var result struct {
+			Text     string `json:"text"`
+			Language string `json:"language"`
+		}
+		if err := json.NewDecoder(body).Decode(&result); err != nil {
+			return "", "", fmt.Errorf("decoding response: %w", err)
+		}
+		return result.Text, result.Language, nil
+	default:
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return "", "", fmt.Errorf("reading response: %w", err)
+		}
+		return strings.TrimSpace(string(data)), "", nil
+	}
+}
+
+// decodeStream reads the server-sent events of a streaming transcription,
+// printing the text as it arrives, and returns the final transcript.
+func decodeStream(body io.Reader) (string, error) {
+	var partial strings.Builder
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+
+		var event struct {
+			Type  string `json:"type"`
+			Delta string `json:"delta"`
+			Text  string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return "", fmt.Errorf("decoding stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "transcript.text.delta":
+			// The live echo is dictated text, left out with privacy.logs off.
+			if config.Privacy.Logs {
+				fmt.Print(event.Delta)
+			}
+			partial.WriteString(event.Delta)
+		case "transcript.text.done":
+			if config.Privacy.Logs {
+				fmt.Println()
+			}
+			return event.Text, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("reading stream: %w", err)
+	}
+
+	// The stream ended without a done event; what arrived is all we have.
+	fmt.Println()
+	return partial.String(), nil
+}
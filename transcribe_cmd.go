@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runTranscribeCommand transcribes audio files, for recordings made
+// elsewhere (voice memos), to redo a transcription, or to work through a
+// whole folder of them. The text is printed, or with -out-dir written to one
+// file per recording; files already transcribed there are skipped, so an
+// interrupted batch can simply be run again. A file named - is read from
+// stdin.
+func runTranscribeCommand(args []string) error {
+	flags := flag.NewFlagSet("transcribe", flag.ExitOnError)
+	profileName := flags.String("profile", defaultProfile, "transcription profile to use")
+	jobs := flags.Int("jobs", 1, "number of files to transcribe at the same time")
+	outDir := flags.String("out-dir", "", "write a .txt file per recording to this folder instead of printing")
+	rate := flags.Int("rate", 16000, "sample rate of raw PCM piped to stdin")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dictation transcribe [-profile name] [-jobs n] [-out-dir folder] [-rate hz] file... (- for stdin)")
+		flags.PrintDefaults()
+	}
+	files := parseInterspersed(flags, args)
+
+	if len(files) == 0 {
+		flags.Usage()
+		return errors.New("no audio file given")
+	}
+	if *jobs < 1 {
+		return errors.New("-jobs must be at least 1")
+	}
+	if *rate < 1 {
+		return errors.New("-rate must be positive")
+	}
+
+	// Checked up front, and again for each file for the budget.
+	if _, err := fileProfile(*profileName); err != nil {
+		return err
+	}
+
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	b := &batch{profile: *profileName, outDir: *outDir, total: len(files), showPath: len(files) > 1, names: map[string]string{}}
+	for i, file := range files {
+		if file != "-" {
+			continue
+		}
+		if slices.Contains(files[i+1:], "-") {
+			return errors.New("stdin (-) can only be given once")
+		}
+		path, cleanup, err := stdinAudio(*rate)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		files[i] = path
+		b.names[path] = "stdin"
+	}
+	b.run(files, *jobs)
+
+	if b.failed > 0 {
+		return fmt.Errorf("%d of %d files failed", b.failed, len(files))
+	}
+	return nil
+}
+
+// parseInterspersed parses flags that come before, between or after the
+// positional arguments, which the flag package alone stops at.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// batch transcribes files with a number of workers. Per file errors are
+// reported and counted, they don't stop the others.
+type batch struct {
+	profile  string
+	outDir   string
+	total    int
+	showPath bool
+	names    map[string]string // what to call files, by path
+
+	mu                    sync.Mutex
+	done, failed, skipped int
+}
+
+func (b *batch) run(files []string, jobs int) {
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range queue {
+				b.process(path)
+			}
+		}()
+	}
+
+	b.progress()
+	for _, path := range files {
+		queue <- path
+	}
+	close(queue)
+	wg.Wait()
+
+	if b.outDir != "" {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "%d transcribed, %d skipped, %d failed\n", b.done-b.skipped-b.failed, b.skipped, b.failed)
+	}
+}
+
+func (b *batch) process(path string) {
+	name := path
+	if n, ok := b.names[path]; ok {
+		name = n
+	}
+	var out string
+	if b.outDir != "" {
+		out = filepath.Join(b.outDir, strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))+".txt")
+		// Piped audio is new every time, its name says nothing.
+		if _, err := os.Stat(out); err == nil && name == path {
+			b.finish(func() { b.skipped++ })
+			return
+		}
+	}
+
+	profile, err := fileProfile(b.profile)
+	var text string
+	if err == nil {
+		text, err = transcribeFile(path, profile)
+	}
+	if err == nil && out != "" {
+		err = os.WriteFile(out, []byte(text+"\n"), 0o644)
+	}
+
+	b.finish(func() {
+		if err != nil {
+			b.failed++
+			b.clearProgress()
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", name, err)
+			return
+		}
+		if out == "" {
+			if b.showPath {
+				fmt.Printf("%s: ", name)
+			}
+			fmt.Println(text)
+		}
+	})
+}
+
+// finish updates the counters under the lock and redraws the progress bar.
+func (b *batch) finish(update func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done++
+	update()
+	b.progress()
+}
+
+// progress draws a progress bar on stderr. Only used when results go to
+// files, printed transcripts would be interleaved with it.
+func (b *batch) progress() {
+	if b.outDir == "" {
+		return
+	}
+	const width = 30
+	filled := width * b.done / b.total
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %d/%d", strings.Repeat("=", filled), strings.Repeat(" ", width-filled), b.done, b.total)
+	if b.failed > 0 {
+		fmt.Fprintf(os.Stderr, " (%d failed)", b.failed)
+	}
+}
+
+func (b *batch) clearProgress() {
+	if b.outDir != "" {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
+
+// fileProfile looks up a profile for transcribing files and checks it can.
+// The budget is applied like for dictations, so the profile may be the
+// fallback one, and its preset is applied. Commands that transcribe many
+// files call it for each.
+func fileProfile(name string) (Profile, error) {
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	profile = applyBudget(profile)
+	provider := config.provider(profile)
+	if provider.Type == providerFasterWhisperWS {
+		return Profile{}, fmt.Errorf("profile %s streams audio and can't transcribe files", profile.Name)
+	}
+	if err := provider.checkAPIKey(); err != nil {
+		return Profile{}, fmt.Errorf("API key of provider %q: %w", profile.Provider, err)
+	}
+	return applyPreset(profile), nil
+}
+
+// transcribeFile transcribes an audio file in any format ffmpeg or
+// CoreAudio can read, converting it first if the provider can't take it.
+func transcribeFile(path string, profile Profile) (string, error) {
+	provider := config.provider(profile)
+
+	input, cleanup, err := prepareAudioFile(path, provider)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	d, err := probeDuration(input)
+	if err != nil {
+		// transcribeAudio doesn't record usage without a length.
+		d = 0
+		fmt.Fprintf(os.Stderr, "Warning: can't tell the length of %s, usage not recorded: %v\n", path, err)
+	}
+	started := time.Now()
+	text, err := transcribeAudio(input, profile, d, nil)
+	logTranscription(transcriptionEvent{File: path}, profile, d, time.Since(started), text, err)
+	if err != nil {
+		return "", err
+	}
+	return text, nil
+}
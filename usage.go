@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pricePerMinute holds the list price in USD per minute of audio of known
+// cloud models. Providers can set their own with price_per_minute.
+var pricePerMinute = map[string]float64{
+	"whisper-1":              0.006,
+	"gpt-4o-transcribe":      0.006,
+	"gpt-4o-mini-transcribe": 0.003,
+	"whisper-large-v3":       0.111 / 60,
+	"whisper-large-v3-turbo": 0.04 / 60,
+}
+
+// estimateCost returns the estimated cost of transcribing the given amount
+// of audio with a profile. Local providers are free.
+func estimateCost(profile Profile, audio time.Duration) float64 {
+	provider := config.Providers[profile.Provider]
+	if provider.local() {
+		return 0
+	}
+	price := pricePerMinute[profile.Model]
+	if provider.PricePerMinute != nil {
+		price = *provider.PricePerMinute
+	}
+	return price * audio.Minutes()
+}
+
+// dayUsage is the usage of one calendar day.
+type dayUsage struct {
+	Requests     int     `json:"requests"`
+	AudioSeconds float64 `json:"audio_seconds"`
+	Cost         float64 `json:"cost"`
+}
+
+// usageTracker keeps per day usage, persisted to a JSON file keyed by date,
+// so budgets survive restarts.
+type usageTracker struct {
+	mu   sync.Mutex
+	path string
+	Days map[string]*dayUsage `json:"days"`
+}
+
+var usage = &usageTracker{Days: map[string]*dayUsage{}}
+
+func usagePath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "usage.json"), nil
+}
+
+func loadUsage() (*usageTracker, error) {
+	path, err := usagePath()
+	if err != nil {
+		return nil, err
+	}
+	u := &usageTracker{path: path, Days: map[string]*dayUsage{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return u, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading usage: %w", err)
+	}
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil, fmt.Errorf("parsing usage %s: %w", path, err)
+	}
+	return u, nil
+}
+
+// add records a transcription and saves the usage file.
+func (u *usageTracker) add(profile Profile, audio time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	day := time.Now().Format(time.DateOnly)
+	d, ok := u.Days[day]
+	if !ok {
+		d = &dayUsage{}
+		u.Days[day] = d
+	}
+	d.Requests++
+	d.AudioSeconds += audio.Seconds()
+	d.Cost += estimateCost(profile, audio)
+
+	if err := u.save(); err != nil {
+		fmt.Printf("Warning: failed to save usage: %v\n", err)
+	}
+}
+
+func (u *usageTracker) save() error {
+	if u.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(u.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(u.path, data, 0o644)
+}
+
+// spent returns the estimated cost of today and of this month.
+func (u *usageTracker) spent() (today, month float64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	day := now.Format(time.DateOnly)
+	monthPrefix := now.Format("2006-01-")
+	for date, d := range u.Days {
+		if date == day {
+			today = d.Cost
+		}
+		if strings.HasPrefix(date, monthPrefix) {
+			month += d.Cost
+		}
+	}
+	return today, month
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// version and commit are set at build time, for example:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse --short HEAD)"
+//
+// Without them the commit is taken from the VCS information Go embeds.
+var (
+	version = "dev"
+	commit  = ""
+)
+
+const releasesURL = "https://api.github.com/repos/ashfame/dictation-whisper-api-macos/releases/latest"
+
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" && modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+func versionString() string {
+	s := "dictation " + version
+	if c := buildCommit(); c != "" {
+		s += " (" + c + ")"
+	}
+	return s + " " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH
+}
+
+func runVersionCommand(args []string) error {
+	fmt.Println(versionString())
+	if len(args) > 0 && args[0] == "-check" {
+		latest, err := latestRelease()
+		if err != nil {
+			return err
+		}
+		if newerVersion(latest, version) {
+			fmt.Printf("%s is available: https://github.com/ashfame/dictation-whisper-api-macos/releases\n", latest)
+		} else {
+			fmt.Println("Up to date.")
+		}
+	}
+	return nil
+}
+
+// updateAvailable holds the tag of a newer release once the update check
+// found one. It is shown on the status surfaces.
+var (
+	updateMu        sync.Mutex
+	updateAvailable string
+)
+
+func availableUpdate() string {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	return updateAvailable
+}
+
+// checkForUpdate looks up the latest release in the background and tells
+// the user if it is newer than this build. Development builds are never
+// checked, their version means nothing.
+func checkForUpdate() {
+	if version == "dev" {
+		return
+	}
+	go func() {
+		latest, err := latestRelease()
+		if err != nil {
+			fmt.Printf("Warning: update check failed: %v\n", err)
+			return
+		}
+		if !newerVersion(latest, version) {
+			return
+		}
+		updateMu.Lock()
+		updateAvailable = latest
+		updateMu.Unlock()
+		fmt.Printf("Dictation %s is available (you have %s)\n", latest, version)
+		notify(tr("Dictation update available"), tr("%s is available, you have %s", latest, version))
+	}()
+}
+
+func latestRelease() (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(releasesURL)
+	if err != nil {
+		return "", fmt.Errorf("checking for updates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("checking for updates: GitHub returned %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("decoding release: %w", err)
+	}
+	return release.TagName, nil
+}
+
+// newerVersion reports whether version a is newer than b. Both are
+// dotted numbers with an optional "v" prefix; anything after a "-" is
+// ignored.
+func newerVersion(a, b string) bool {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := range max(len(pa), len(pb)) {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "-")
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
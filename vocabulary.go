@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// editWatchTime is how long after an insertion edits to it are looked
+	// for, and editPollInterval how often.
+	editWatchTime    = 30 * time.Second
+	editPollInterval = 2 * time.Second
+	// maxCorrectionWords bounds both sides of a learned correction; longer
+	// edits are rewording, not a misheard word.
+	maxCorrectionWords = 3
+	// maxEditChanges is how many separate changes an edit may have to be
+	// learned from.
+	maxEditChanges = 3
+	// maxPromptWords bounds the vocabulary added to the prompt, which
+	// Whisper cuts off at 224 tokens.
+	maxPromptWords = 40
+)
+
+// correction is a word or phrase the transcription got wrong and what it
+// should have been. Seen counts how often it was corrected; it is applied
+// once that reaches vocabulary.learn_after.
+type correction struct {
+	Heard string    `json:"heard"`
+	Meant string    `json:"meant"`
+	Seen  int       `json:"seen"`
+	Last  time.Time `json:"last"`
+}
+
+// vocabularyStore holds the corrections learned from the user, persisted to
+// vocabulary.json in the data directory. It is reread when it changed on
+// disk, as dictation history browse adds to it from another process.
+type vocabularyStore struct {
+	mu          sync.Mutex
+	path        string
+	modified    time.Time
+	Corrections map[string]*correction `json:"corrections"` // by lowercase heard
+}
+
+var vocabulary = &vocabularyStore{Corrections: map[string]*correction{}}
+
+func vocabularyPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vocabulary.json"), nil
+}
+
+func loadVocabulary() (*vocabularyStore, error) {
+	path, err := vocabularyPath()
+	if err != nil {
+		return nil, err
+	}
+	v := &vocabularyStore{path: path, Corrections: map[string]*correction{}}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v, v.reload()
+}
+
+// reload reads the file again if it changed since it was last read.
+func (v *vocabularyStore) reload() error {
+	if v.path == "" {
+		return nil
+	}
+	info, err := os.Stat(v.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading vocabulary: %w", err)
+	}
+	if info.ModTime().Equal(v.modified) {
+		return nil
+	}
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return fmt.Errorf("reading vocabulary: %w", err)
+	}
+	corrections := map[string]*correction{}
+	if err := json.Unmarshal(data, &struct {
+		Corrections *map[string]*correction `json:"corrections"`
+	}{&corrections}); err != nil {
+		return fmt.Errorf("parsing vocabulary %s: %w", v.path, err)
+	}
+	v.Corrections, v.modified = corrections, info.ModTime()
+	return nil
+}
+
+func (v *vocabularyStore) save() error {
+	if v.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o755); err != nil {
+		return err
+	}
+	// Corrections are bits of dictated text, keep them private like the
+	// history.
+	if err := os.WriteFile(v.path, data, 0o600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(v.path); err == nil {
+		v.modified = info.ModTime()
+	}
+	return nil
+}
+
+// learn records a correction. A flagged one was pointed out by the user and
+// applies right away, one seen in an edit has to be seen learn_after times.
+func (v *vocabularyStore) learn(heard, meant string, flagged bool) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.reload(); err != nil {
+		return err
+	}
+
+	key := strings.ToLower(heard)
+	c, ok := v.Corrections[key]
+	if !ok || c.Meant != meant {
+		// A different fix for the same words starts over.
+		c = &correction{Heard: heard, Meant: meant}
+		v.Corrections[key] = c
+	}
+	c.Seen++
+	if flagged {
+		c.Seen = max(c.Seen, config.Vocabulary.LearnAfter)
+	}
+	c.Last = time.Now()
+	return v.save()
+}
+
+// forget drops the correction of heard, reporting whether there was one.
+func (v *vocabularyStore) forget(heard string) (bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.reload(); err != nil {
+		return false, err
+	}
+	key := strings.ToLower(heard)
+	if _, ok := v.Corrections[key]; !ok {
+		return false, nil
+	}
+	delete(v.Corrections, key)
+	return true, v.save()
+}
+
+// merge adds corrections from elsewhere, like a config bundle, for words
+// that have none yet, and returns how many it added.
+func (v *vocabularyStore) merge(corrections []correction) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.reload(); err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, c := range corrections {
+		key := strings.ToLower(c.Heard)
+		if _, ok := v.Corrections[key]; ok || strings.TrimSpace(c.Heard) == "" {
+			continue
+		}
+		v.Corrections[key] = &c
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	return added, v.save()
+}
+
+// list returns all learned corrections, applied or not, most recent first.
+func (v *vocabularyStore) list() []correction {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.reload(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	var list []correction
+	for _, c := range v.Corrections {
+		list = append(list, *c)
+	}
+	slices.SortFunc(list, func(a, b correction) int { return b.Last.Compare(a.Last) })
+	return list
+}
+
+// replacements returns the configured replacements and the learned
+// corrections that are applied, keyed by lowercase heard.
+func (v *vocabularyStore) replacements() map[string]string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.reload(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	r := map[string]string{}
+	for _, c := range v.Corrections {
+		if c.Seen >= config.Vocabulary.LearnAfter {
+			r[strings.ToLower(c.Heard)] = c.Meant
+		}
+	}
+	// Configured ones win over learned ones.
+	for heard, meant := range config.Vocabulary.Replacements {
+		r[strings.ToLower(heard)] = meant
+	}
+	return r
+}
+
+// applyVocabulary replaces the words and phrases of the replacement
+// dictionary, as whole words and ignoring case (see matchCase). Longer
+// phrases go first, so "get hub actions" wins over "get hub".
+func applyVocabulary(text string) string {
+	return applyReplacements(text, vocabulary.replacements())
+}
+
+// applyReplacements replaces the keys of r with their values the same way.
+func applyReplacements(text string, r map[string]string) string {
+	heard := make([]string, 0, len(r))
+	for h := range r {
+		heard = append(heard, h)
+	}
+	slices.SortFunc(heard, func(a, b string) int { return len(b) - len(a) })
+	for _, h := range heard {
+		text = replacementPattern(h).ReplaceAllStringFunc(text, func(match string) string {
+			return matchCase(match, r[h])
+		})
+	}
+	return text
+}
+
+// matchCase gives a replacement in lower case the case of what it
+// replaces, so "Color" at the start of a sentence becomes "Colour" and
+// not "colour". Replacements with capitals ("GitHub") are kept as they are.
+func matchCase(match, replacement string) string {
+	if replacement != strings.ToLower(replacement) {
+		return replacement
+	}
+	if utf8.RuneCountInString(match) > 1 && match == strings.ToUpper(match) && match != strings.ToLower(match) {
+		return strings.ToUpper(replacement)
+	}
+	if first, _ := utf8.DecodeRuneInString(match); unicode.IsUpper(first) {
+		r, n := utf8.DecodeRuneInString(replacement)
+		return string(unicode.ToUpper(r)) + replacement[n:]
+	}
+	return replacement
+}
+
+// replacementPatterns caches the pattern of each heard phrase, as the same
+// ones are applied to every dictation.
+var replacementPatterns sync.Map // string → *regexp.Regexp
+
+func replacementPattern(heard string) *regexp.Regexp {
+	if re, ok := replacementPatterns.Load(heard); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(`(?i)` + phrasePattern(heard))
+	replacementPatterns.Store(heard, re)
+	return re
+}
+
+// vocabularyPrompt adds the vocabulary to a transcription prompt, so Whisper
+// spells names and jargon right to begin with: vocabulary.words and the
+// words the learned corrections introduced.
+func vocabularyPrompt(prompt string) string {
+	if !config.Vocabulary.Prompt {
+		return prompt
+	}
+	var words []string
+	seen := map[string]bool{}
+	add := func(w string) {
+		if key := strings.ToLower(w); w != "" && !seen[key] && len(words) < maxPromptWords {
+			seen[key] = true
+			words = append(words, w)
+		}
+	}
+	for _, w := range config.Vocabulary.Words {
+		add(w)
+	}
+	var meant []string
+	for _, m := range vocabulary.replacements() {
+		meant = append(meant, m)
+	}
+	slices.Sort(meant)
+	for _, w := range meant {
+		add(w)
+	}
+	if len(words) == 0 {
+		return prompt
+	}
+	vocab := "Vocabulary: " + strings.Join(words, ", ") + "."
+	if prompt == "" {
+		return vocab
+	}
+	return prompt + " " + vocab
+}
+
+// watchEdits looks at the text field text was just typed into for a while,
+// and learns the corrections the user makes to it there. macOS only, it
+// reads the field through the accessibility API.
+func watchEdits(text string) {
+	time.Sleep(editPollInterval) // let the insertion land
+	before, ok := focusedText()
+	if !ok {
+		return
+	}
+	i := strings.LastIndex(before, text)
+	if i < 0 {
+		return
+	}
+	prefix, suffix := before[:i], before[i+len(text):]
+	app := frontmostApp()
+
+	edited := text
+	for waited := time.Duration(0); waited < editWatchTime; waited += editPollInterval {
+		time.Sleep(editPollInterval)
+		if frontmostApp() != app {
+			break
+		}
+		after, ok := focusedText()
+		if !ok {
+			break
+		}
+		// Only edits within the dictation count; if what is around it
+		// changed too, the user is typing on or focus moved to another
+		// field, and the dictation can't be told apart.
+		if !strings.HasPrefix(after, prefix) || !strings.HasSuffix(after, suffix) || len(after) < len(prefix)+len(suffix) {
+			break
+		}
+		edited = after[len(prefix) : len(after)-len(suffix)]
+	}
+
+	for _, c := range editCorrections(text, edited) {
+		fmt.Printf("Learned from your edit: %q → %q\n", logged(c.Heard), logged(c.Meant))
+		if err := vocabulary.learn(c.Heard, c.Meant, false); err != nil {
+			fmt.Printf("Warning: failed to save vocabulary: %v\n", err)
+		}
+	}
+}
+
+// editCorrections returns the word substitutions between a dictation and the
+// user's edit of it. Only short one for one swaps count; anything else
+// (adding a sentence, deleting a clause, rewriting it all) is an edit of
+// what was said, not a fix of how it was heard.
+func editCorrections(text, edited string) []correction {
+	var corrections []correction
+	changes := 0
+	wordChanges(strings.Fields(text), strings.Fields(edited), nil, func(removed, added []string) {
+		changes++
+		if len(removed) == 0 || len(added) == 0 || len(removed) > maxCorrectionWords || len(added) > maxCorrectionWords {
+			return
+		}
+		heard, meant := trimWord(strings.Join(removed, " ")), trimWord(strings.Join(added, " "))
+		if heard == "" || meant == "" || strings.EqualFold(heard, meant) {
+			// Only punctuation or case changed.
+			return
+		}
+		corrections = append(corrections, correction{Heard: heard, Meant: meant})
+	})
+	if changes > maxEditChanges {
+		return nil
+	}
+	return corrections
+}
+
+// trimWord strips the punctuation around a word or phrase.
+func trimWord(s string) string {
+	return strings.TrimFunc(s, func(r rune) bool { return unicode.IsPunct(r) })
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const vocabularyUsage = "usage: dictation vocabulary [list | add <heard> <meant> | forget <heard>]"
+
+// runVocabularyCommand shows and edits the learned corrections.
+func runVocabularyCommand(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		list := vocabulary.list()
+		if len(list) == 0 && len(config.Vocabulary.Replacements) == 0 {
+			fmt.Println("No corrections learned yet.")
+			return nil
+		}
+		for _, c := range list {
+			state := "applied"
+			if c.Seen < config.Vocabulary.LearnAfter {
+				state = fmt.Sprintf("seen %d of %d times", c.Seen, config.Vocabulary.LearnAfter)
+			}
+			fmt.Printf("%q → %q  (%s, last %s)\n", c.Heard, c.Meant, state, c.Last.Local().Format("2006-01-02"))
+		}
+		for heard, meant := range config.Vocabulary.Replacements {
+			fmt.Printf("%q → %q  (config)\n", heard, meant)
+		}
+		return nil
+	case "add":
+		if len(args) != 3 || strings.TrimSpace(args[1]) == "" {
+			return errors.New(vocabularyUsage)
+		}
+		if err := vocabulary.learn(args[1], args[2], true); err != nil {
+			return fmt.Errorf("saving vocabulary: %w", err)
+		}
+		fmt.Printf("Added %q → %q\n", args[1], args[2])
+		return nil
+	case "forget":
+		if len(args) != 2 {
+			return errors.New(vocabularyUsage)
+		}
+		ok, err := vocabulary.forget(args[1])
+		if err != nil {
+			return fmt.Errorf("saving vocabulary: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("no learned correction for %q", args[1])
+		}
+		fmt.Printf("Forgot the correction of %q\n", args[1])
+		return nil
+	default:
+		return errors.New(vocabularyUsage)
+	}
+}
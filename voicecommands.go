@@ -0,0 +1,139 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// casingCommand matches a spoken casing command and the words it applies
+// to: everything up to the next punctuation mark, the end of the text or
+// the spoken terminator "end case". Whisper often capitalizes the command or
+// puts a comma after it, both are accepted.
+var casingCommand = casingPattern(nil, "")
+
+// casingPattern is casingCommand with the casing commands of a locale,
+// phrase to style, and its word for "end case" accepted too.
+func casingPattern(casing map[string]string, endCase string) *regexp.Regexp {
+	command := `(?:camel|pascal|snake|kebab|constant|title|upper|lower)[ -]case`
+	for phrase := range casing {
+		command += "|" + strings.ReplaceAll(regexp.QuoteMeta(phrase), " ", `\s+`)
+	}
+	end := `end case`
+	if endCase != "" {
+		end += "|" + strings.ReplaceAll(regexp.QuoteMeta(endCase), " ", `\s+`)
+	}
+	return regexp.MustCompile(`(?i)\b(` + command + `)\b,?\s+([^.,!?;:]*?)(?:\s*\b(?:` + end + `)\b|\s*([.,!?;:])|\s*$)`)
+}
+
+// casingCommands caches casingPattern for each locale.
+var casingCommands sync.Map
+
+// casingStyles format the words of a casing command.
+var casingStyles = map[string]func(words []string) string{
+	"camel": func(words []string) string {
+		for i, w := range words {
+			if i == 0 {
+				words[i] = strings.ToLower(w)
+			} else {
+				words[i] = capitalize(strings.ToLower(w))
+			}
+		}
+		return strings.Join(words, "")
+	},
+	"pascal": func(words []string) string {
+		for i, w := range words {
+			words[i] = capitalize(strings.ToLower(w))
+		}
+		return strings.Join(words, "")
+	},
+	"snake": func(words []string) string {
+		return strings.ToLower(strings.Join(words, "_"))
+	},
+	"kebab": func(words []string) string {
+		return strings.ToLower(strings.Join(words, "-"))
+	},
+	"constant": func(words []string) string {
+		return strings.ToUpper(strings.Join(words, "_"))
+	},
+	"title": titleCase,
+	"upper": func(words []string) string {
+		return strings.ToUpper(strings.Join(words, " "))
+	},
+	"lower": func(words []string) string {
+		return strings.ToLower(strings.Join(words, " "))
+	},
+}
+
+// applyVoiceCommands runs the spoken commands in a transcription, like
+// "camel case foo bar" becoming "fooBar". The locale's casing commands
+// work as well.
+func applyVoiceCommands(text string) string {
+	pattern := casingCommand
+	l, localized := locales[activeLocale()]
+	if localized {
+		cached, ok := casingCommands.Load(activeLocale())
+		if !ok {
+			cached, _ = casingCommands.LoadOrStore(activeLocale(), casingPattern(l.Casing, l.EndCase))
+		}
+		pattern = cached.(*regexp.Regexp)
+	}
+	return pattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := pattern.FindStringSubmatch(match)
+		command := strings.Join(strings.Fields(strings.ToLower(m[1])), " ")
+		style, ok := l.Casing[command]
+		if !ok {
+			// "camel case" or "camel-case".
+			style, _, _ = strings.Cut(strings.ReplaceAll(command, "-", " "), " ")
+		}
+		words, punct := casingWords(m[2]), m[3]
+		if len(words) == 0 {
+			return match
+		}
+		return casingStyles[style](words) + punct
+	})
+}
+
+// casingWords splits the dictated words, dropping punctuation Whisper may
+// have put inside them.
+func casingWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
+
+// titleMinorWords stay lower case in titles unless they come first or last.
+var titleMinorWords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "but": true, "or": true,
+	"nor": true, "for": true, "so": true, "yet": true, "as": true, "at": true,
+	"by": true, "in": true, "of": true, "off": true, "on": true, "per": true,
+	"to": true, "up": true, "via": true, "vs": true,
+}
+
+// titleCase capitalizes each word except short articles, conjunctions and
+// prepositions in the middle of the title. Words that already have capitals
+// inside (iPhone, NASA) are kept as they are.
+func titleCase(words []string) string {
+	for i, w := range words {
+		lower := strings.ToLower(w)
+		switch {
+		case w != lower && w != capitalize(lower):
+			// mixed case, keep
+		case i > 0 && i < len(words)-1 && titleMinorWords[lower]:
+			words[i] = lower
+		default:
+			words[i] = capitalize(lower)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// watchFormats are the audio files the watcher picks up.
+var watchFormats = []string{".aac", ".aif", ".aiff", ".caf", ".flac", ".m4a", ".mp3", ".mp4", ".oga", ".ogg", ".wav", ".webm"}
+
+// runWatchCommand transcribes audio files appearing in a folder into a text
+// file next to each of them, e.g. for Voice Memos exported to iCloud Drive.
+func runWatchCommand(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	profileName := flags.String("profile", defaultProfile, "transcription profile to use")
+	format := flags.String("format", "txt", "output format, txt or md")
+	interval := flags.Duration("interval", 5*time.Second, "how often to look for new files")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dictation watch [-profile name] [-format txt|md] [-interval 5s] folder")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected exactly one folder")
+	}
+	if *format != "txt" && *format != "md" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	dir := flags.Arg(0)
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", dir)
+	}
+
+	// Checked up front, and again for each file for the budget.
+	if _, err := fileProfile(*profileName); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	w := &folderWatcher{dir: dir, format: *format, profile: *profileName, sizes: map[string]int64{}}
+	fmt.Printf("Watching %s for new audio files. Press Ctrl+C to exit.\n", dir)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		w.scan()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// folderWatcher polls a folder. A file is transcribed once it has no output
+// file yet and its size stayed the same between two scans, so files still
+// being synced or copied aren't picked up half written.
+type folderWatcher struct {
+	dir     string
+	format  string
+	profile string
+	sizes   map[string]int64
+	failed  map[string]time.Time
+}
+
+func (w *folderWatcher) scan() {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", w.dir, err)
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !slices.Contains(watchFormats, strings.ToLower(filepath.Ext(name))) {
+			continue
+		}
+
+		path := filepath.Join(w.dir, name)
+		out := strings.TrimSuffix(path, filepath.Ext(path)) + "." + w.format
+		if _, err := os.Stat(out); err == nil {
+			delete(w.sizes, path)
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if last, seen := w.sizes[path]; !seen || last != info.Size() {
+			w.sizes[path] = info.Size()
+			continue
+		}
+
+		// Failed files are retried, but not on every scan.
+		if at, failed := w.failed[path]; failed && time.Since(at) < 5*time.Minute {
+			continue
+		}
+
+		fmt.Printf("Transcribing %s\n", name)
+		if err := w.transcribe(path, out, info.ModTime()); err != nil {
+			fmt.Printf("Error transcribing %s: %v\n", name, err)
+			if w.failed == nil {
+				w.failed = map[string]time.Time{}
+			}
+			w.failed[path] = time.Now()
+			continue
+		}
+		delete(w.sizes, path)
+		delete(w.failed, path)
+		fmt.Printf("Wrote %s\n", filepath.Base(out))
+	}
+}
+
+func (w *folderWatcher) transcribe(path, out string, recorded time.Time) error {
+	profile, err := fileProfile(w.profile)
+	if err != nil {
+		return err
+	}
+	text, err := transcribeFile(path, profile)
+	if err != nil {
+		return err
+	}
+
+	content := text + "\n"
+	if w.format == "md" {
+		title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		content = fmt.Sprintf("# %s\n\n_Recorded %s_\n\n%s\n", title, recorded.Format("2006-01-02 15:04"), text)
+	}
+
+	// Write to a temporary name first, the output file existing is what
+	// marks the recording as done.
+	tmp := out + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, out)
+}
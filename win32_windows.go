@@ -0,0 +1,81 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+// The Win32 functions used on Windows, called without cgo.
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procRegisterHotKey      = user32.NewProc("RegisterHotKey")
+	procUnregisterHotKey    = user32.NewProc("UnregisterHotKey")
+	procGetMessageW         = user32.NewProc("GetMessageW")
+	procPostThreadMessageW  = user32.NewProc("PostThreadMessageW")
+	procSendInput           = user32.NewProc("SendInput")
+	procOpenClipboard       = user32.NewProc("OpenClipboard")
+	procCloseClipboard      = user32.NewProc("CloseClipboard")
+	procEmptyClipboard      = user32.NewProc("EmptyClipboard")
+	procGetClipboardData    = user32.NewProc("GetClipboardData")
+	procSetClipboardData    = user32.NewProc("SetClipboardData")
+	procGetForegroundWindow = user32.NewProc("GetForegroundWindow")
+	procGetWindowTextW      = user32.NewProc("GetWindowTextW")
+	procGetWindowThreadPID  = user32.NewProc("GetWindowThreadProcessId")
+
+	procGetCurrentThreadId         = kernel32.NewProc("GetCurrentThreadId")
+	procGlobalAlloc                = kernel32.NewProc("GlobalAlloc")
+	procGlobalFree                 = kernel32.NewProc("GlobalFree")
+	procGlobalLock                 = kernel32.NewProc("GlobalLock")
+	procGlobalUnlock               = kernel32.NewProc("GlobalUnlock")
+	procLstrlenW                   = kernel32.NewProc("lstrlenW")
+	procRtlMoveMemory              = kernel32.NewProc("RtlMoveMemory")
+	procOpenProcess                = kernel32.NewProc("OpenProcess")
+	procCloseHandle                = kernel32.NewProc("CloseHandle")
+	procQueryFullProcessImageNameW = kernel32.NewProc("QueryFullProcessImageNameW")
+	procGetDiskFreeSpaceExW        = kernel32.NewProc("GetDiskFreeSpaceExW")
+)
+
+const (
+	wmHotkey = 0x0312
+	wmQuit   = 0x0012
+
+	modNoRepeat = 0x4000
+
+	inputKeyboard    = 1
+	keyeventfKeyUp   = 0x0002
+	keyeventfUnicode = 0x0004
+
+	cfUnicodeText = 13
+	gmemMoveable  = 0x0002
+
+	processQueryLimitedInformation = 0x1000
+)
+
+// msg is MSG.
+type msg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      struct{ x, y int32 }
+	private uint32
+}
+
+// keyboardInput is an INPUT of type INPUT_KEYBOARD. The padding makes it as
+// large as the union's biggest member, MOUSEINPUT, which SendInput checks.
+type keyboardInput struct {
+	typ     uint32
+	ki      keybdInput
+	padding [8]byte
+}
+
+// keybdInput is KEYBDINPUT. As its own struct it gets the union's alignment.
+type keybdInput struct {
+	vk    uint16
+	scan  uint16
+	flags uint32
+	time  uint32
+	extra uintptr
+}